@@ -2,28 +2,51 @@ package main
 
 import (
 	"PICs_Manager/config"
+	"PICs_Manager/internal/auth"
+	"PICs_Manager/internal/models"
 	"PICs_Manager/pkg/database"
 	"PICs_Manager/pkg/database/mongo"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/layout"
+	"PICs_Manager/pkg/library"
 	"PICs_Manager/pkg/maintenance"
+	"PICs_Manager/pkg/quarantine"
 	"PICs_Manager/pkg/scanner"
+	"PICs_Manager/pkg/trash"
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
 	// --- 1. 定义命令行参数 ---
-	action := flag.String("action", "", "要执行的操作: scan, list-series, list-images, search")
+	action := flag.String("action", "", "要执行的操作: scan, create-manifest, dump-database, fix-orientation, list-series, list-images, search, create-user, quarantine-list, quarantine-restore, quarantine-purge, trash-list, trash-restore, trash-purge, migrate-layout, staging, staging-resume, staging-return, test-rules, config")
 	seriesID := flag.String("series-id", "", "用于 list-images 或其他系列特定操作的ID")
 	query := flag.String("query", "", "用于 search 操作的搜索关键词")
 	page := flag.Int("page", 1, "分页页码")
 	limit := flag.Int("limit", 20, "每页数量")
+	username := flag.String("username", "", "用于 create-user 操作的用户名")
+	role := flag.String("role", string(models.RoleViewer), "用于 create-user 操作的角色: admin, viewer")
+	itemID := flag.String("id", "", "用于 quarantine-restore / quarantine-purge / trash-restore / trash-purge 操作的记录ID；trash-purge 不提供时清除所有过期条目")
+	kind := flag.String("kind", "", "用于 quarantine-list 操作的隔离原因筛选，例如 undecodable")
+	dryRun := flag.Bool("dry-run", false, "用于 migrate-layout 操作：只打印移动计划，不修改文件和数据库")
+	dir := flag.String("dir", "", "用于 test-rules 操作：从该目录取样文件和子目录进行测试")
+	folders := flag.Bool("folders", false, "用于 test-rules 操作：把其余参数当作系列文件夹名而不是文件名")
+	libraryName := flag.String("library", "", "要操作的库名，默认使用配置中的第一个库 (没有配置 libraries 时为 default)")
+	configPath := flag.String("config", ".", "配置文件路径，或包含 config.yaml 的目录；PICS_ 开头的环境变量会覆盖其中的值")
 
 	flag.Parse()
 
@@ -34,32 +57,60 @@ func main() {
 	}
 
 	// --- 2. 初始化应用核心组件 ---
-	// 假设 config.yaml 在项目根目录
-	if err := config.LoadConfig("."); err != nil {
+	if err := config.LoadConfig(*configPath); err != nil {
 		log.Fatalf("FATAL: 无法加载配置: %v", err)
 	}
 	// slog 的初始化可以更简单，这里我们直接使用默认配置
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	if err := config.Current().Validate(); err != nil {
+		slog.Warn("配置校验未通过，相关功能可能无法正常工作", "文件", config.File, "error", err)
+	}
+	lib := config.Current().DefaultLibraryConfig()
+	if *libraryName != "" {
+		var ok bool
+		if lib, ok = config.Current().Library(*libraryName); !ok {
+			fmt.Printf("错误: 找不到库 '%s'\n", *libraryName)
+			os.Exit(1)
+		}
+	}
+
+	// 规则测试和查看配置只读取配置，不需要连接数据库
+	switch *action {
+	case "test-rules":
+		testRules(lib.Scanner, *dir, *limit, *folders, flag.Args())
+		return
+	case "config":
+		configCommand(flag.Args())
+		return
+	}
 
 	var db database.Store
 	var err error
-	db, err = mongo.NewStore(context.Background(), config.C)
+	db, err = mongo.NewStore(context.Background(), config.Current())
 	if err != nil {
 		slog.Error("FATAL: 无法连接到数据库", "error", err)
 		os.Exit(1)
 	}
+	// db 用于用户管理，store 是所选库的内容
+	store := database.LibraryStores(db, config.Current())[lib.Name]
 	if err := db.EnsureIndexes(context.Background()); err != nil {
 		slog.Error("FATAL: 无法创建/验证数据库索引", "error", err)
 		os.Exit(1)
 	}
+	if store != db {
+		if err := store.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("FATAL: 无法创建/验证数据库索引", "库", lib.Name, "error", err)
+			os.Exit(1)
+		}
+	}
 
-	orchestrator, err := scanner.NewOrchestrator(config.C, db)
+	orchestrator, err := scanner.NewOrchestrator(config.Current(), lib, store)
 	if err != nil {
 		slog.Error("FATAL: 无法创建扫描与处理协调器", "error", err)
 		os.Exit(1)
 	}
 
-	maintenanceModule, err := maintenance.NewMaintenance(config.C.Logger.Path, config.C.Scanner.WorkerCount)
+	maintenanceModule, err := maintenance.NewMaintenance(config.Current().LogDir(lib.Name), fsutil.OS, lib.Scanner.WorkerCount)
 	if err != nil {
 		slog.Error("FATAL: 无法创建维护模块", "error", err)
 		os.Exit(1)
@@ -70,13 +121,69 @@ func main() {
 	switch *action {
 	case "scan":
 		slog.Info("开始执行完整的扫描、整理、入库流水线任务...")
-		orchestrator.RunFullScan(config.C.Scanner)
-		slog.Info("批量导入已执行完毕。")
+		report, err := orchestrator.RunFullScan(lib.Scanner)
+		if report == nil {
+			slog.Error("扫描失败", "error", err)
+			os.Exit(1)
+		}
+		if r := report.Resumed; r != nil {
+			slog.Info("已继续完成上一次中断的扫描运行", "run", r.RunID, "resumedFrom", r.ResumedFrom, "files", r.ProcessedFiles, "series", r.Series)
+		}
+		slog.Info("批量导入已执行完毕。", "run", report.RunID, "files", report.ProcessedFiles, "series", report.Series, "classifiers", report.Classifiers)
+		for _, path := range report.Unclassified {
+			slog.Warn("文件无法分类，仍留在扫描目录中", "path", path)
+		}
+		if err != nil {
+			slog.Error("扫描未完成，下次扫描将从最后的检查点继续", "run", report.RunID, "error", err)
+			os.Exit(1)
+		}
+
+	case "staging":
+		report, err := scanner.InspectStaging(lib.Scanner.StagingPath)
+		if err != nil {
+			slog.Error("无法检查中转站", "error", err)
+			return
+		}
+		if report.Empty() {
+			fmt.Printf("中转站 %s 中没有遗留内容。\n", report.Path)
+			return
+		}
+		fmt.Printf("--- 中转站 %s 中遗留了 %d 个系列、%d 个文件 (%d 字节) ---\n", report.Path, len(report.Series), report.Files, report.Bytes)
+		for _, s := range report.Series {
+			fmt.Printf("  %s: %d 个文件, %d 字节\n", s.Name, s.Files, s.Bytes)
+		}
+		for _, f := range report.LooseFiles {
+			fmt.Printf("  (不属于任何系列) %s\n", f)
+		}
+		fmt.Println("执行 -action staging-resume 继续归档入库，或 -action staging-return 退回扫描目录。")
+
+	case "staging-resume":
+		slog.Info("从中转站继续上次中断的扫描...")
+		report, err := orchestrator.ResumeStaging(ctx, lib.Scanner)
+		if err != nil {
+			slog.Error("从中转站继续失败", "error", err)
+			return
+		}
+		slog.Info("从中转站继续已执行完毕。", "files", report.ProcessedFiles, "series", report.Series)
+
+	case "staging-return":
+		result, err := scanner.ReturnStaging(fsutil.OS, lib.Scanner.StagingPath, lib.Scanner.ScanPath)
+		if err != nil {
+			slog.Error("退回扫描目录失败", "error", err)
+			return
+		}
+		for _, path := range result.Conflicts {
+			fmt.Printf("  冲突，保留在中转站: %s\n", path)
+		}
+		for path, reason := range result.Failed {
+			fmt.Printf("  失败: %s: %s\n", path, reason)
+		}
+		fmt.Printf("完成: 退回 %d 个文件到 %s，冲突 %d 个，失败 %d 个。\n", result.Moved, lib.Scanner.ScanPath, len(result.Conflicts), len(result.Failed))
 
 	case "create-manifest":
 		slog.Info("开始生成文件系统清单...")
-		finalLibraryPath, _ := filepath.Abs(config.C.Scanner.FinalLibraryPath)
-		backupPath, _ := filepath.Abs(config.C.Scanner.BackupPath)
+		finalLibraryPath, _ := filepath.Abs(lib.Scanner.FinalLibraryPath)
+		backupPath, _ := filepath.Abs(lib.Scanner.BackupPath)
 		if err := maintenanceModule.GenerateFileManifest(ctx, finalLibraryPath, backupPath); err != nil {
 			slog.Error("生成文件清单失败", "error", err)
 		} else {
@@ -85,16 +192,29 @@ func main() {
 
 	case "dump-database":
 		slog.Info("开始执行数据库压缩备份...")
-		backupPath, _ := filepath.Abs(config.C.Scanner.BackupPath)
-		if err := maintenanceModule.BackupDatabase(ctx, config.C.Database.URI, config.C.Database.Name, backupPath); err != nil {
+		databaseName := lib.Database
+		if databaseName == "" {
+			databaseName = config.Current().Database.Name
+		}
+		backupPath, _ := filepath.Abs(lib.Scanner.BackupPath)
+		if err := maintenanceModule.BackupDatabase(ctx, config.Current().Database.URI, databaseName, backupPath); err != nil {
 			slog.Error("数据库备份失败", "error", err)
 		} else {
 			slog.Info("数据库备份成功！")
 		}
 
+	case "fix-orientation":
+		slog.Info("开始为需要方向校正的图片重新生成缩略图...")
+		updated, err := maintenanceModule.RegenerateOrientedThumbnails(ctx, store)
+		if err != nil {
+			slog.Error("缩略图方向校正失败", "error", err)
+		} else {
+			slog.Info("缩略图方向校正完成", "updated", updated)
+		}
+
 	case "list-series":
 		fmt.Println("--- 获取系列列表 ---")
-		series, total, err := db.Series().List(ctx, *page, *limit)
+		series, total, err := store.Series().List(ctx, *page, *limit)
 		if err != nil {
 			slog.Error("获取系列列表失败", "error", err)
 			return
@@ -116,7 +236,7 @@ func main() {
 			return
 		}
 		fmt.Printf("--- 获取系列 '%s' 下的图片列表 ---\n", *seriesID)
-		images, total, err := db.Images().ListBySeriesID(ctx, objID, *page, *limit)
+		images, total, err := store.Images().ListBySeriesID(ctx, objID, *page, *limit)
 		if err != nil {
 			slog.Error("获取图片列表失败", "error", err)
 			return
@@ -133,7 +253,7 @@ func main() {
 		}
 		fmt.Printf("--- 搜索系列名包含 '%s' 的系列 ---\n", *query)
 		// 注意：我们之前实现的是按图片文件名搜索，这里改为按系列名搜索可能更有用
-		series, total, err := db.Series().SearchByName(ctx, *query, *page, *limit)
+		series, total, err := store.Series().SearchByName(ctx, *query, *page, *limit)
 		if err != nil {
 			slog.Error("搜索系列失败", "error", err)
 			return
@@ -143,8 +263,328 @@ func main() {
 			fmt.Printf("  ID: %s, Name: %s, Path: %s\n", s.ID.Hex(), s.Name, s.Path)
 		}
 
+	case "create-user":
+		if *username == "" {
+			fmt.Println("错误: create-user 操作需要提供 -username 参数。")
+			return
+		}
+		userRole := models.Role(*role)
+		if !auth.ValidRole(userRole) {
+			fmt.Printf("错误: 无效的角色 '%s'\n", *role)
+			return
+		}
+		password, err := readPassword()
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return
+		}
+		passwordHash, err := auth.HashPassword(password)
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			return
+		}
+		user := &models.User{Username: *username, PasswordHash: passwordHash, Role: userRole}
+		if err := db.Users().Create(ctx, user); err != nil {
+			slog.Error("创建用户失败", "error", err)
+			return
+		}
+		fmt.Printf("用户已创建: ID: %s, Username: %s, Role: %s\n", user.ID.Hex(), user.Username, user.Role)
+
+	case "quarantine-list":
+		fmt.Println("--- 获取隔离区列表 ---")
+		items, total, err := store.Quarantine().List(ctx, models.QuarantineKind(*kind), *page, *limit)
+		if err != nil {
+			slog.Error("获取隔离列表失败", "error", err)
+			return
+		}
+		fmt.Printf("总共找到 %d 个隔离文件 (正在显示第 %d 页，每页 %d 个):\n", total, *page, *limit)
+		for _, item := range items {
+			fmt.Printf("ID: %s\n  Kind: %s\n  Original: %s\n  Quarantined: %s\n  Error: %s\n  CreatedAt: %s\n\n",
+				item.ID.Hex(), item.Kind, item.OriginalPath, item.QuarantinedPath, item.Error, item.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+
+	case "quarantine-restore", "quarantine-purge":
+		if *itemID == "" {
+			fmt.Printf("错误: %s 操作需要提供 -id 参数。\n", *action)
+			return
+		}
+		objID, err := primitive.ObjectIDFromHex(*itemID)
+		if err != nil {
+			fmt.Printf("错误: 无效的 id 格式: %v\n", err)
+			return
+		}
+		bin, err := trash.NewManager(fsutil.OS, lib.Scanner.TrashPath, lib.Scanner.TrashRetention, store.Trash())
+		if err != nil {
+			slog.Error("无法创建回收站管理器", "error", err)
+			return
+		}
+		manager, err := quarantine.NewManager(fsutil.OS, lib.Scanner.QuarantinePath, bin, store.Quarantine())
+		if err != nil {
+			slog.Error("无法创建隔离区管理器", "error", err)
+			return
+		}
+		if *action == "quarantine-restore" {
+			item, err := manager.Restore(ctx, objID)
+			if err != nil {
+				slog.Error("恢复隔离文件失败", "error", err)
+				return
+			}
+			fmt.Printf("已恢复: %s\n", item.OriginalPath)
+		} else {
+			item, err := manager.Purge(ctx, objID)
+			if err != nil {
+				slog.Error("清除隔离文件失败", "error", err)
+				return
+			}
+			fmt.Printf("已移入回收站: %s\n", item.QuarantinedPath)
+		}
+
+	case "trash-list":
+		fmt.Println("--- 获取回收站列表 ---")
+		items, total, err := store.Trash().List(ctx, *page, *limit)
+		if err != nil {
+			slog.Error("获取回收站列表失败", "error", err)
+			return
+		}
+		fmt.Printf("总共找到 %d 个回收站条目 (正在显示第 %d 页，每页 %d 个):\n", total, *page, *limit)
+		for _, item := range items {
+			expires := "永不"
+			if !item.ExpiresAt.IsZero() {
+				expires = item.ExpiresAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("ID: %s\n  Source: %s\n  Original: %s\n  Trashed: %s\n  Reason: %s\n  DeletedAt: %s\n  ExpiresAt: %s\n\n",
+				item.ID.Hex(), item.Source, item.OriginalPath, item.TrashedPath, item.Reason, item.CreatedAt.Format("2006-01-02 15:04:05"), expires)
+		}
+
+	case "trash-restore", "trash-purge":
+		manager, err := trash.NewManager(fsutil.OS, lib.Scanner.TrashPath, lib.Scanner.TrashRetention, store.Trash())
+		if err != nil {
+			slog.Error("无法创建回收站管理器", "error", err)
+			return
+		}
+		if *action == "trash-purge" && *itemID == "" {
+			purged, err := manager.PurgeExpired(ctx)
+			if err != nil {
+				slog.Error("清除过期回收站条目失败", "error", err)
+				return
+			}
+			fmt.Printf("已永久删除 %d 个过期条目\n", purged)
+			return
+		}
+		if *itemID == "" {
+			fmt.Println("错误: trash-restore 操作需要提供 -id 参数。")
+			return
+		}
+		objID, err := primitive.ObjectIDFromHex(*itemID)
+		if err != nil {
+			fmt.Printf("错误: 无效的 id 格式: %v\n", err)
+			return
+		}
+		if *action == "trash-restore" {
+			item, err := manager.Restore(ctx, objID)
+			if err != nil {
+				slog.Error("恢复回收站条目失败", "error", err)
+				return
+			}
+			fmt.Printf("已恢复: %s (库内文件需要重新扫描或同步后才会重新出现)\n", item.OriginalPath)
+		} else {
+			item, err := manager.Purge(ctx, objID)
+			if err != nil {
+				slog.Error("永久删除回收站条目失败", "error", err)
+				return
+			}
+			fmt.Printf("已永久删除: %s\n", item.TrashedPath)
+		}
+
+	case "migrate-layout":
+		libraryLayout, err := layout.New(lib.Scanner.LibraryLayout)
+		if err != nil {
+			slog.Error("无效的 libraryLayout 配置", "error", err)
+			return
+		}
+		editor, err := library.NewEditor(lib.Scanner.FinalLibraryPath, libraryLayout, nil, store)
+		if err != nil {
+			slog.Error("无法创建库整理模块", "error", err)
+			return
+		}
+		fmt.Printf("--- 把最终库迁移到 %s 布局 (dry-run: %t) ---\n", libraryLayout.Name(), *dryRun)
+		report, err := editor.MigrateLayout(ctx, *dryRun)
+		if err != nil {
+			slog.Error("布局迁移失败", "error", err)
+			return
+		}
+		for _, m := range report.Moved {
+			fmt.Printf("  移动: %s\n     -> %s (%d 个系列)\n", m.From, m.To, m.Series)
+		}
+		for _, m := range report.Failed {
+			fmt.Printf("  失败: %s\n     -> %s: %s\n", m.From, m.To, m.Error)
+		}
+		fmt.Printf("完成: 移动 %d 个，失败 %d 个，无需移动 %d 个。\n", len(report.Moved), len(report.Failed), report.Unchanged)
+
 	default:
 		fmt.Printf("错误: 未知的 action '%s'\n", *action)
 		flag.Usage()
 	}
 }
+
+// testRules 打印文件名和文件夹名命中的规则，不移动任何文件。
+// 用法: -action test-rules [-library 库名] [-dir 目录] [-folders] 名称...
+func testRules(cfg config.ScannerConfig, dir string, limit int, asFolders bool, names []string) {
+	var files, folders []string
+	if asFolders {
+		folders = names
+	} else {
+		files = names
+	}
+	var scanRoot string
+	if dir != "" {
+		scanRoot, _ = filepath.Abs(dir)
+		sampledFiles, sampledFolders, err := scanner.SampleDir(scanRoot, limit)
+		if err != nil {
+			slog.Error("取样目录失败", "error", err)
+			return
+		}
+		files = append(files, sampledFiles...)
+		folders = append(folders, sampledFolders...)
+	}
+	if len(files) == 0 && len(folders) == 0 {
+		fmt.Println("错误: 请在参数中提供文件名 (或配合 -folders 提供文件夹名)，或使用 -dir 指定取样目录。")
+		return
+	}
+
+	report, err := scanner.EvaluateRules(cfg, scanRoot, files, folders)
+	if err != nil {
+		slog.Error("规则无效", "error", err)
+		return
+	}
+	if len(report.Files) > 0 {
+		fmt.Println("--- 文件分类 (filePatterns / classifiers) ---")
+		for _, f := range report.Files {
+			if f.RuleIndex >= 0 {
+				fmt.Printf("  %s\n     filePatterns[%d] %s -> 系列 '%s'\n", f.Input, f.RuleIndex, f.Pattern, f.SeriesName)
+			} else {
+				fmt.Printf("  %s\n     没有命中任何 filePatterns\n", f.Input)
+			}
+			if f.Classifier != "" {
+				fmt.Printf("     分类链: [%s] -> 系列 '%s'\n", f.Classifier, f.ClassifiedAs)
+			} else {
+				fmt.Println("     分类链: 无法分类，扫描时会留在扫描目录中")
+			}
+		}
+	}
+	if len(report.Folders) > 0 {
+		fmt.Println("--- 文件夹聚合 (seriesGroupPatterns) ---")
+		for _, f := range report.Folders {
+			if f.RuleIndex < 0 {
+				fmt.Printf("  %s\n     不聚合\n", f.Input)
+				continue
+			}
+			fmt.Printf("  %s\n     规则 '%s' -> %s (本次输入中同组 %d 个)\n", f.Input, f.Rule, f.AggFolder, f.Members)
+		}
+	}
+}
+
+// configCommand 处理 config 子命令。
+// 用法: -action config print [--effective]
+// 不带 --effective 时打印配置文件原文；带 --effective 时打印合并环境变量和默认值后的最终配置及每一项的来源。
+func configCommand(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Println("用法: -action config print [--effective]")
+		return
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	effective := fs.Bool("effective", false, "打印合并环境变量和默认值后的最终配置，并标明每一项的来源")
+	fs.Parse(args[1:])
+
+	if !*effective {
+		data, err := os.ReadFile(config.File)
+		if err != nil {
+			slog.Error("读取配置文件失败", "error", err)
+			return
+		}
+		fmt.Printf("# %s\n%s", config.File, data)
+		return
+	}
+
+	fmt.Printf("# 配置文件: %s\n", config.File)
+	fmt.Printf("# 来源: %s = 配置文件, %s = 环境变量, %s = 默认值\n", config.SourceFile, config.SourceEnv, config.SourceDefault)
+	for _, s := range config.Effective() {
+		env := s.Env
+		if env == "" {
+			env = "(只能在配置文件中设置)"
+		}
+		fmt.Printf("%-32s = %-40s [%s] %s\n", s.Key, formatSetting(s.Value), s.Source, env)
+	}
+}
+
+// formatSetting 把配置值格式化为一行：时长使用 Go 的写法，列表和规则使用与配置文件相同键名的行内 YAML。
+func formatSetting(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strconv.Quote(t)
+	case time.Duration:
+		return t.String()
+	}
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	setFlowStyle(&node)
+	data, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func setFlowStyle(n *yaml.Node) {
+	if n.Kind == yaml.SequenceNode || n.Kind == yaml.MappingNode {
+		n.Style |= yaml.FlowStyle
+	}
+	for _, c := range n.Content {
+		setFlowStyle(c)
+	}
+}
+
+// passwordEnv 是 create-user 读取密码的环境变量。密码不通过命令行参数传入，
+// 以免出现在进程列表和 shell 历史中。
+const passwordEnv = "PICS_USER_PASSWORD"
+
+// readPassword 读取 create-user 的密码：优先使用 passwordEnv 环境变量；
+// 标准输入是终端时不回显地提示输入两次，否则读取标准输入的第一行。
+func readPassword() (string, error) {
+	if password := os.Getenv(passwordEnv); password != "" {
+		return password, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("读取密码失败: %w", err)
+		}
+		if password := strings.TrimRight(line, "\r\n"); password != "" {
+			return password, nil
+		}
+		return "", fmt.Errorf("没有提供密码，请通过标准输入或 %s 环境变量提供", passwordEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "密码: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取密码失败: %w", err)
+	}
+	fmt.Fprint(os.Stderr, "确认密码: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取密码失败: %w", err)
+	}
+	if len(password) == 0 {
+		return "", fmt.Errorf("密码不能为空")
+	}
+	if string(password) != string(confirm) {
+		return "", fmt.Errorf("两次输入的密码不一致")
+	}
+	return string(password), nil
+}
@@ -9,7 +9,9 @@ import (
 	"PICs_Manager/pkg/database/mongo"
 	"PICs_Manager/pkg/logger"
 	"PICs_Manager/pkg/scanner"
+	"PICs_Manager/pkg/trash"
 	"context"
+	"flag"
 	"log"
 	"log/slog"
 	"net/http"
@@ -18,59 +20,88 @@ import (
 )
 
 func main() {
+	configPath := flag.String("config", ".", "配置文件路径，或包含 config.yaml 的目录；PICS_ 开头的环境变量会覆盖其中的值")
+	flag.Parse()
+
 	// --- 1. 初始化 ---
-	if err := config.LoadConfig("."); err != nil {
+	if err := config.LoadConfig(*configPath); err != nil {
 		log.Fatalf("FATAL: 无法加载配置: %v", err)
 	}
 	// [修正] 根据错误提示“实参过多”，InitLogger很可能不需要参数，
-	// 而是直接在内部使用全局的 config.C。
+	// 而是直接在内部使用当前生效的配置 config.Current()。
 	if err := logger.InitLogger(); err != nil {
 		log.Fatalf("FATAL: 无法初始化日志: %v", err)
 	}
 	slog.Info("应用启动")
+	// 配置问题只给出警告，以便仍能通过 Web 界面修正配置
+	if err := config.Current().Validate(); err != nil {
+		slog.Warn("配置校验未通过，相关功能可能无法正常工作", "文件", config.File, "error", err)
+	}
+	if !config.Current().AuthEnabled() {
+		slog.Warn("!!! 认证已关闭 (auth.enabled: false)：任何能访问本服务的人都将以管理员身份操作，包括删除文件和修改配置。仅应在本机单人使用时关闭 !!!", "地址", config.Current().Server.Port)
+	}
 	defer slog.Info("应用关闭")
 
 	// --- 2. 连接数据库 ---
 	var db database.Store
 	var err error
 	// [修正] 根据错误提示，NewStore 函数期望接收整个配置对象 (*config.Config)，
-	// 而不是其中的一部分 (config.C.Database)。
-	db, err = mongo.NewStore(context.Background(), config.C)
+	// 而不是其中的一部分 (config.Current().Database)。
+	db, err = mongo.NewStore(context.Background(), config.Current())
 	if err != nil {
 		slog.Error("FATAL: 无法连接到数据库", "error", err)
 		os.Exit(1)
 	}
+	// 每个库的内容位于自己的数据库或带前缀的集合中，用户和会话所在的主库也需要索引
+	stores := database.LibraryStores(db, config.Current())
 	if err := db.EnsureIndexes(context.Background()); err != nil {
 		slog.Error("FATAL: 无法创建/验证数据库索引", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("数据库连接成功并已验证索引")
+	for name, store := range stores {
+		if store == db {
+			continue
+		}
+		if err := store.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("FATAL: 无法创建/验证数据库索引", "库", name, "error", err)
+			os.Exit(1)
+		}
+	}
+	slog.Info("数据库连接成功并已验证索引", "库", len(stores))
 
 	// --- 3. 创建核心服务实例 ---
-	// 使用全局 config.C
-	orchestrator, err := scanner.NewOrchestrator(config.C, db)
+	// 每个库一个扫描协调器
+	orchestrators, err := scanner.NewOrchestrators(config.Current(), stores)
 	if err != nil {
 		slog.Error("FATAL: 无法创建扫描与处理协调器", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("扫描器协调器创建成功")
 
+	// 回收站的后台清除任务：定期永久删除超过保留期的条目，修改配置后按新的保留期和间隔重启
+	purgers := trash.NewPurgers(stores)
+	if err := purgers.Restart(config.Current()); err != nil {
+		slog.Error("FATAL: 无法创建回收站管理器", "error", err)
+		os.Exit(1)
+	}
+	defer purgers.Stop()
+
 	// 将创建好的扫描器实例和配置实例注入到任务管理器中
-	taskManager := task.NewManager(orchestrator, config.C)
+	taskManager := task.NewManager(orchestrators, config.Current())
 	slog.Info("任务管理器创建成功")
 
 	// --- 4. 设置并启动HTTP服务器 ---
-	router := api.RegisterRoutes(taskManager, db)
+	router := api.RegisterRoutes(taskManager, db, stores, purgers)
 
 	server := &http.Server{
-		Addr:         config.C.Server.Port,
+		Addr:         config.Current().Server.Port,
 		Handler:      router,
-		ReadTimeout:  config.C.Server.Timeout, // 直接使用 time.Duration 类型
-		WriteTimeout: config.C.Server.Timeout, // 直接使用 time.Duration 类型
+		ReadTimeout:  config.Current().Server.Timeout, // 直接使用 time.Duration 类型
+		WriteTimeout: config.Current().Server.Timeout, // 直接使用 time.Duration 类型
 		IdleTimeout:  120 * time.Second,
 	}
 
-	slog.Info("HTTP服务器正在启动...", "地址", config.C.Server.Port)
+	slog.Info("HTTP服务器正在启动...", "地址", config.Current().Server.Port)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("无法启动HTTP服务器", "error", err)
 		os.Exit(1)
@@ -1,63 +1,121 @@
 package config
 
 import (
-	"github.com/spf13/viper"
+	"os"
+	"sync/atomic"
 	"time"
+
+	"github.com/spf13/viper"
 )
 
 type SeriesGroupRule struct {
-	Name    string `mapstructure:"name"`
-	Pattern string `mapstructure:"pattern"`
+	Name    string `mapstructure:"name" yaml:"name"`
+	Pattern string `mapstructure:"pattern" yaml:"pattern"`
+}
+
+// ClassifierRule 是分类链中的一个分类器，按配置顺序依次尝试，第一个给出系列名的分类器生效。
+type ClassifierRule struct {
+	// Type 取值 regex、parent-folder、exif-date、sidecar、unsorted。
+	Type string `mapstructure:"type" yaml:"type"`
+	// Patterns 用于 regex，为空时使用 filePatterns。
+	Patterns []string `mapstructure:"patterns" yaml:"patterns,omitempty"`
+	// Format 用于 exif-date，是系列名的 Go 时间格式，默认 "2006-01"。
+	Format string `mapstructure:"format" yaml:"format,omitempty"`
+	// Fields 用于 sidecar，依次尝试的元数据字段 (支持 "user.name" 形式的嵌套字段)。
+	Fields []string `mapstructure:"fields" yaml:"fields,omitempty"`
+	// Name 用于 unsorted，是兜底系列的名称，默认 "_unsorted"。
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
 }
 
 type ScannerConfig struct {
-	ScanPath          string            `mapstructure:"scanPath"`
-	StagingPath       string            `mapstructure:"stagingPath"`
-	FinalLibraryPath  string            `mapstructure:"finalLibraryPath"`
-	BackupPath        string            `mapstructure:"backupPath"`
-	QuarantinePath    string            `mapstructure:"quarantinePath"`
-	CorruptionLogPath string            `mapstructure:"corruptionLogPath"`
-	DuplicatesDir     string            `mapstructure:"duplicatesDir"`
-	WorkerCount       int               `mapstructure:"workerCount"`
-	BatchSize         int               `mapstructure:"batchSize"`
-	FilePatterns      []string          `mapstructure:"filePatterns"`
-	SeriesGroupRules  []SeriesGroupRule `mapstructure:"seriesGroupPatterns"`
+	ScanPath           string            `mapstructure:"scanPath" yaml:"scanPath"`
+	StagingPath        string            `mapstructure:"stagingPath" yaml:"stagingPath"`
+	FinalLibraryPath   string            `mapstructure:"finalLibraryPath" yaml:"finalLibraryPath"`
+	LibraryLayout      string            `mapstructure:"libraryLayout" yaml:"libraryLayout"`
+	BackupPath         string            `mapstructure:"backupPath" yaml:"backupPath"`
+	QuarantinePath     string            `mapstructure:"quarantinePath" yaml:"quarantinePath"`
+	TrashPath          string            `mapstructure:"trashPath" yaml:"trashPath"`
+	TrashRetention     time.Duration     `mapstructure:"trashRetention" yaml:"trashRetention"`
+	TrashPurgeInterval time.Duration     `mapstructure:"trashPurgeInterval" yaml:"trashPurgeInterval"`
+	CorruptionLogPath  string            `mapstructure:"corruptionLogPath" yaml:"corruptionLogPath"`
+	DuplicatesDir      string            `mapstructure:"duplicatesDir" yaml:"duplicatesDir"`
+	WorkerCount        int               `mapstructure:"workerCount" yaml:"workerCount"`
+	BatchSize          int               `mapstructure:"batchSize" yaml:"batchSize"`
+	ImageExtensions    []string          `mapstructure:"imageExtensions" yaml:"imageExtensions"`
+	VideoExtensions    []string          `mapstructure:"videoExtensions" yaml:"videoExtensions"`
+	FilePatterns       []string          `mapstructure:"filePatterns" yaml:"filePatterns"`
+	Classifiers        []ClassifierRule  `mapstructure:"classifiers" yaml:"classifiers"`
+	SeriesGroupRules   []SeriesGroupRule `mapstructure:"seriesGroupPatterns" yaml:"seriesGroupPatterns"`
 }
 
 type Config struct {
 	Server struct {
-		Port    string        `mapstructure:"port"`
-		Timeout time.Duration `mapstructure:"timeout"`
-	} `mapstructure:"server"`
+		Port    string        `mapstructure:"port" yaml:"port"`
+		Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	} `mapstructure:"server" yaml:"server"`
 
 	Database struct {
-		URI  string `mapstructure:"uri"`
-		Name string `mapstructure:"name"`
-	} `mapstructure:"database"`
+		URI  string `mapstructure:"uri" yaml:"uri"`
+		Name string `mapstructure:"name" yaml:"name"`
+	} `mapstructure:"database" yaml:"database"`
 
 	Logger struct {
-		Level  string `mapstructure:"level"`
-		Format string `mapstructure:"format"`
-		Path   string `mapstructure:"path"`
-	} `mapstructure:"logger"`
+		Level  string `mapstructure:"level" yaml:"level"`
+		Format string `mapstructure:"format" yaml:"format"`
+		Path   string `mapstructure:"path" yaml:"path"`
+	} `mapstructure:"logger" yaml:"logger"`
+
+	Auth struct {
+		// Enabled 为 false 时所有请求都被视为管理员，仅适用于本机单人使用；未设置时默认开启，应通过 AuthEnabled 读取。
+		Enabled      *bool         `mapstructure:"enabled" yaml:"enabled"`
+		SessionTTL   time.Duration `mapstructure:"sessionTTL" yaml:"sessionTTL"`
+		CookieSecure bool          `mapstructure:"cookieSecure" yaml:"cookieSecure"`
+	} `mapstructure:"auth" yaml:"auth"`
+
+	Scanner ScannerConfig `mapstructure:"scanner" yaml:"scanner"`
 
-	Scanner ScannerConfig `mapstructure:"scanner"`
+	// Libraries 为空时只有一个使用顶层 scanner 和 database 配置的 default 库。
+	Libraries []LibraryConfig `mapstructure:"libraries" yaml:"libraries,omitempty"`
 }
 
-var C *Config
+// current 是当前生效的配置。运行时修改配置会整体替换它，因此通过 Current 读取，不要修改拿到的 *Config。
+var current atomic.Pointer[Config]
 
-// LoadConfig 函数保持不变
+// Current 返回当前生效的配置，LoadConfig 之前为 nil。可以在多个 goroutine 中同时调用。
+func Current() *Config {
+	return current.Load()
+}
+
+// Set 用 c 整体替换当前生效的配置，之后不能再修改 c。
+func Set(c *Config) {
+	current.Store(c)
+}
+
+// LoadConfig 读取配置并填入默认值，不做校验，调用方应再调用 Current().Validate()。
+// path 可以是配置文件路径，也可以是包含 config.yaml 的目录。
+// 以 PICS_ 开头的环境变量会覆盖配置文件中的值，键中的 "." 换成 "_"，例如 PICS_SCANNER_SCANPATH。
 func LoadConfig(path string) (err error) {
 	v := viper.New()
-	v.AddConfigPath(path)
-	v.SetConfigName("config")
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		v.AddConfigPath(path)
+		v.SetConfigName("config")
+	} else {
+		v.SetConfigFile(path)
+	}
 	v.SetConfigType("yaml")
-	v.AutomaticEnv()
+	bindEnv(v)
 
 	if err = v.ReadInConfig(); err != nil {
 		return
 	}
 
-	err = v.Unmarshal(&C)
+	var c Config
+	if err = v.Unmarshal(&c); err != nil {
+		return
+	}
+	c.ApplyDefaults()
+	Set(&c)
+	File = v.ConfigFileUsed()
+	recordSources(v)
 	return
 }
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidate(t *testing.T) {
+	root := t.TempDir()
+	path := func(name string) string { return filepath.Join(root, name) }
+	for _, name := range []string{"scan", "photos-scan"} {
+		if err := os.Mkdir(path(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path("file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	valid := func() *Config {
+		c := &Config{}
+		c.Database.URI, c.Database.Name = "mongodb://localhost", "pics"
+		c.Scanner = ScannerConfig{
+			ScanPath:         path("scan"),
+			StagingPath:      path("staging"),
+			FinalLibraryPath: path("library"),
+			TrashPath:        path("trash"),
+			FilePatterns:     []string{`^(.*?)_\d+`},
+		}
+		c.ApplyDefaults()
+		return c
+	}
+	photos := func() LibraryConfig {
+		return LibraryConfig{Name: "photos", CollectionPrefix: "photos_", Scanner: ScannerConfig{
+			ScanPath:         path("photos-scan"),
+			StagingPath:      path("photos-staging"),
+			FinalLibraryPath: path("photos-library"),
+			TrashPath:        path("photos-trash"),
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// want 是错误信息中应包含的片段，为空时要求校验通过
+		want []string
+	}{
+		{name: "valid", modify: func(c *Config) {}},
+		{
+			name:   "required directories",
+			modify: func(c *Config) { c.Scanner.TrashPath, c.Scanner.StagingPath = "", "" },
+			want:   []string{"scanner.stagingPath 不能为空", "scanner.trashPath 不能为空"},
+		},
+		{
+			name:   "missing scan path and file as directory",
+			modify: func(c *Config) { c.Scanner.ScanPath, c.Scanner.BackupPath = path("missing"), path("file") },
+			want:   []string{"scanPath '" + path("missing") + "' 不存在", "backupPath '" + path("file") + "' 不是目录"},
+		},
+		{
+			name:   "nested directories",
+			modify: func(c *Config) { c.Scanner.StagingPath = path("scan/staging") },
+			want:   []string{"scanPath 与 stagingPath 相同或互相嵌套"},
+		},
+		{
+			name: "counts and durations",
+			modify: func(c *Config) {
+				c.Scanner.WorkerCount, c.Scanner.BatchSize, c.Scanner.TrashRetention = maxWorkerCount+1, -1, -1
+			},
+			want: []string{"workerCount 257 超出范围", "batchSize -1 超出范围", "trashRetention 不能为负数"},
+		},
+		{
+			name: "rules",
+			modify: func(c *Config) {
+				c.Scanner.LibraryLayout = "by-color"
+				c.Scanner.FilePatterns = []string{`^\d+$`, `(`}
+				c.Scanner.Classifiers = []ClassifierRule{{Type: "magic"}, {Type: "regex", Patterns: []string{`x`}}}
+				c.Scanner.SeriesGroupRules = []SeriesGroupRule{{Name: "vol", Pattern: `^(.*) \d+$`}}
+			},
+			want: []string{
+				"libraryLayout 无效",
+				"filePatterns[0]: '^\\d+$' 没有捕获组",
+				"filePatterns[1]: '(' 无法编译",
+				"classifiers[0].type 'magic' 无效",
+				"classifiers[1].patterns[0]: 'x' 没有捕获组",
+				"seriesGroupPatterns[0] 'vol' 缺少命名分组",
+			},
+		},
+		{
+			name:   "logger",
+			modify: func(c *Config) { c.Logger.Level, c.Logger.Format = "trace", "xml" },
+			want:   []string{"logger.level 'trace' 无效", "logger.format 'xml' 无效"},
+		},
+		{
+			name:   "libraries inherit the top-level scanner",
+			modify: func(c *Config) { c.Libraries = []LibraryConfig{{Name: "default"}, photos()} },
+		},
+		{
+			name: "libraries conflict",
+			modify: func(c *Config) {
+				dup := photos()
+				dup.Scanner.StagingPath = path("scan/photos")
+				bad := photos()
+				bad.Name, bad.CollectionPrefix, bad.Scanner.ImageExtensions = "Bad Name", "other_", []string{".png"}
+				bad.Scanner.ScanPath = ""
+				c.Libraries = []LibraryConfig{{Name: "default"}, photos(), dup, bad}
+			},
+			want: []string{
+				"libraries[2] 与 libraries[1] 的名称 'photos' 重复",
+				"libraries[2] 与 libraries[1] 使用了相同的数据库和集合前缀",
+				"libraries[3].name 'Bad Name' 无效",
+				"libraries[3].scanner 不能单独设置 imageExtensions/videoExtensions",
+				"libraries[0].scanner.scanPath 与 libraries[2].scanner.stagingPath 相同或互相嵌套",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want errors containing %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error does not contain %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeNode(t *testing.T) {
+	tests := []struct {
+		name    string
+		dst     string
+		src     string
+		sources map[string]string
+		want    string
+	}{
+		{
+			name: "values are replaced and comments, quotes and durations kept",
+			dst: `# 服务器
+server:
+  port: ":8080" # 端口
+  timeout: 168h
+`,
+			src: `server: {port: ":9090", timeout: 168h0m0s}`,
+			want: `# 服务器
+server:
+  port: ":9090" # 端口
+  timeout: 168h
+`,
+		},
+		{
+			name: "unknown top-level keys are kept and new keys appended",
+			dst: `extra: 1
+logger:
+  level: info
+`,
+			src: `logger: {level: debug, format: json}`,
+			want: `extra: 1
+logger:
+  level: debug
+  format: json
+`,
+		},
+		{
+			name: "stale keys of sequence items are pruned",
+			dst: `classifiers:
+  - type: sidecar
+    fields: [user.name]
+  - type: unsorted
+`,
+			src: `classifiers: [{type: regex}]`,
+			want: `classifiers:
+  - type: regex
+`,
+		},
+		{
+			name: "appended strings follow the existing quote style",
+			dst: `filePatterns:
+  - '^(.*)_\d+$'
+`,
+			src: `filePatterns: ['^(.*)_\d+$', "^(.*)-\\d+$"]`,
+			want: `filePatterns:
+  - '^(.*)_\d+$'
+  - '^(.*)-\d+$'
+`,
+		},
+		{
+			name: "values from the environment are not written",
+			dst: `database:
+  uri: mongodb://file
+  name: pics
+`,
+			src:     `database: {uri: "mongodb://env", name: other}`,
+			sources: map[string]string{"database.uri": SourceEnv, "database.name": SourceFile},
+			want: `database:
+  uri: mongodb://file
+  name: other
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := sources
+			sources = tt.sources
+			defer func() { sources = saved }()
+
+			var dst, src yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.dst), &dst); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.src), &src); err != nil {
+				t.Fatal(err)
+			}
+			mergeNode(dst.Content[0], src.Content[0], "", false)
+
+			var buf bytes.Buffer
+			enc := yaml.NewEncoder(&buf)
+			enc.SetIndent(2)
+			if err := enc.Encode(&dst); err != nil {
+				t.Fatal(err)
+			}
+			enc.Close()
+			if got := buf.String(); got != tt.want {
+				t.Errorf("merged:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
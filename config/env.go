@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 是覆盖配置项的环境变量前缀，例如 scanner.scanPath 对应 PICS_SCANNER_SCANPATH。
+const EnvPrefix = "PICS"
+
+// 配置值的来源。
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Setting 是一个配置项的最终值及其来源。
+type Setting struct {
+	// Key 是配置文件中的键，例如 "scanner.scanPath"。
+	Key string
+	// Env 是可以覆盖该项的环境变量名，为空表示只能在配置文件中设置 (例如规则列表)。
+	Env    string
+	Value  any
+	Source string
+}
+
+// sources 记录 LoadConfig 时每个配置项的来源。
+var sources map[string]string
+
+// EnvName 返回配置项对应的环境变量名。
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// bindEnv 为每个可以用字符串表示的配置项绑定环境变量。
+// viper 的 AutomaticEnv 只对配置文件中已出现的键生效，显式绑定后配置文件中没写的键也能通过环境变量设置。
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	walk(reflect.ValueOf(Config{}), "", func(key string, _ reflect.Value) {
+		if envSupported(key) {
+			v.BindEnv(key)
+		}
+	})
+}
+
+// recordSources 记录每个配置项来自环境变量、配置文件还是默认值。
+func recordSources(v *viper.Viper) {
+	sources = make(map[string]string)
+	walk(reflect.ValueOf(Config{}), "", func(key string, _ reflect.Value) {
+		switch {
+		case envSupported(key) && envSet(key):
+			sources[key] = SourceEnv
+		case v.InConfig(key):
+			sources[key] = SourceFile
+		default:
+			sources[key] = SourceDefault
+		}
+	})
+}
+
+// Effective 按配置文件中的顺序返回当前配置 (Current) 的每一项及其来源。
+func Effective() []Setting {
+	var settings []Setting
+	c := Current()
+	if c == nil {
+		return settings
+	}
+	walk(reflect.ValueOf(c).Elem(), "", func(key string, value reflect.Value) {
+		if value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		s := Setting{Key: key, Value: value.Interface(), Source: sources[key]}
+		if s.Source == "" {
+			s.Source = SourceDefault
+		}
+		if envSupported(key) {
+			s.Env = EnvName(key)
+		}
+		settings = append(settings, s)
+	})
+	return settings
+}
+
+func envSet(key string) bool {
+	_, ok := os.LookupEnv(EnvName(key))
+	return ok
+}
+
+// envSupported 判断配置项能否用环境变量表示：结构体列表 (分类链、聚合规则) 只能写在配置文件中。
+func envSupported(key string) bool {
+	t := fieldType(reflect.TypeOf(Config{}), strings.Split(key, "."))
+	return t != nil && !(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct)
+}
+
+func fieldType(t reflect.Type, path []string) reflect.Type {
+	for _, name := range path {
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			if tagName(t.Field(i)) == name {
+				t, found = t.Field(i).Type, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return t
+}
+
+// walk 按 mapstructure 标签遍历配置结构体，对每个叶子配置项调用 fn。
+func walk(v reflect.Value, prefix string, fn func(key string, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := tagName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}) {
+			walk(field, key, fn)
+			continue
+		}
+		fn(key, field)
+	}
+}
+
+func tagName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+	return name
+}
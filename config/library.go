@@ -0,0 +1,71 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+)
+
+// DefaultLibrary 是没有配置 libraries 时唯一的库的名称，它直接使用顶层的 scanner 和 database 配置。
+const DefaultLibrary = "default"
+
+// libraryNamePattern 限制库名只能使用可以直接放进 URL 和目录名的字符。
+var libraryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// LibraryConfig 是一个独立管理的库 (例如插画、照片、漫画)，拥有自己的路径、规则和数据库集合。
+type LibraryConfig struct {
+	Name string `mapstructure:"name" yaml:"name"`
+	// Database 是该库使用的 Mongo 数据库名，为空时使用 database.name。
+	Database string `mapstructure:"database" yaml:"database,omitempty"`
+	// CollectionPrefix 是该库集合名的前缀，多个库共用一个数据库时用来区分，例如 "photos_"。
+	CollectionPrefix string `mapstructure:"collectionPrefix" yaml:"collectionPrefix,omitempty"`
+	// Scanner 中未设置的项沿用顶层 scanner 的值。
+	Scanner ScannerConfig `mapstructure:"scanner" yaml:"scanner"`
+}
+
+// LibraryConfigs 返回所有库的最终配置。
+// 没有配置 libraries 时返回名为 default 的单个库；否则每个库的 scanner 中未设置的项由顶层 scanner 补齐。
+func (c *Config) LibraryConfigs() []LibraryConfig {
+	if len(c.Libraries) == 0 {
+		return []LibraryConfig{{Name: DefaultLibrary, Scanner: c.Scanner}}
+	}
+	libs := make([]LibraryConfig, 0, len(c.Libraries))
+	for _, lib := range c.Libraries {
+		inherit(&lib.Scanner, &c.Scanner)
+		libs = append(libs, lib)
+	}
+	return libs
+}
+
+// Library 按名称返回库的最终配置。
+func (c *Config) Library(name string) (LibraryConfig, bool) {
+	for _, lib := range c.LibraryConfigs() {
+		if lib.Name == name {
+			return lib, true
+		}
+	}
+	return LibraryConfig{}, false
+}
+
+// DefaultLibraryConfig 返回第一个库，不带库名的旧 API 路由和 CLI 默认操作这个库。
+func (c *Config) DefaultLibraryConfig() LibraryConfig {
+	return c.LibraryConfigs()[0]
+}
+
+// LogDir 返回库的模块日志目录。配置了多个库时每个库使用 logger.path 下以库名命名的子目录，避免日志互相覆盖。
+func (c *Config) LogDir(library string) string {
+	if len(c.Libraries) == 0 {
+		return c.Logger.Path
+	}
+	return filepath.Join(c.Logger.Path, library)
+}
+
+// inherit 用 base 中的值填充 dst 中未设置 (零值) 的字段。
+func inherit(dst, base *ScannerConfig) {
+	d, b := reflect.ValueOf(dst).Elem(), reflect.ValueOf(base).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		if d.Field(i).IsZero() {
+			d.Field(i).Set(b.Field(i))
+		}
+	}
+}
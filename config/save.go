@@ -0,0 +1,167 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File 是 LoadConfig 实际读取的配置文件路径，Save 默认写回这个文件。
+var File string
+
+// backupSuffix 是 Save 写入前保存旧配置文件时使用的后缀。
+const backupSuffix = ".bak"
+
+// Save 把 c 写入 path。
+// 已有的配置文件会被解析并按键合并，保留注释、键的顺序和 Config 中没有的键；
+// 写入前把旧文件复制为 path.bak，新内容先写入同目录的临时文件再重命名，避免写到一半时留下损坏的配置。
+func Save(path string, c *Config) error {
+	if path == "" {
+		path = "config.yaml"
+	}
+
+	var updated yaml.Node
+	if err := updated.Encode(c); err != nil {
+		return fmt.Errorf("序列化配置失败: %w", err)
+	}
+
+	old, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&updated}}
+	if len(old) > 0 {
+		var existing yaml.Node
+		if err := yaml.Unmarshal(old, &existing); err == nil && len(existing.Content) > 0 {
+			mergeNode(existing.Content[0], &updated, "", false)
+			doc = &existing
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("序列化配置失败: %w", err)
+	}
+	enc.Close()
+
+	if len(old) > 0 {
+		if err := os.WriteFile(path+backupSuffix, old, 0644); err != nil {
+			return fmt.Errorf("备份配置文件失败: %w", err)
+		}
+	}
+	return writeAtomic(path, buf.Bytes())
+}
+
+// mergeNode 把 src 中的值写入 dst，尽量保留 dst 原有的注释、引号和行内列表风格：
+// 映射按键递归合并，序列按位置逐项合并，其余情况整体替换。
+// prune 为 false 时保留 dst 中多出的键 (顶层配置中手写的未知键)；序列中的映射项使用 prune，
+// 否则例如把一个 sidecar 分类器改成 regex 后，旧的 fields 仍会留在文件中。
+// 来自环境变量的配置项不写入文件，因为下次启动时环境变量仍会覆盖它。
+func mergeNode(dst, src *yaml.Node, key string, prune bool) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		content := dst.Content
+		if prune {
+			content = nil
+			for i := 0; i+1 < len(dst.Content); i += 2 {
+				if mappingValue(src, dst.Content[i].Value) != nil {
+					content = append(content, dst.Content[i], dst.Content[i+1])
+				}
+			}
+		}
+		dst.Content = content
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			name, value := src.Content[i], src.Content[i+1]
+			child := name.Value
+			if key != "" {
+				child = key + "." + name.Value
+			}
+			if sources[child] == SourceEnv {
+				continue
+			}
+			if existing := mappingValue(dst, name.Value); existing != nil {
+				mergeNode(existing, value, child, prune)
+				continue
+			}
+			dst.Content = append(dst.Content, name, value)
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		if len(dst.Content) > len(src.Content) {
+			dst.Content = dst.Content[:len(src.Content)]
+		}
+		for i, item := range src.Content {
+			if i < len(dst.Content) {
+				mergeNode(dst.Content[i], item, "", true)
+				continue
+			}
+			// 新增的字符串项沿用已有项的引号风格
+			if len(dst.Content) > 0 && item.Kind == yaml.ScalarNode && item.Tag == "!!str" {
+				item.Style = dst.Content[0].Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+			}
+			dst.Content = append(dst.Content, item)
+		}
+	default:
+		// 未改动的时长保持原来的写法 (例如 168h 而不是 168h0m0s)
+		if dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && sameDuration(dst.Value, src.Value) {
+			return
+		}
+		head, line, foot, style := dst.HeadComment, dst.LineComment, dst.FootComment, dst.Style
+		quoted := dst.Kind == yaml.ScalarNode && style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		if quoted && dst.Kind == yaml.ScalarNode && dst.Tag == "!!str" {
+			dst.Style = style
+		}
+	}
+}
+
+func sameDuration(a, b string) bool {
+	da, errA := time.ParseDuration(a)
+	db, errB := time.ParseDuration(b)
+	return errA == nil && errB == nil && da == db
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// writeAtomic 先写入同目录下的临时文件并刷盘，再重命名覆盖目标文件。
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时配置文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // 重命名成功后临时文件已不存在，这里只在失败时生效
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入临时配置文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入临时配置文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入临时配置文件失败: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return fmt.Errorf("设置配置文件权限失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("替换配置文件失败: %w", err)
+	}
+	return nil
+}
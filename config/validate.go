@@ -0,0 +1,309 @@
+package config
+
+import (
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/layout"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxWorkerCount = 256
+	maxBatchSize   = 10000
+)
+
+// classifierTypes 是 scanner.classifiers[].type 的可选值，与 scanner 包中的分类器类型一致。
+var classifierTypes = []string{"regex", "parent-folder", "exif-date", "sidecar", "unsorted"}
+
+// ApplyDefaults 为未设置的字段填入默认值。
+func (c *Config) ApplyDefaults() {
+	if c.Server.Port == "" {
+		c.Server.Port = ":8080"
+	}
+	if c.Server.Timeout == 0 {
+		c.Server.Timeout = 30 * time.Second
+	}
+	if c.Logger.Level == "" {
+		c.Logger.Level = "info"
+	}
+	if c.Logger.Format == "" {
+		c.Logger.Format = "text"
+	}
+	if c.Logger.Path == "" {
+		c.Logger.Path = "./logs"
+	}
+	if c.Auth.Enabled == nil {
+		enabled := true
+		c.Auth.Enabled = &enabled
+	}
+	if c.Auth.SessionTTL == 0 {
+		c.Auth.SessionTTL = 168 * time.Hour
+	}
+	if c.Scanner.LibraryLayout == "" {
+		c.Scanner.LibraryLayout = layout.FirstLetter
+	}
+	if c.Scanner.TrashPurgeInterval == 0 {
+		c.Scanner.TrashPurgeInterval = time.Hour
+	}
+	if c.Scanner.DuplicatesDir == "" {
+		c.Scanner.DuplicatesDir = "_duplicates"
+	}
+	if c.Scanner.BatchSize == 0 {
+		c.Scanner.BatchSize = 100
+	}
+}
+
+// AuthEnabled 判断是否启用认证。没有 auth 块的旧配置文件也默认启用，只有显式设置 enabled: false 才会关闭。
+func (c *Config) AuthEnabled() bool {
+	return c.Auth.Enabled == nil || *c.Auth.Enabled
+}
+
+// Validate 检查配置是否可用，返回所有发现的问题 (用 errors.Join 合并)，没有问题时返回 nil。
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port == "" {
+		add("server.port 不能为空")
+	}
+	if c.Database.URI == "" {
+		add("database.uri 不能为空")
+	}
+	if c.Database.Name == "" {
+		add("database.name 不能为空")
+	}
+	switch c.Logger.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		add("logger.level '%s' 无效，可选 debug, info, warn, error", c.Logger.Level)
+	}
+	if c.Logger.Format != "text" && c.Logger.Format != "json" {
+		add("logger.format '%s' 无效，可选 text, json", c.Logger.Format)
+	}
+
+	errs = append(errs, c.validateLibraries()...)
+	return errors.Join(errs...)
+}
+
+// validateLibraries 校验每个库的最终配置，以及库与库之间的名称、目录和数据库集合是否冲突。
+func (c *Config) validateLibraries() []error {
+	var errs []error
+	libs := c.LibraryConfigs()
+	prefix := func(i int) string {
+		if len(c.Libraries) == 0 {
+			return "scanner"
+		}
+		return fmt.Sprintf("libraries[%d].scanner", i)
+	}
+	for i, lib := range libs {
+		for _, err := range lib.Scanner.validate() {
+			errs = append(errs, fmt.Errorf("%s.%w", prefix(i), err))
+		}
+	}
+	if len(c.Libraries) == 0 {
+		return errs
+	}
+
+	names := make(map[string]int)
+	stores := make(map[string]int)
+	for i, lib := range c.Libraries {
+		if !libraryNamePattern.MatchString(lib.Name) {
+			errs = append(errs, fmt.Errorf("libraries[%d].name '%s' 无效，只能使用小写字母、数字、- 和 _", i, lib.Name))
+		}
+		if j, ok := names[lib.Name]; ok {
+			errs = append(errs, fmt.Errorf("libraries[%d] 与 libraries[%d] 的名称 '%s' 重复", i, j, lib.Name))
+		}
+		names[lib.Name] = i
+		// 扩展名允许列表是进程级的设置，所有库共用顶层 scanner 中的值
+		if len(lib.Scanner.ImageExtensions) > 0 || len(lib.Scanner.VideoExtensions) > 0 {
+			errs = append(errs, fmt.Errorf("libraries[%d].scanner 不能单独设置 imageExtensions/videoExtensions，请在顶层 scanner 中设置", i))
+		}
+		database := lib.Database
+		if database == "" {
+			database = c.Database.Name
+		}
+		key := database + "/" + lib.CollectionPrefix
+		if j, ok := stores[key]; ok {
+			errs = append(errs, fmt.Errorf("libraries[%d] 与 libraries[%d] 使用了相同的数据库和集合前缀 (%s)，请设置不同的 database 或 collectionPrefix", i, j, key))
+		}
+		stores[key] = i
+	}
+
+	// 不同库的目录同样不能相同或嵌套
+	for i := 0; i < len(libs); i++ {
+		for j := i + 1; j < len(libs); j++ {
+			for _, a := range libs[i].Scanner.absDirs() {
+				for _, b := range libs[j].Scanner.absDirs() {
+					if overlaps(a.path, b.path) {
+						errs = append(errs, fmt.Errorf("%s.%s 与 %s.%s 相同或互相嵌套: %s", prefix(i), a.key, prefix(j), b.key, a.path))
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
+
+type dir struct {
+	key, path string
+}
+
+// requiredDirs 是扫描必需的目录，其余目录可以不设置。
+// 所有删除操作 (冗余副本、隔离区清除等) 都经过回收站，因此 trashPath 也是必需的。
+var requiredDirs = []string{"scanPath", "stagingPath", "finalLibraryPath", "trashPath"}
+
+// dirFields 返回配置中的各个目录 (原样，可能为空)。
+func (s *ScannerConfig) dirFields() []dir {
+	return []dir{
+		{"scanPath", s.ScanPath},
+		{"stagingPath", s.StagingPath},
+		{"finalLibraryPath", s.FinalLibraryPath},
+		{"backupPath", s.BackupPath},
+		{"quarantinePath", s.QuarantinePath},
+		{"trashPath", s.TrashPath},
+	}
+}
+
+// absDirs 返回已设置的各个目录的绝对路径。
+func (s *ScannerConfig) absDirs() []dir {
+	var out []dir
+	for _, d := range s.dirFields() {
+		if d.path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(d.path); err == nil {
+			out = append(out, dir{d.key, abs})
+		}
+	}
+	return out
+}
+
+// validate 校验单个库的扫描配置，返回的错误不带 "scanner." 前缀，由调用方加上。
+func (s *ScannerConfig) validate() []error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// --- 路径 ---
+	for _, d := range s.dirFields() {
+		if d.path == "" {
+			if contains(requiredDirs, d.key) {
+				add("%s 不能为空", d.key)
+			}
+			continue
+		}
+		// 其余目录会在扫描时自动创建，只有扫描入口必须事先存在
+		info, err := os.Stat(d.path)
+		switch {
+		case err == nil && !info.IsDir():
+			add("%s '%s' 不是目录", d.key, d.path)
+		case err != nil && d.key == "scanPath":
+			add("scanPath '%s' 不存在或无法访问: %v", d.path, err)
+		}
+	}
+	// 各目录之间不能相同或互相嵌套，否则扫描会处理到自己移动出去的文件，清空中转站时也会误删其他目录
+	dirs := s.absDirs()
+	for i := 0; i < len(dirs); i++ {
+		for j := i + 1; j < len(dirs); j++ {
+			if overlaps(dirs[i].path, dirs[j].path) {
+				add("%s 与 %s 相同或互相嵌套: %s, %s", dirs[i].key, dirs[j].key, dirs[i].path, dirs[j].path)
+			}
+		}
+	}
+	if strings.ContainsAny(s.DuplicatesDir, `/\`) {
+		add("duplicatesDir '%s' 应为目录名而不是路径", s.DuplicatesDir)
+	}
+
+	// --- 数量 ---
+	if s.WorkerCount < 0 || s.WorkerCount > maxWorkerCount {
+		add("workerCount %d 超出范围 (0-%d，0 表示使用CPU核心数)", s.WorkerCount, maxWorkerCount)
+	}
+	if s.BatchSize < 0 || s.BatchSize > maxBatchSize {
+		add("batchSize %d 超出范围 (0-%d)", s.BatchSize, maxBatchSize)
+	}
+	if s.TrashRetention < 0 {
+		add("trashRetention 不能为负数")
+	}
+	if s.TrashPurgeInterval < 0 {
+		add("trashPurgeInterval 不能为负数")
+	}
+
+	// --- 格式与布局 ---
+	if err := formats.CheckExtensions(s.ImageExtensions, s.VideoExtensions); err != nil {
+		add("imageExtensions/videoExtensions 无效: %v", err)
+	}
+	if _, err := layout.New(s.LibraryLayout); err != nil {
+		add("libraryLayout 无效: %v", err)
+	}
+
+	// --- 规则 ---
+	for i, p := range s.FilePatterns {
+		if err := checkCapturePattern(p); err != nil {
+			add("filePatterns[%d]: %v", i, err)
+		}
+	}
+	for i, rule := range s.Classifiers {
+		if !contains(classifierTypes, rule.Type) {
+			add("classifiers[%d].type '%s' 无效，可选 %s", i, rule.Type, strings.Join(classifierTypes, ", "))
+			continue
+		}
+		for j, p := range rule.Patterns {
+			if err := checkCapturePattern(p); err != nil {
+				add("classifiers[%d].patterns[%d]: %v", i, j, err)
+			}
+		}
+		if rule.Type == "regex" && len(rule.Patterns) == 0 && len(s.FilePatterns) == 0 {
+			add("classifiers[%d] 是 regex 分类器，但既没有 patterns 也没有 filePatterns", i)
+		}
+	}
+	for i, rule := range s.SeriesGroupRules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			add("seriesGroupPatterns[%d] '%s' 无法编译: %v", i, rule.Name, err)
+			continue
+		}
+		if !contains(re.SubexpNames(), "group") {
+			add("seriesGroupPatterns[%d] '%s' 缺少命名分组 (?P<group>...)", i, rule.Name)
+		}
+	}
+	return errs
+}
+
+// checkCapturePattern 检查从文件名提取系列名的正则：必须能编译，并且至少有一个捕获组 (第一个捕获组即系列名)。
+func checkCapturePattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("'%s' 无法编译: %w", pattern, err)
+	}
+	if re.NumSubexp() == 0 {
+		return fmt.Errorf("'%s' 没有捕获组，无法提取系列名", pattern)
+	}
+	return nil
+}
+
+// overlaps 判断两个绝对路径是否相同或其中一个位于另一个之内。
+func overlaps(a, b string) bool {
+	return a == b || within(a, b) || within(b, a)
+}
+
+func within(child, parent string) bool {
+	rel, err := filepath.Rel(parent, child)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,398 @@
+// 文件: internal/api/auth.go
+package api
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/internal/auth"
+	"PICs_Manager/internal/models"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	sessionCookieName = "pics_session"
+	defaultSessionTTL = 7 * 24 * time.Hour
+
+	// 同一用户名和 IP 在窗口内最多失败 maxLoginFailures 次，同一 IP 对所有用户名合计最多 maxLoginIPFailures 次
+	maxLoginFailures   = 5
+	maxLoginIPFailures = 20
+	loginFailureWindow = 15 * time.Minute
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// localAdmin 是未启用认证时注入到每个请求中的虚拟用户
+var localAdmin = &models.User{Username: "local", Role: models.RoleAdmin}
+
+// currentUser 返回认证中间件放入 context 的用户。
+// 只应在 authMiddleware 之后的处理器中调用。
+func currentUser(r *http.Request) *models.User {
+	if user, ok := r.Context().Value(userContextKey).(*models.User); ok {
+		return user
+	}
+	return localAdmin
+}
+
+// --- 中间件 ---
+
+// authMiddleware 依次尝试 "Authorization: Bearer <token>" 头 (脚本) 和会话 Cookie (Web UI)，
+// 成功后把用户放入 context，否则返回 401。
+func (h *APIHandlers) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Current().AuthEnabled() {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, localAdmin)))
+			return
+		}
+
+		user, err := h.resolveUser(r)
+		if err != nil {
+			slog.Error("认证时查询数据库失败", "error", err)
+			respondError(w, http.StatusInternalServerError, "认证失败")
+			return
+		}
+		if user == nil {
+			respondError(w, http.StatusUnauthorized, "未登录或凭据已失效")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	})
+}
+
+func (h *APIHandlers) resolveUser(r *http.Request) (*models.User, error) {
+	ctx := r.Context()
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token, err := h.db.APITokens().GetByTokenHash(ctx, auth.HashToken(strings.TrimPrefix(header, "Bearer ")))
+		if err != nil || token == nil {
+			return nil, err
+		}
+		if err := h.db.APITokens().TouchLastUsed(ctx, token.ID); err != nil {
+			slog.Warn("更新令牌最后使用时间失败", "tokenId", token.ID.Hex(), "error", err)
+		}
+		return h.db.Users().GetByID(ctx, token.UserID)
+	}
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, nil
+	}
+	session, err := h.db.Sessions().GetByTokenHash(ctx, auth.HashToken(cookie.Value))
+	if err != nil || session == nil {
+		return nil, err
+	}
+	return h.db.Users().GetByID(ctx, session.UserID)
+}
+
+// requireRole 限制只有指定角色的用户才能访问，必须挂在 authMiddleware 之后。
+func requireRole(role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if currentUser(r).Role != role {
+				respondError(w, http.StatusForbidden, "权限不足")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// --- 登录会话处理器 ---
+
+func (h *APIHandlers) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	ip := clientIP(r)
+	userKey := strings.ToLower(payload.Username) + "@" + ip
+	if wait := max(h.loginFailures.Blocked(userKey), h.loginIPFailures.Blocked(ip)); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondError(w, http.StatusTooManyRequests, "登录失败次数过多，请稍后再试")
+		return
+	}
+	user, err := h.db.Users().GetByUsername(r.Context(), payload.Username)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "查询用户失败: "+err.Error())
+		return
+	}
+	if err := auth.CheckPassword(user, payload.Password); err != nil {
+		h.loginFailures.Fail(userKey)
+		h.loginIPFailures.Fail(ip)
+		slog.Warn("登录失败", "username", payload.Username, "ip", ip)
+		respondError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	h.loginFailures.Reset(userKey)
+
+	plain, hash, _, err := auth.NewToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ttl := config.Current().Auth.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	session := &models.Session{UserID: user.ID, TokenHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	if err := h.db.Sessions().Create(r.Context(), session); err != nil {
+		respondError(w, http.StatusInternalServerError, "创建会话失败: "+err.Error())
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    plain,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   config.Current().Auth.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	respondJSON(w, http.StatusOK, user)
+}
+
+// clientIP 返回请求来源的 IP，不信任可被客户端伪造的转发头。
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (h *APIHandlers) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := h.db.Sessions().DeleteByTokenHash(r.Context(), auth.HashToken(cookie.Value)); err != nil {
+			respondError(w, http.StatusInternalServerError, "注销会话失败: "+err.Error())
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   config.Current().Auth.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIHandlers) HandleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, currentUser(r))
+}
+
+// --- 个人 API 令牌处理器 ---
+
+func (h *APIHandlers) HandleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.db.APITokens().ListByUserID(r.Context(), currentUser(r).ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取令牌列表失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// HandleCreateAPIToken 创建一个新的个人令牌。明文令牌只在此响应中出现一次。
+func (h *APIHandlers) HandleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Name == "" {
+		respondError(w, http.StatusBadRequest, "缺少 'name' 字段")
+		return
+	}
+	plain, hash, prefix, err := auth.NewToken()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token := &models.APIToken{UserID: currentUser(r).ID, Name: payload.Name, Prefix: prefix, TokenHash: hash}
+	if err := h.db.APITokens().Create(r.Context(), token); err != nil {
+		respondError(w, http.StatusInternalServerError, "创建令牌失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"token": plain,
+		"info":  token,
+	})
+}
+
+func (h *APIHandlers) HandleDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的令牌ID")
+		return
+	}
+	if err := h.db.APITokens().Delete(r.Context(), currentUser(r).ID, tokenID); err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- 用户管理处理器 (仅管理员) ---
+
+type userPayload struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Role     models.Role `json:"role"`
+}
+
+func (h *APIHandlers) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.db.Users().List(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户列表失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, users)
+}
+
+func (h *APIHandlers) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var payload userPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Username == "" {
+		respondError(w, http.StatusBadRequest, "缺少 'username' 字段")
+		return
+	}
+	if payload.Role == "" {
+		payload.Role = models.RoleViewer
+	}
+	if !auth.ValidRole(payload.Role) {
+		respondError(w, http.StatusBadRequest, "无效的角色: "+string(payload.Role))
+		return
+	}
+	passwordHash, err := auth.HashPassword(payload.Password)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user := &models.User{Username: payload.Username, PasswordHash: passwordHash, Role: payload.Role}
+	if err := h.db.Users().Create(r.Context(), user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(w, http.StatusConflict, "用户名已存在")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "创建用户失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// HandleUpdateUser 修改用户的角色和/或密码。修改密码会使该用户的所有会话失效。
+func (h *APIHandlers) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+	var payload userPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Role != "" {
+		if !auth.ValidRole(payload.Role) {
+			respondError(w, http.StatusBadRequest, "无效的角色: "+string(payload.Role))
+			return
+		}
+		if user.Role == models.RoleAdmin && payload.Role != models.RoleAdmin && !h.hasOtherAdmin(w, r) {
+			return
+		}
+		user.Role = payload.Role
+	}
+	if payload.Password != "" {
+		passwordHash, err := auth.HashPassword(payload.Password)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		user.PasswordHash = passwordHash
+	}
+	if err := h.db.Users().Update(r.Context(), user); err != nil {
+		respondError(w, http.StatusInternalServerError, "更新用户失败: "+err.Error())
+		return
+	}
+	if payload.Password != "" {
+		if err := h.db.Sessions().DeleteByUserID(r.Context(), user.ID); err != nil {
+			slog.Warn("清理用户会话失败", "userId", user.ID.Hex(), "error", err)
+		}
+	}
+	respondJSON(w, http.StatusOK, user)
+}
+
+// HandleDeleteUser 删除用户及其会话和 API 令牌。
+func (h *APIHandlers) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.loadUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role == models.RoleAdmin && !h.hasOtherAdmin(w, r) {
+		return
+	}
+	ctx := r.Context()
+	if err := h.db.Users().Delete(ctx, user.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "删除用户失败: "+err.Error())
+		return
+	}
+	if err := h.db.Sessions().DeleteByUserID(ctx, user.ID); err != nil {
+		slog.Warn("清理用户会话失败", "userId", user.ID.Hex(), "error", err)
+	}
+	if err := h.db.APITokens().DeleteByUserID(ctx, user.ID); err != nil {
+		slog.Warn("清理用户令牌失败", "userId", user.ID.Hex(), "error", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasOtherAdmin 确保操作后系统中至少还剩一个管理员，否则写入 409 并返回 false。
+func (h *APIHandlers) hasOtherAdmin(w http.ResponseWriter, r *http.Request) bool {
+	count, err := h.db.Users().CountByRole(r.Context(), models.RoleAdmin)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "统计管理员数量失败: "+err.Error())
+		return false
+	}
+	if count <= 1 {
+		respondError(w, http.StatusConflict, "不能移除最后一个管理员")
+		return false
+	}
+	return true
+}
+
+func (h *APIHandlers) loadUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的用户ID")
+		return nil, false
+	}
+	user, err := h.db.Users().GetByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取用户失败: "+err.Error())
+		return nil, false
+	}
+	if user == nil {
+		respondError(w, http.StatusNotFound, "用户不存在")
+		return nil, false
+	}
+	return user, true
+}
@@ -3,35 +3,62 @@ package api
 
 import (
 	"PICs_Manager/config" // [修正] 引入您项目根目录下的config包
+	"PICs_Manager/internal/auth"
 	"PICs_Manager/internal/models"
 	"PICs_Manager/internal/task"
+	"PICs_Manager/pkg/archive"
 	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/formats"
 	"PICs_Manager/pkg/hasher"
+	"PICs_Manager/pkg/imagemeta"
+	"PICs_Manager/pkg/logger"
+	"PICs_Manager/pkg/scanner"
+	"PICs_Manager/pkg/thumbnailer"
+	"PICs_Manager/pkg/trash"
+	"PICs_Manager/pkg/videometa"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"gopkg.in/yaml.v3" // [新增] 引入YAML库来保存配置
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // APIHandlers 持有所有依赖
 type APIHandlers struct {
 	taskManager *task.Manager
-	db          database.Store
-	// [修正] 移除 config 字段，我们将使用全局的 config.C
+	// db 用于用户、会话和 API 令牌；库的内容通过 stores 中对应库的 Store 访问
+	db     database.Store
+	stores map[string]database.Store // 按库名索引
+	// purgers 是回收站的后台清除任务，修改配置后按新的保留期重启
+	purgers *trash.Purgers
+	// loginFailures 和 loginIPFailures 分别按 "用户名@IP" 和 IP 限制登录失败次数
+	loginFailures   *auth.Limiter
+	loginIPFailures *auth.Limiter
+	// [修正] 移除 config 字段，我们将使用当前生效的配置 config.Current()
 }
 
 // NewAPIHandlers 创建一个新的API处理器实例
 // [修正] 移除 config 参数
-func NewAPIHandlers(tm *task.Manager, db database.Store) *APIHandlers {
+func NewAPIHandlers(tm *task.Manager, db database.Store, stores map[string]database.Store, purgers *trash.Purgers) *APIHandlers {
 	return &APIHandlers{
 		taskManager: tm,
 		db:          db,
+		stores:      stores,
+		purgers:     purgers,
+
+		loginFailures:   auth.NewLimiter(maxLoginFailures, loginFailureWindow),
+		loginIPFailures: auth.NewLimiter(maxLoginIPFailures, loginFailureWindow),
 	}
 }
 
@@ -69,7 +96,7 @@ func (h *APIHandlers) HandleStartScanTask(w http.ResponseWriter, r *http.Request
 		respondError(w, http.StatusBadRequest, "缺少 'path' 字段")
 		return
 	}
-	taskID, err := h.taskManager.StartNewScanTask(payload.Path)
+	taskID, err := h.taskManager.StartNewScanTask(h.library(r).config.Name, payload.Path)
 	if err != nil {
 		respondError(w, http.StatusConflict, err.Error())
 		return
@@ -98,7 +125,19 @@ func (h *APIHandlers) HandleListSeries(w http.ResponseWriter, r *http.Request) {
 	if limit <= 0 {
 		limit = 20
 	}
-	series, total, err := h.db.Series().List(r.Context(), page, limit)
+	stateQuery, err := parseSeriesStateFilters(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var series []models.Series
+	var total int64
+	if stateQuery != nil {
+		stateQuery.UserID = currentUser(r).ID
+		series, total, err = h.store(r).QuerySeries(r.Context(), stateQuery, page, limit)
+	} else {
+		series, total, err = h.store(r).Series().List(r.Context(), page, limit)
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "无法获取系列列表: "+err.Error())
 		return
@@ -114,13 +153,55 @@ func (h *APIHandlers) HandleListSeries(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// parseSeriesStateFilters 解析系列列表上的个人状态筛选参数 (unread, favorite, minRating)。
+// 没有任何筛选参数时返回 nil。
+func parseSeriesStateFilters(r *http.Request) (*models.SmartQuery, error) {
+	q := r.URL.Query()
+	var query models.SmartQuery
+	var present bool
+	if v := q.Get("unread"); v != "" {
+		unread, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 'unread' 参数: %s", v)
+		}
+		query.Unread = &unread
+		present = true
+	}
+	if v := q.Get("favorite"); v != "" {
+		favorite, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 'favorite' 参数: %s", v)
+		}
+		query.Favorite = &favorite
+		present = true
+	}
+	if v := q.Get("minRating"); v != "" {
+		minRating, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 'minRating' 参数: %s", v)
+		}
+		query.MinRating = &minRating
+		present = true
+	}
+	if !present {
+		return nil, nil
+	}
+	return &query, nil
+}
+
 func (h *APIHandlers) HandleListImagesBySeries(w http.ResponseWriter, r *http.Request) {
 	seriesID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "seriesID"))
 	if err != nil {
 		respondError(w, http.StatusBadRequest, "无效的系列ID")
 		return
 	}
-	images, err := h.db.Images().GetAllBySeriesID(r.Context(), seriesID)
+	filter, err := parseImageFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.SeriesID = &seriesID
+	images, _, err := h.store(r).Images().Query(r.Context(), filter, 0, 0)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "无法获取图片列表: "+err.Error())
 		return
@@ -128,6 +209,248 @@ func (h *APIHandlers) HandleListImagesBySeries(w http.ResponseWriter, r *http.Re
 	respondJSON(w, http.StatusOK, images)
 }
 
+// HandleListImages 跨系列按技术元数据筛选图片，支持分页
+func (h *APIHandlers) HandleListImages(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	filter, err := parseImageFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := r.URL.Query().Get("seriesId"); v != "" {
+		seriesID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "无效的系列ID")
+			return
+		}
+		filter.SeriesID = &seriesID
+	}
+	images, total, err := h.store(r).Images().Query(r.Context(), filter, page, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "无法获取图片列表: "+err.Error())
+		return
+	}
+	response := map[string]interface{}{
+		"data": images,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"totalPages":  int(math.Ceil(float64(total) / float64(limit))),
+			"totalItems":  total,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// HandleGetImagePreview 返回按 EXIF 方向校正并缩放后的 JPEG 预览图，size 为最长边上限 (默认 1600)
+func (h *APIHandlers) HandleGetImagePreview(w http.ResponseWriter, r *http.Request) {
+	imageID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "imageID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的图片ID")
+		return
+	}
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	if size <= 0 || size > 4096 {
+		size = 1600
+	}
+	img, err := h.store(r).Images().GetByID(r.Context(), imageID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取图片失败: "+err.Error())
+		return
+	}
+	if img == nil {
+		respondError(w, http.StatusNotFound, "图片不存在")
+		return
+	}
+	var source image.Image
+	if img.MediaType == models.MediaTypeVideo {
+		// 视频以封面帧作为预览
+		source, err = loadVideoPoster(img.FilePath)
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	} else {
+		data, err := archive.ReadFile(img.FilePath)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "读取图片文件失败: "+err.Error())
+			return
+		}
+		decoded, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			respondError(w, http.StatusUnprocessableEntity, "解码图片失败: "+err.Error())
+			return
+		}
+		meta := imagemeta.Extract(data, decoded, format)
+		source = thumbnailer.ApplyOrientation(decoded, meta.Orientation())
+	}
+	rendered, err := thumbnailer.RenderJPEG(source, size, size)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "生成预览图失败: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(rendered)
+}
+
+// HandleGetImageFile 返回图片的原始文件，压缩包内的图片会从压缩包中读取
+func (h *APIHandlers) HandleGetImageFile(w http.ResponseWriter, r *http.Request) {
+	imageID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "imageID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的图片ID")
+		return
+	}
+	img, err := h.store(r).Images().GetByID(r.Context(), imageID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取图片失败: "+err.Error())
+		return
+	}
+	if img == nil {
+		respondError(w, http.StatusNotFound, "图片不存在")
+		return
+	}
+	file, err := archive.Open(img.FilePath)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "读取图片文件失败: "+err.Error())
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	// ServeContent 根据文件名推断 Content-Type，并处理 Range 请求 (视频拖动进度条需要)
+	http.ServeContent(w, r, path.Base(img.FileName), img.UpdatedAt, file)
+}
+
+// loadVideoPoster 从视频容器中提取封面帧
+func loadVideoPoster(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取视频文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := videometa.Probe(file)
+	if err != nil {
+		return nil, fmt.Errorf("解析视频失败: %w", err)
+	}
+	if info.Poster == nil {
+		return nil, fmt.Errorf("该视频没有可用的封面帧")
+	}
+	return info.Poster, nil
+}
+
+// parseImageFilter 解析图片列表上的技术元数据筛选参数：
+// minWidth, maxWidth, minHeight, maxHeight, orientation, format, animated, mediaType，
+// 以及下载器元数据上的 author, sourceTag
+func parseImageFilter(r *http.Request) (*models.ImageFilter, error) {
+	q := r.URL.Query()
+	filter := &models.ImageFilter{
+		Orientation: q.Get("orientation"),
+		Format:      q.Get("format"),
+		MediaType:   q.Get("mediaType"),
+		Author:      q.Get("author"),
+		SourceTag:   q.Get("sourceTag"),
+	}
+	intParams := map[string]**int{
+		"minWidth":  &filter.MinWidth,
+		"maxWidth":  &filter.MaxWidth,
+		"minHeight": &filter.MinHeight,
+		"maxHeight": &filter.MaxHeight,
+	}
+	for name, target := range intParams {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("无效的 '%s' 参数: %s", name, v)
+		}
+		*target = &n
+	}
+	switch filter.Orientation {
+	case "", models.OrientationPortrait, models.OrientationLandscape, models.OrientationSquare:
+	default:
+		return nil, fmt.Errorf("无效的 'orientation' 参数: %s", filter.Orientation)
+	}
+	switch filter.MediaType {
+	case "", models.MediaTypeImage, models.MediaTypeAnimation, models.MediaTypeVideo:
+	default:
+		return nil, fmt.Errorf("无效的 'mediaType' 参数: %s", filter.MediaType)
+	}
+	if v := q.Get("animated"); v != "" {
+		animated, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 'animated' 参数: %s", v)
+		}
+		filter.Animated = &animated
+	}
+	return filter, nil
+}
+
+// --- 系列个人状态处理器 ---
+
+// HandleGetSeriesState 获取系列的评分、收藏和阅读进度，未设置过时返回默认值
+func (h *APIHandlers) HandleGetSeriesState(w http.ResponseWriter, r *http.Request) {
+	seriesID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "seriesID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的系列ID")
+		return
+	}
+	state, err := h.store(r).UserState().GetBySeriesID(r.Context(), currentUser(r).ID, seriesID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取系列状态失败: "+err.Error())
+		return
+	}
+	if state == nil {
+		state = &models.SeriesState{UserID: currentUser(r).ID, SeriesID: seriesID}
+	}
+	respondJSON(w, http.StatusOK, state)
+}
+
+// HandleUpdateSeriesState 部分更新系列的个人状态，请求体中未出现的字段保持不变
+func (h *APIHandlers) HandleUpdateSeriesState(w http.ResponseWriter, r *http.Request) {
+	seriesID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "seriesID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的系列ID")
+		return
+	}
+	var payload models.SeriesStateUpdate
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Rating != nil && (*payload.Rating < 0 || *payload.Rating > 5) {
+		respondError(w, http.StatusBadRequest, "'rating' 必须在 0 到 5 之间")
+		return
+	}
+	if payload.LastReadPage != nil && *payload.LastReadPage < 0 {
+		respondError(w, http.StatusBadRequest, "'lastReadPage' 不能为负数")
+		return
+	}
+	series, err := h.store(r).Series().GetByID(r.Context(), seriesID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取系列失败: "+err.Error())
+		return
+	}
+	if series == nil {
+		respondError(w, http.StatusNotFound, "系列不存在")
+		return
+	}
+	state, err := h.store(r).UserState().Upsert(r.Context(), currentUser(r).ID, seriesID, &payload)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, state)
+}
+
 // --- 搜索处理器 ---
 
 func (h *APIHandlers) HandleSearchText(w http.ResponseWriter, r *http.Request) {
@@ -136,7 +459,7 @@ func (h *APIHandlers) HandleSearchText(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "缺少搜索查询参数 'q'")
 		return
 	}
-	series, total, err := h.db.Series().SearchByName(r.Context(), query, 1, 100)
+	series, total, err := h.store(r).Series().SearchByName(r.Context(), query, 1, 100)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "搜索系列失败: "+err.Error())
 		return
@@ -179,7 +502,7 @@ func (h *APIHandlers) HandleSearchByImage(w http.ResponseWriter, r *http.Request
 		respondError(w, http.StatusInternalServerError, "计算图片哈希失败: "+err.Error())
 		return
 	}
-	similarImages, err := h.db.Images().FindSimilarByPHash(r.Context(), pHash, 50)
+	similarImages, err := h.store(r).Images().FindSimilarByPHash(r.Context(), pHash, 50)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "数据库查找失败: "+err.Error())
 		return
@@ -194,7 +517,7 @@ func (h *APIHandlers) HandleSearchByImage(w http.ResponseWriter, r *http.Request
 	}
 	var series []models.Series
 	if len(uniqueSeriesIDs) > 0 {
-		series, err = h.db.Series().GetByIDs(r.Context(), uniqueSeriesIDs)
+		series, err = h.store(r).Series().GetByIDs(r.Context(), uniqueSeriesIDs)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "获取系列信息失败: "+err.Error())
 			return
@@ -215,35 +538,231 @@ func (h *APIHandlers) HandleSearchByImage(w http.ResponseWriter, r *http.Request
 
 // HandleGetConfig 获取当前应用配置
 func (h *APIHandlers) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
-	// [修正] 直接返回全局配置变量 config.C
-	respondJSON(w, http.StatusOK, config.C)
+	// [修正] 直接返回当前生效的配置 config.Current()
+	respondJSON(w, http.StatusOK, config.Current())
 }
 
-// HandleUpdateConfig 更新并保存应用配置
+// HandleUpdateConfig 校验、保存并重新加载应用配置。
+// 请求体只需包含要修改的字段，其余字段沿用当前配置；有任务运行时拒绝修改。
+// 每个库的扫描器和日志会按新配置重建，server 和 database 的修改需要重启后才生效。
 func (h *APIHandlers) HandleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	old := config.Current()
+	// 通过 JSON 复制一份当前配置，避免解码时复用切片改动正在使用的配置
+	current, err := json.Marshal(old)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "读取当前配置失败: "+err.Error())
+		return
+	}
 	var newConfig config.Config
+	if err := json.Unmarshal(current, &newConfig); err != nil {
+		respondError(w, http.StatusInternalServerError, "读取当前配置失败: "+err.Error())
+		return
+	}
 	if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
 		respondError(w, http.StatusBadRequest, "无效的配置格式: "+err.Error())
 		return
 	}
+	newConfig.ApplyDefaults()
+	if err := newConfig.Validate(); err != nil {
+		respondError(w, http.StatusBadRequest, "配置校验失败: "+err.Error())
+		return
+	}
+	// 每个库的数据库连接在启动时建立，增删库或修改库的数据库需要重启
+	if !sameLibraryStores(old, &newConfig) {
+		respondError(w, http.StatusBadRequest, "不能在运行时增删库或修改库的 database/collectionPrefix，请修改配置文件后重启服务")
+		return
+	}
 
-	// [修正] 实现将配置写回 config.yaml 文件的逻辑
-	// 1. 将接收到的新配置数据序列化为YAML格式
-	yamlData, err := yaml.Marshal(&newConfig)
+	err = h.taskManager.Reload(&newConfig, func() (map[string]*scanner.Orchestrator, error) {
+		// 创建协调器会替换全局的扩展名允许列表，失败时要恢复原来的列表，
+		// 否则仍在使用旧配置的扫描器会按新的允许列表处理文件
+		images, videos := formats.Allowed(), formats.AllowedVideo()
+		restore := func() {
+			if err := formats.SetAllowedExtensions(images); err != nil {
+				slog.Error("恢复图片扩展名允许列表失败", "error", err)
+			}
+			if err := formats.SetAllowedVideoExtensions(videos); err != nil {
+				slog.Error("恢复视频扩展名允许列表失败", "error", err)
+			}
+		}
+		orchestrators, err := scanner.NewOrchestrators(&newConfig, h.stores)
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		if err := config.Save(config.File, &newConfig); err != nil {
+			for _, o := range orchestrators {
+				o.Close()
+			}
+			restore()
+			return nil, err
+		}
+		return orchestrators, nil
+	})
+	if errors.Is(err, task.ErrTaskRunning) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "序列化配置为YAML失败: "+err.Error())
+		respondError(w, http.StatusInternalServerError, "应用新配置失败: "+err.Error())
 		return
 	}
 
-	// 2. 将YAML数据写入到 config.yaml 文件
-	// 假设配置文件在当前工作目录
-	if err := os.WriteFile("config.yaml", yamlData, 0644); err != nil {
-		respondError(w, http.StatusInternalServerError, "写入config.yaml文件失败: "+err.Error())
+	config.Set(&newConfig)
+	if err := logger.InitLogger(); err != nil {
+		slog.Error("按新配置重建日志失败", "error", err)
+	}
+	if err := h.purgers.Restart(&newConfig); err != nil {
+		slog.Error("按新配置重启回收站清除任务失败，原有的清除任务继续运行", "error", err)
+	}
+	if old.Server != newConfig.Server || old.Database != newConfig.Database {
+		slog.Warn("server 或 database 配置已修改，需要重启服务后才能生效")
+	}
+	slog.Info("配置已更新并重新加载", "文件", config.File)
+
+	respondJSON(w, http.StatusOK, &newConfig)
+}
+
+// --- 智能集合处理器 ---
+
+// smartCollectionPayload 是创建/更新智能集合时的请求体
+type smartCollectionPayload struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Query       models.SmartQuery `json:"query"`
+}
+
+func (h *APIHandlers) HandleListSmartCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.store(r).SmartCollections().List(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "无法获取智能集合列表: "+err.Error())
 		return
 	}
+	respondJSON(w, http.StatusOK, collections)
+}
 
-	// 3. 更新内存中的全局配置变量
-	config.C = &newConfig
+func (h *APIHandlers) HandleCreateSmartCollection(w http.ResponseWriter, r *http.Request) {
+	var payload smartCollectionPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Name == "" {
+		respondError(w, http.StatusBadRequest, "缺少 'name' 字段")
+		return
+	}
+	collection := &models.SmartCollection{
+		Name:        payload.Name,
+		Description: payload.Description,
+		Query:       payload.Query,
+	}
+	if err := h.store(r).SmartCollections().Create(r.Context(), collection); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(w, http.StatusConflict, "已存在同名的智能集合")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "创建智能集合失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusCreated, collection)
+}
+
+func (h *APIHandlers) HandleGetSmartCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.loadSmartCollection(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, collection)
+}
+
+func (h *APIHandlers) HandleUpdateSmartCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.loadSmartCollection(w, r)
+	if !ok {
+		return
+	}
+	var payload smartCollectionPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	if payload.Name == "" {
+		respondError(w, http.StatusBadRequest, "缺少 'name' 字段")
+		return
+	}
+	collection.Name = payload.Name
+	collection.Description = payload.Description
+	collection.Query = payload.Query
+	if err := h.store(r).SmartCollections().Update(r.Context(), collection); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			respondError(w, http.StatusConflict, "已存在同名的智能集合")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "更新智能集合失败: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, collection)
+}
+
+func (h *APIHandlers) HandleDeleteSmartCollection(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.loadSmartCollection(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store(r).SmartCollections().Delete(r.Context(), collection.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "删除智能集合失败: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	respondJSON(w, http.StatusOK, config.C)
+// HandleListSmartCollectionItems 实时计算并返回智能集合当前匹配的系列
+func (h *APIHandlers) HandleListSmartCollectionItems(w http.ResponseWriter, r *http.Request) {
+	collection, ok := h.loadSmartCollection(w, r)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	query := collection.Query
+	query.UserID = currentUser(r).ID
+	series, total, err := h.store(r).QuerySeries(r.Context(), &query, page, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "计算智能集合失败: "+err.Error())
+		return
+	}
+	response := map[string]interface{}{
+		"data": series,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"totalPages":  int(math.Ceil(float64(total) / float64(limit))),
+			"totalItems":  total,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// loadSmartCollection 解析 URL 中的 {id} 并加载对应的智能集合。
+// 失败时已写入错误响应，调用方只需在 ok 为 false 时直接返回。
+func (h *APIHandlers) loadSmartCollection(w http.ResponseWriter, r *http.Request) (*models.SmartCollection, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的智能集合ID")
+		return nil, false
+	}
+	collection, err := h.store(r).SmartCollections().GetByID(r.Context(), id)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "获取智能集合失败: "+err.Error())
+		return nil, false
+	}
+	if collection == nil {
+		respondError(w, http.StatusNotFound, "智能集合不存在")
+		return nil, false
+	}
+	return collection, true
 }
@@ -0,0 +1,98 @@
+// 文件: internal/api/libraries.go
+package api
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type libraryContextKey struct{}
+
+// requestLibrary 是请求所操作的库：/libraries/{lib}/... 路由由路径决定，不带库名的旧路由使用默认库。
+type requestLibrary struct {
+	config config.LibraryConfig
+	store  database.Store
+}
+
+// libraryResponse 是库列表中的一项。数据库和目录等字段与 GET /config 一样只返回给管理员。
+type libraryResponse struct {
+	Name             string `json:"name"`
+	Default          bool   `json:"default"`
+	Database         string `json:"database,omitempty"`
+	CollectionPrefix string `json:"collectionPrefix,omitempty"`
+	ScanPath         string `json:"scanPath,omitempty"`
+	FinalLibraryPath string `json:"finalLibraryPath,omitempty"`
+	LibraryLayout    string `json:"libraryLayout,omitempty"`
+}
+
+// libraryMiddleware 解析请求所属的库，找不到时返回 404。
+func (h *APIHandlers) libraryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "lib")
+		if name == "" {
+			name = config.Current().DefaultLibraryConfig().Name
+		}
+		cfg, ok := config.Current().Library(name)
+		store, hasStore := h.stores[name]
+		if !ok || !hasStore {
+			respondError(w, http.StatusNotFound, "找不到库: "+name)
+			return
+		}
+		ctx := context.WithValue(r.Context(), libraryContextKey{}, &requestLibrary{config: cfg, store: store})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// library 返回请求所属的库，只能在 libraryMiddleware 之后调用。
+func (h *APIHandlers) library(r *http.Request) *requestLibrary {
+	return r.Context().Value(libraryContextKey{}).(*requestLibrary)
+}
+
+// store 返回请求所属的库的 Store。
+func (h *APIHandlers) store(r *http.Request) database.Store {
+	return h.library(r).store
+}
+
+// HandleListLibraries 列出所有库，第一个库是不带库名的旧路由所操作的默认库。
+// 非管理员只能看到库名，看不到数据库名和服务器上的路径。
+func (h *APIHandlers) HandleListLibraries(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Current()
+	admin := currentUser(r).Role == models.RoleAdmin
+	libs := cfg.LibraryConfigs()
+	response := make([]libraryResponse, 0, len(libs))
+	for i, lib := range libs {
+		item := libraryResponse{Name: lib.Name, Default: i == 0}
+		if admin {
+			item.Database = lib.Database
+			if item.Database == "" {
+				item.Database = cfg.Database.Name
+			}
+			item.CollectionPrefix = lib.CollectionPrefix
+			item.ScanPath = lib.Scanner.ScanPath
+			item.FinalLibraryPath = lib.Scanner.FinalLibraryPath
+			item.LibraryLayout = lib.Scanner.LibraryLayout
+		}
+		response = append(response, item)
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// sameLibraryStores 判断两份配置的库名以及库自己的 database、collectionPrefix 是否一致。
+// 顶层 database 的修改与以前一样只需重启后生效，不在这里比较。
+func sameLibraryStores(a, b *config.Config) bool {
+	libsA, libsB := a.LibraryConfigs(), b.LibraryConfigs()
+	if len(libsA) != len(libsB) {
+		return false
+	}
+	for i := range libsA {
+		if libsA[i].Name != libsB[i].Name || libsA[i].Database != libsB[i].Database || libsA[i].CollectionPrefix != libsB[i].CollectionPrefix {
+			return false
+		}
+	}
+	return true
+}
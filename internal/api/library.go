@@ -0,0 +1,278 @@
+// 文件: internal/api/library.go
+package api
+
+import (
+	"PICs_Manager/pkg/layout"
+	"PICs_Manager/pkg/library"
+	"PICs_Manager/pkg/trash"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HandleRenameSeries 重命名系列，并把文件夹移动到新名称对应的归档字母目录
+func (h *APIHandlers) HandleRenameSeries(w http.ResponseWriter, r *http.Request) {
+	editor, seriesID, done, ok := h.libraryRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	series, err := editor.Rename(r.Context(), seriesID, payload.Name)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, series)
+}
+
+// HandleSetSeriesSortName 设置系列的排序名覆盖，sortName 为空时恢复为自动罗马字化的名称
+func (h *APIHandlers) HandleSetSeriesSortName(w http.ResponseWriter, r *http.Request) {
+	editor, seriesID, done, ok := h.libraryRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	var payload struct {
+		SortName string `json:"sortName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	series, err := editor.SetSortName(r.Context(), seriesID, payload.SortName)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, series)
+}
+
+// HandleMergeSeries 把当前系列的所有文件并入 targetSeriesId，并删除当前系列
+func (h *APIHandlers) HandleMergeSeries(w http.ResponseWriter, r *http.Request) {
+	editor, seriesID, done, ok := h.libraryRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	var payload struct {
+		TargetSeriesID string `json:"targetSeriesId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	targetID, err := primitive.ObjectIDFromHex(payload.TargetSeriesID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的目标系列ID")
+		return
+	}
+	series, err := editor.Merge(r.Context(), seriesID, targetID)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, series)
+}
+
+// HandleSplitSeries 把选中的图片移动到一个新系列中
+func (h *APIHandlers) HandleSplitSeries(w http.ResponseWriter, r *http.Request) {
+	editor, seriesID, done, ok := h.libraryRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	var payload struct {
+		ImageIDs []string `json:"imageIds"`
+		Name     string   `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	imageIDs, ok := parseObjectIDs(w, payload.ImageIDs)
+	if !ok {
+		return
+	}
+	series, err := editor.Split(r.Context(), seriesID, imageIDs, payload.Name)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusCreated, series)
+}
+
+// HandleDeleteSeries 把系列文件夹移入回收站，并删除系列及其所有图片记录
+func (h *APIHandlers) HandleDeleteSeries(w http.ResponseWriter, r *http.Request) {
+	editor, seriesID, done, ok := h.libraryRequest(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	if err := editor.DeleteSeries(r.Context(), seriesID); err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleDeleteImage 把单张图片移入回收站并删除其记录
+func (h *APIHandlers) HandleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	imageID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "imageID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的图片ID")
+		return
+	}
+	editor, done, ok := h.newEditor(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	if _, err := editor.DeleteImages(r.Context(), []primitive.ObjectID{imageID}); err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleDeleteImages 批量把图片移入回收站并删除其记录，返回受影响的系列
+func (h *APIHandlers) HandleDeleteImages(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ImageIDs []string `json:"imageIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	imageIDs, ok := parseObjectIDs(w, payload.ImageIDs)
+	if !ok {
+		return
+	}
+	editor, done, ok := h.newEditor(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	affected, err := editor.DeleteImages(r.Context(), imageIDs)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"deleted": len(imageIDs), "affectedSeries": affected})
+}
+
+// HandleMoveImages 把选中的图片移动到 targetSeriesId
+func (h *APIHandlers) HandleMoveImages(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ImageIDs       []string `json:"imageIds"`
+		TargetSeriesID string   `json:"targetSeriesId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	targetID, err := primitive.ObjectIDFromHex(payload.TargetSeriesID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的目标系列ID")
+		return
+	}
+	imageIDs, ok := parseObjectIDs(w, payload.ImageIDs)
+	if !ok {
+		return
+	}
+	editor, done, ok := h.newEditor(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	series, err := editor.MoveImages(r.Context(), imageIDs, targetID)
+	if err != nil {
+		respondLibraryError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, series)
+}
+
+func (h *APIHandlers) libraryRequest(w http.ResponseWriter, r *http.Request) (library.Editor, primitive.ObjectID, func(), bool) {
+	seriesID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "seriesID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的系列ID")
+		return nil, primitive.NilObjectID, nil, false
+	}
+	editor, done, ok := h.newEditor(w, r)
+	return editor, seriesID, done, ok
+}
+
+// newEditor 创建请求所属库的 Editor，并用 beginEdit 登记一次整理操作。操作结束后调用方必须调用 done。
+func (h *APIHandlers) newEditor(w http.ResponseWriter, r *http.Request) (library.Editor, func(), bool) {
+	lib := h.library(r)
+	// 没有配置回收站时，除删除以外的整理操作仍然可用
+	bin, err := h.newTrashManager(r)
+	if err != nil && !errors.Is(err, trash.ErrNotConfigured) {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	libraryLayout, err := layout.New(lib.config.Scanner.LibraryLayout)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	editor, err := library.NewEditor(lib.config.Scanner.FinalLibraryPath, libraryLayout, bin, lib.store)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	done, ok := h.beginEdit(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	return editor, done, true
+}
+
+// beginEdit 在任务管理器中为请求所属的库登记一次整理操作：库中有任务 (扫描、同步等) 运行时返回 409，
+// 整理期间也不能启动新任务。操作结束后调用方必须调用 done，需要在操作之后启动同步任务时先调用 done。
+func (h *APIHandlers) beginEdit(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	done, err := h.taskManager.BeginEdit(h.library(r).config.Name)
+	if err != nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return nil, false
+	}
+	return done, true
+}
+
+func parseObjectIDs(w http.ResponseWriter, hexes []string) ([]primitive.ObjectID, bool) {
+	ids := make([]primitive.ObjectID, 0, len(hexes))
+	for _, hex := range hexes {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "无效的图片ID: "+hex)
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+func respondLibraryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, library.ErrSeriesNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, library.ErrNameTaken):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, library.ErrInvalidRequest):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, trash.ErrNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
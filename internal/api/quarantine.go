@@ -0,0 +1,166 @@
+// 文件: internal/api/quarantine.go
+package api
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/quarantine"
+	"PICs_Manager/pkg/trash"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HandleListQuarantine 分页列出隔离区中的文件，可用 ?kind= 按隔离原因筛选
+func (h *APIHandlers) HandleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	kind := models.QuarantineKind(r.URL.Query().Get("kind"))
+	items, total, err := h.store(r).Quarantine().List(r.Context(), kind, page, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "无法获取隔离列表: "+err.Error())
+		return
+	}
+	response := map[string]interface{}{
+		"data": items,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"totalPages":  int(math.Ceil(float64(total) / float64(limit))),
+			"totalItems":  total,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// HandleRestoreQuarantineItem 把隔离文件移回原始位置
+func (h *APIHandlers) HandleRestoreQuarantineItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.quarantineRequest(w, r)
+	if !ok {
+		return
+	}
+	done, ok := h.beginEdit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	item, err := manager.Restore(r.Context(), id)
+	if err != nil {
+		respondQuarantineError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, item)
+}
+
+// HandlePurgeQuarantineItem 把隔离文件移入回收站并删除其记录
+func (h *APIHandlers) HandlePurgeQuarantineItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.quarantineRequest(w, r)
+	if !ok {
+		return
+	}
+	done, ok := h.beginEdit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	if _, err := manager.Purge(r.Context(), id); err != nil {
+		respondQuarantineError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCompareQuarantineItem 对比冲突文件夹与其冲突目标的文件列表和哈希
+func (h *APIHandlers) HandleCompareQuarantineItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.quarantineRequest(w, r)
+	if !ok {
+		return
+	}
+	comparison, err := manager.Compare(r.Context(), id)
+	if err != nil {
+		respondQuarantineError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, comparison)
+}
+
+// HandleResolveQuarantineItem 以 merge / replace / rename / discard 之一解决文件夹冲突，
+// 并为内容发生变化的系列文件夹启动一个同步任务
+func (h *APIHandlers) HandleResolveQuarantineItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.quarantineRequest(w, r)
+	if !ok {
+		return
+	}
+	var req quarantine.ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+	done, ok := h.beginEdit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	resolution, err := manager.Resolve(r.Context(), id, req)
+	if err != nil {
+		respondQuarantineError(w, err)
+		return
+	}
+	// 整理操作结束后才能启动同步任务
+	done()
+	response := map[string]interface{}{"resolution": resolution}
+	if len(resolution.AffectedPaths) > 0 {
+		taskID, err := h.taskManager.StartSyncTask(h.library(r).config.Name, resolution.AffectedPaths)
+		if err != nil {
+			// 文件已经移动完毕，只是暂时无法入库；下次扫描或手动同步即可补上
+			response["syncError"] = err.Error()
+		} else {
+			response["taskId"] = taskID
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+func (h *APIHandlers) quarantineRequest(w http.ResponseWriter, r *http.Request) (quarantine.Manager, primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "itemID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的隔离记录ID")
+		return nil, primitive.NilObjectID, false
+	}
+	bin, err := h.newTrashManager(r)
+	if err != nil {
+		respondTrashError(w, err)
+		return nil, primitive.NilObjectID, false
+	}
+	manager, err := quarantine.NewManager(fsutil.OS, h.library(r).config.Scanner.QuarantinePath, bin, h.store(r).Quarantine())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return nil, primitive.NilObjectID, false
+	}
+	return manager, id, true
+}
+
+func respondQuarantineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, quarantine.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, quarantine.ErrRestoreConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, quarantine.ErrNotCollision), errors.Is(err, quarantine.ErrInvalidResolution):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, trash.ErrNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
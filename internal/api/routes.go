@@ -2,8 +2,10 @@
 package api
 
 import (
+	"PICs_Manager/internal/models"
 	"PICs_Manager/internal/task"
 	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/trash"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -12,7 +14,8 @@ import (
 )
 
 // RegisterRoutes 注册所有API路由
-func RegisterRoutes(tm *task.Manager, db database.Store) *chi.Mux {
+// db 用于用户、会话和 API 令牌，stores 是每个库的 Store，以库名为键；purgers 在修改配置后重启。
+func RegisterRoutes(tm *task.Manager, db database.Store, stores map[string]database.Store, purgers *trash.Purgers) *chi.Mux {
 	r := chi.NewRouter()
 
 	// --- 中间件 (Middleware) ---
@@ -28,19 +31,49 @@ func RegisterRoutes(tm *task.Manager, db database.Store) *chi.Mux {
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
-	
-	handlers := NewAPIHandlers(tm, db)
+
+	handlers := NewAPIHandlers(tm, db, stores, purgers)
 
 	// --- API路由 ---
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Post("/tasks/scan", handlers.HandleStartScanTask)
-		r.Get("/tasks/{taskId}", handlers.HandleGetTaskStatus)
-		r.Get("/series", handlers.HandleListSeries)
-		r.Get("/series/{seriesID}/images", handlers.HandleListImagesBySeries)
-		r.Get("/search/text", handlers.HandleSearchText)
-		r.Post("/search/image", handlers.HandleSearchByImage)
-		r.Get("/config", handlers.HandleGetConfig)
-		r.Put("/config", handlers.HandleUpdateConfig)
+		r.Post("/auth/login", handlers.HandleLogin)
+
+		// 以下路由都需要登录 (会话 Cookie 或 API 令牌)
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.authMiddleware)
+
+			r.Post("/auth/logout", handlers.HandleLogout)
+			r.Get("/auth/me", handlers.HandleGetCurrentUser)
+			r.Get("/auth/tokens", handlers.HandleListAPITokens)
+			r.Post("/auth/tokens", handlers.HandleCreateAPIToken)
+			r.Delete("/auth/tokens/{tokenID}", handlers.HandleDeleteAPIToken)
+
+			r.Get("/libraries", handlers.HandleListLibraries)
+			r.Get("/tasks/{taskId}", handlers.HandleGetTaskStatus)
+
+			// 仅管理员：查看和修改配置 (其中有数据库连接串和服务器路径)、管理用户
+			r.Group(func(r chi.Router) {
+				r.Use(requireRole(models.RoleAdmin))
+
+				r.Get("/config", handlers.HandleGetConfig)
+				r.Put("/config", handlers.HandleUpdateConfig)
+
+				r.Get("/users", handlers.HandleListUsers)
+				r.Post("/users", handlers.HandleCreateUser)
+				r.Put("/users/{userID}", handlers.HandleUpdateUser)
+				r.Delete("/users/{userID}", handlers.HandleDeleteUser)
+			})
+
+			// 库内的路由：/libraries/{lib}/...；不带库名的旧路由操作默认库 (配置中的第一个库)
+			r.Route("/libraries/{lib}", func(r chi.Router) {
+				r.Use(handlers.libraryMiddleware)
+				handlers.registerLibraryRoutes(r)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(handlers.libraryMiddleware)
+				handlers.registerLibraryRoutes(r)
+			})
+		})
 	})
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -50,3 +83,55 @@ func RegisterRoutes(tm *task.Manager, db database.Store) *chi.Mux {
 
 	return r
 }
+
+// registerLibraryRoutes 注册操作单个库内容的路由，请求所属的库由 libraryMiddleware 解析。
+func (h *APIHandlers) registerLibraryRoutes(r chi.Router) {
+	r.Get("/series", h.HandleListSeries)
+	r.Get("/series/{seriesID}/images", h.HandleListImagesBySeries)
+	r.Get("/images", h.HandleListImages)
+	r.Get("/images/{imageID}/preview", h.HandleGetImagePreview)
+	r.Get("/images/{imageID}/file", h.HandleGetImageFile)
+	r.Get("/series/{seriesID}/state", h.HandleGetSeriesState)
+	r.Put("/series/{seriesID}/state", h.HandleUpdateSeriesState)
+	r.Get("/search/text", h.HandleSearchText)
+	r.Post("/search/image", h.HandleSearchByImage)
+
+	r.Get("/smart-collections", h.HandleListSmartCollections)
+	r.Post("/smart-collections", h.HandleCreateSmartCollection)
+	r.Get("/smart-collections/{id}", h.HandleGetSmartCollection)
+	r.Put("/smart-collections/{id}", h.HandleUpdateSmartCollection)
+	r.Delete("/smart-collections/{id}", h.HandleDeleteSmartCollection)
+	r.Get("/smart-collections/{id}/items", h.HandleListSmartCollectionItems)
+
+	// 仅管理员：启动扫描、处理中转站遗留、删除内容、管理隔离区和回收站
+	r.Group(func(r chi.Router) {
+		r.Use(requireRole(models.RoleAdmin))
+
+		r.Post("/tasks/scan", h.HandleStartScanTask)
+		r.Post("/rules/test", h.HandleTestRules)
+
+		r.Get("/staging", h.HandleGetStaging)
+		r.Post("/staging/resume", h.HandleResumeStaging)
+		r.Post("/staging/return", h.HandleReturnStaging)
+
+		r.Get("/quarantine", h.HandleListQuarantine)
+		r.Post("/quarantine/{itemID}/restore", h.HandleRestoreQuarantineItem)
+		r.Get("/quarantine/{itemID}/compare", h.HandleCompareQuarantineItem)
+		r.Post("/quarantine/{itemID}/resolve", h.HandleResolveQuarantineItem)
+		r.Delete("/quarantine/{itemID}", h.HandlePurgeQuarantineItem)
+
+		r.Post("/series/{seriesID}/rename", h.HandleRenameSeries)
+		r.Put("/series/{seriesID}/sort-name", h.HandleSetSeriesSortName)
+		r.Post("/series/{seriesID}/merge", h.HandleMergeSeries)
+		r.Post("/series/{seriesID}/split", h.HandleSplitSeries)
+		r.Delete("/series/{seriesID}", h.HandleDeleteSeries)
+		r.Delete("/images/{imageID}", h.HandleDeleteImage)
+		r.Post("/images/delete", h.HandleDeleteImages)
+		r.Post("/images/move", h.HandleMoveImages)
+
+		r.Get("/trash", h.HandleListTrash)
+		r.Post("/trash/purge-expired", h.HandlePurgeExpiredTrash)
+		r.Post("/trash/{itemID}/restore", h.HandleRestoreTrashItem)
+		r.Delete("/trash/{itemID}", h.HandlePurgeTrashItem)
+	})
+}
@@ -0,0 +1,73 @@
+// 文件: internal/api/rules.go
+package api
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/pkg/scanner"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+)
+
+// rulesTestPayload 是规则测试的请求体。
+// 规则字段为空时使用当前配置，非空时只用于本次测试，便于在保存前调试新的正则。
+type rulesTestPayload struct {
+	Files   []string `json:"files"`
+	Folders []string `json:"folders"`
+	// Dir 非空时从该目录取样文件和子目录，追加到 Files / Folders 之后。
+	Dir   string `json:"dir"`
+	Limit int    `json:"limit"`
+
+	FilePatterns        []string                 `json:"filePatterns"`
+	Classifiers         []config.ClassifierRule  `json:"classifiers"`
+	SeriesGroupPatterns []config.SeriesGroupRule `json:"seriesGroupPatterns"`
+}
+
+// HandleTestRules 显示文件名命中的 filePatterns 规则和提取出的系列名，以及文件夹会被哪条
+// seriesGroupPatterns 规则聚合到哪个 _agg 目录，不移动任何文件
+func (h *APIHandlers) HandleTestRules(w http.ResponseWriter, r *http.Request) {
+	var payload rulesTestPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondError(w, http.StatusBadRequest, "无效的请求体: "+err.Error())
+		return
+	}
+
+	cfg := h.library(r).config.Scanner
+	if payload.FilePatterns != nil {
+		cfg.FilePatterns = payload.FilePatterns
+	}
+	if payload.Classifiers != nil {
+		cfg.Classifiers = payload.Classifiers
+	}
+	if payload.SeriesGroupPatterns != nil {
+		cfg.SeriesGroupRules = payload.SeriesGroupPatterns
+	}
+
+	var scanRoot string
+	if payload.Dir != "" {
+		dir, err := filepath.Abs(payload.Dir)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "无效的目录: "+err.Error())
+			return
+		}
+		files, folders, err := scanner.SampleDir(dir, payload.Limit)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scanRoot = dir
+		payload.Files = append(payload.Files, files...)
+		payload.Folders = append(payload.Folders, folders...)
+	}
+	if len(payload.Files) == 0 && len(payload.Folders) == 0 {
+		respondError(w, http.StatusBadRequest, "请提供 files、folders 或 dir")
+		return
+	}
+
+	report, err := scanner.EvaluateRules(cfg, scanRoot, payload.Files, payload.Folders)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "规则无效: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,37 @@
+// 文件: internal/api/staging.go
+package api
+
+import (
+	"PICs_Manager/pkg/scanner"
+	"net/http"
+)
+
+// HandleGetStaging 报告中转站中遗留的内容，有遗留说明上次扫描中断了
+func (h *APIHandlers) HandleGetStaging(w http.ResponseWriter, r *http.Request) {
+	report, err := scanner.InspectStaging(h.library(r).config.Scanner.StagingPath)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "无法检查中转站: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, report)
+}
+
+// HandleResumeStaging 启动一个任务，把中转站中的系列归档到最终库并入库
+func (h *APIHandlers) HandleResumeStaging(w http.ResponseWriter, r *http.Request) {
+	taskID, err := h.taskManager.StartStagingResumeTask(h.library(r).config.Name)
+	if err != nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"taskId": taskID})
+}
+
+// HandleReturnStaging 启动一个任务，把中转站中的内容退回扫描目录
+func (h *APIHandlers) HandleReturnStaging(w http.ResponseWriter, r *http.Request) {
+	taskID, err := h.taskManager.StartStagingReturnTask(h.library(r).config.Name)
+	if err != nil {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"taskId": taskID})
+}
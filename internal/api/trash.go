@@ -0,0 +1,147 @@
+// 文件: internal/api/trash.go
+package api
+
+import (
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/trash"
+	"errors"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HandleListTrash 分页列出回收站中的条目
+func (h *APIHandlers) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	items, total, err := h.store(r).Trash().List(r.Context(), page, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "无法获取回收站列表: "+err.Error())
+		return
+	}
+	response := map[string]interface{}{
+		"data": items,
+		"pagination": map[string]interface{}{
+			"currentPage": page,
+			"totalPages":  int(math.Ceil(float64(total) / float64(limit))),
+			"totalItems":  total,
+		},
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// HandleRestoreTrashItem 把回收站条目移回原始位置；恢复到库内的文件会触发一次同步任务重新入库
+func (h *APIHandlers) HandleRestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.trashRequest(w, r)
+	if !ok {
+		return
+	}
+	done, ok := h.beginEdit(w, r)
+	if !ok {
+		return
+	}
+	defer done()
+	item, err := manager.Restore(r.Context(), id)
+	if err != nil {
+		respondTrashError(w, err)
+		return
+	}
+	// 整理操作结束后才能启动同步任务
+	done()
+	response := map[string]interface{}{"item": item}
+	if dir := librarySyncPath(h.library(r).config.Scanner.FinalLibraryPath, item.OriginalPath, item.IsDir); dir != "" {
+		taskID, err := h.taskManager.StartSyncTask(h.library(r).config.Name, []string{dir})
+		if err != nil {
+			response["syncError"] = err.Error()
+		} else {
+			response["taskId"] = taskID
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// HandlePurgeTrashItem 立即永久删除回收站条目
+func (h *APIHandlers) HandlePurgeTrashItem(w http.ResponseWriter, r *http.Request) {
+	manager, id, ok := h.trashRequest(w, r)
+	if !ok {
+		return
+	}
+	if _, err := manager.Purge(r.Context(), id); err != nil {
+		respondTrashError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePurgeExpiredTrash 立即清除所有超过保留期的条目，不必等待后台任务
+func (h *APIHandlers) HandlePurgeExpiredTrash(w http.ResponseWriter, r *http.Request) {
+	manager, err := h.newTrashManager(r)
+	if err != nil {
+		respondTrashError(w, err)
+		return
+	}
+	purged, err := manager.PurgeExpired(r.Context())
+	if err != nil {
+		respondTrashError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]int{"purged": purged})
+}
+
+func (h *APIHandlers) trashRequest(w http.ResponseWriter, r *http.Request) (trash.Manager, primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "itemID"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "无效的回收站记录ID")
+		return nil, primitive.NilObjectID, false
+	}
+	manager, err := h.newTrashManager(r)
+	if err != nil {
+		respondTrashError(w, err)
+		return nil, primitive.NilObjectID, false
+	}
+	return manager, id, true
+}
+
+func (h *APIHandlers) newTrashManager(r *http.Request) (trash.Manager, error) {
+	lib := h.library(r)
+	return trash.NewManager(fsutil.OS, lib.config.Scanner.TrashPath, lib.config.Scanner.TrashRetention, lib.store.Trash())
+}
+
+// librarySyncPath 返回恢复后需要重新入库的库内文件夹；不在库内时返回空字符串。
+func librarySyncPath(finalLibraryPath, path string, isDir bool) string {
+	libraryPath, err := filepath.Abs(finalLibraryPath)
+	if err != nil {
+		return ""
+	}
+	if rel, err := filepath.Rel(libraryPath, path); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	if isDir {
+		return path
+	}
+	return filepath.Dir(path)
+}
+
+func respondTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, trash.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, trash.ErrRestoreConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, trash.ErrNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
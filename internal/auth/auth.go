@@ -0,0 +1,68 @@
+// 文件: internal/auth/auth.go
+package auth
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/hasher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TokenPrefix 标识明文 API 令牌，便于在日志或泄露扫描中识别。
+	TokenPrefix = "pics_"
+	// displayPrefixLen 是保存到数据库、用于在列表中辨认令牌的明文前缀长度。
+	displayPrefixLen = len(TokenPrefix) + 6
+	minPasswordLen   = 8
+)
+
+// ErrInvalidCredentials 在用户名不存在或密码错误时返回，两种情况不做区分以免泄露账号是否存在。
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// HashPassword 使用 bcrypt 对明文密码进行哈希。
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLen {
+		return "", fmt.Errorf("密码长度不能少于 %d 位", minPasswordLen)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword 校验明文密码是否与用户的 bcrypt 哈希匹配。
+func CheckPassword(user *models.User, password string) error {
+	if user == nil {
+		return ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// ValidRole 判断给定的角色是否是系统支持的角色。
+func ValidRole(role models.Role) bool {
+	return role == models.RoleAdmin || role == models.RoleViewer
+}
+
+// NewToken 生成一个随机的明文令牌 (用于会话和 API 令牌)，并返回其哈希和展示用前缀。
+// 明文只应交给客户端一次，数据库中只保存哈希。
+func NewToken() (plain, hash, displayPrefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("生成随机令牌失败: %w", err)
+	}
+	plain = TokenPrefix + hex.EncodeToString(buf)
+	return plain, HashToken(plain), plain[:displayPrefixLen], nil
+}
+
+// HashToken 计算明文令牌的 SHA-256，用于数据库查找。
+func HashToken(plain string) string {
+	return hasher.CalculateSHA256FromBytes([]byte(plain))
+}
@@ -0,0 +1,71 @@
+// 文件: internal/auth/limiter.go
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold 是触发清理过期记录的条目数，避免大量不同来源的失败尝试让内存无限增长。
+const sweepThreshold = 1024
+
+// Limiter 在固定时间窗口内统计每个键 (如用户名和 IP) 的失败次数，
+// 达到上限后拒绝该键的后续尝试，直到窗口结束。记录只保存在内存中，重启后清空。
+type Limiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*failureWindow
+}
+
+type failureWindow struct {
+	count int
+	start time.Time
+}
+
+// NewLimiter 创建一个在 window 内最多允许 max 次失败的限制器。
+func NewLimiter(max int, window time.Duration) *Limiter {
+	return &Limiter{max: max, window: window, failures: make(map[string]*failureWindow)}
+}
+
+// Blocked 返回 key 还需要等待多久才能再次尝试，返回 0 表示可以尝试。
+func (l *Limiter) Blocked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[key]
+	if !ok || f.count < l.max {
+		return 0
+	}
+	if wait := time.Until(f.start.Add(l.window)); wait > 0 {
+		return wait
+	}
+	delete(l.failures, key)
+	return 0
+}
+
+// Fail 记录 key 的一次失败尝试。窗口从第一次失败开始计算，过期后重新计数。
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.failures) >= sweepThreshold {
+		for k, f := range l.failures {
+			if now.Sub(f.start) >= l.window {
+				delete(l.failures, k)
+			}
+		}
+	}
+	f, ok := l.failures[key]
+	if !ok || now.Sub(f.start) >= l.window {
+		l.failures[key] = &failureWindow{count: 1, start: now}
+		return
+	}
+	f.count++
+}
+
+// Reset 清除 key 的失败记录，在登录成功后调用。
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
@@ -24,6 +24,13 @@ type Series struct {
 	// Name 是该系列的名称，。
 	Name string `bson:"name"`
 
+	// SortName 是用于排序和归档的名称：默认为 Name 的罗马字 (拼音 / 平文式罗马字) 小写形式，
+	// 设置了 SortNameOverride 时为它的小写形式。由存储层在写入时维护。
+	SortName string `bson:"sortName,omitempty"`
+
+	// SortNameOverride 是手动指定的排序名，用于修正自动罗马字不准确的系列 (例如日文汉字)。
+	SortNameOverride string `bson:"sortNameOverride,omitempty"`
+
 	// Path 是该系列在文件系统上的原始路径，用于扫描器定位。
 	Path string `bson:"path"`
 
@@ -33,6 +40,9 @@ type Series struct {
 	// 系列目录下第一张图片的缩略图
 	Thumbnail string `bson:"thumbnail,omitempty"`
 
+	// Source 取自系列中第一张带下载器元数据的图片，其中的作者和标签用于 artist 布局和智能集合。
+	Source *SourceInfo `bson:"source,omitempty"`
+
 	// 嵌入Timestamps结构体，自动获得 CreatedAt 和 UpdatedAt 字段。
 	Timestamps
 }
@@ -60,6 +70,355 @@ type Image struct {
 	// Thumbnail 字段可以存储缩略图的信息，一个Base64编码的字符串。
 	Thumbnail string `bson:"thumbnail"`
 
+	// 以下是入库时解码图片得到的技术元数据。
+	Width  int `bson:"width"`
+	Height int `bson:"height"`
+
+	// Format 是解码器识别出的格式 (jpeg, png, gif, webp ...)，与扩展名无关。
+	Format string `bson:"format,omitempty"`
+
+	// FileSize 是文件字节数。
+	FileSize int64 `bson:"fileSize"`
+
+	// ColorModel 是解码后的色彩模型，例如 YCbCr、NRGBA、Paletted。
+	ColorModel string `bson:"colorModel,omitempty"`
+
+	// FrameCount 是动画帧数，非动画图片为 1。
+	FrameCount int `bson:"frameCount,omitempty"`
+
+	// MediaType 区分静态图片、动图和视频，见 MediaType* 常量。
+	// 旧文档没有该字段，应视为 image。
+	MediaType string `bson:"mediaType,omitempty"`
+
+	// Duration 是动图或视频的时长 (秒)，静态图片为 0。
+	Duration float64 `bson:"duration,omitempty"`
+
+	// Codec 是视频轨道的编码 (avc1、V_VP9 ...)，仅视频有值。
+	Codec string `bson:"codec,omitempty"`
+
+	// EXIF 仅在文件包含 EXIF 时存在。
+	EXIF *ImageEXIF `bson:"exif,omitempty"`
+
+	// Source 来自下载器写在文件旁边的 .json 元数据，没有元数据文件时不存在。
+	Source *SourceInfo `bson:"source,omitempty"`
+
 	// 嵌入Timestamps结构体。
 	Timestamps
 }
+
+// ImageEXIF 保存从图片 EXIF 中提取的常用字段。
+type ImageEXIF struct {
+	CameraMake  string     `bson:"cameraMake,omitempty"`
+	CameraModel string     `bson:"cameraModel,omitempty"`
+	Software    string     `bson:"software,omitempty"`
+	DateTaken   *time.Time `bson:"dateTaken,omitempty"`
+
+	// Orientation 是 EXIF 方向标签 (1-8)，1 表示无需旋转。
+	Orientation int `bson:"orientation,omitempty"`
+}
+
+// SourceInfo 保存下载器 (gallery-dl、pixiv 下载器等) 元数据中的来源信息。
+type SourceInfo struct {
+	URL      string     `bson:"url,omitempty"`
+	Title    string     `bson:"title,omitempty"`
+	Author   string     `bson:"author,omitempty"`
+	Tags     []string   `bson:"tags,omitempty"`
+	PostedAt *time.Time `bson:"postedAt,omitempty"`
+}
+
+// 媒体类型，用于 Image.MediaType。
+const (
+	MediaTypeImage     = "image"
+	MediaTypeAnimation = "animation"
+	MediaTypeVideo     = "video"
+)
+
+// 图片画面方向，用于 ImageFilter.Orientation。
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+	OrientationSquare    = "square"
+)
+
+// ImageFilter 描述图片列表上的筛选条件，nil/空值表示不限制。
+type ImageFilter struct {
+	SeriesID *primitive.ObjectID
+
+	MinWidth  *int
+	MaxWidth  *int
+	MinHeight *int
+	MaxHeight *int
+
+	// Orientation 取值 portrait / landscape / square，按宽高比较得出。
+	Orientation string
+
+	Format string
+
+	// Animated 为 true 时只返回多帧图片，为 false 时只返回单帧图片。
+	Animated *bool
+
+	// MediaType 取值 image / animation / video。
+	MediaType string
+
+	// Author / SourceTag 按下载器元数据中的作者和原始标签精确筛选。
+	Author    string
+	SourceTag string
+}
+
+// SmartQuery 描述一组可持久化的筛选条件，所有非空条件之间为“与”关系。
+// 区间类字段使用指针，nil 表示不限制。
+type SmartQuery struct {
+	// Text 对系列名称做不区分大小写的模糊匹配。
+	Text string `bson:"text,omitempty" json:"text,omitempty"`
+
+	// Tags 要求系列的下载器元数据 (Source.Tags) 同时包含全部标签。
+	Tags []string `bson:"tags,omitempty" json:"tags,omitempty"`
+
+	// CreatedAfter / CreatedBefore 限定系列的入库时间范围。
+	CreatedAfter  *time.Time `bson:"createdAfter,omitempty" json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `bson:"createdBefore,omitempty" json:"createdBefore,omitempty"`
+
+	// MinImageCount / MaxImageCount 限定系列的图片数量范围。
+	MinImageCount *int `bson:"minImageCount,omitempty" json:"minImageCount,omitempty"`
+	MaxImageCount *int `bson:"maxImageCount,omitempty" json:"maxImageCount,omitempty"`
+
+	// 分辨率条件作用于图片：系列中至少有一张图片满足条件即视为命中。
+	MinWidth  *int `bson:"minWidth,omitempty" json:"minWidth,omitempty"`
+	MaxWidth  *int `bson:"maxWidth,omitempty" json:"maxWidth,omitempty"`
+	MinHeight *int `bson:"minHeight,omitempty" json:"minHeight,omitempty"`
+	MaxHeight *int `bson:"maxHeight,omitempty" json:"maxHeight,omitempty"`
+
+	// SimilarToImageID 指向库中的一张图片，只保留包含与其感知哈希相同图片的系列。
+	SimilarToImageID *primitive.ObjectID `bson:"similarToImageId,omitempty" json:"similarToImageId,omitempty"`
+
+	// 以下条件作用于 userState 集合中的个人状态。
+	Favorite  *bool `bson:"favorite,omitempty" json:"favorite,omitempty"`
+	Unread    *bool `bson:"unread,omitempty" json:"unread,omitempty"`
+	MinRating *int  `bson:"minRating,omitempty" json:"minRating,omitempty"`
+
+	// UserID 指定个人状态条件按哪个用户计算。它不随查询保存，
+	// 由 API 层在求值时填入当前登录用户，因此同一个共享集合对每个人显示各自的结果。
+	UserID primitive.ObjectID `bson:"-" json:"-"`
+}
+
+// SmartCollection 是一个已保存的查询（智能集合），对应 "smartCollections" 集合中的一个文档。
+// 它只保存条件本身，成员在每次访问时实时计算。
+type SmartCollection struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+
+	// Name 是集合的显示名称，全局唯一，便于团队共享。
+	Name string `bson:"name"`
+
+	Description string `bson:"description,omitempty"`
+
+	Query SmartQuery `bson:"query"`
+
+	Timestamps
+}
+
+// SeriesState 记录用户对某个系列的个人状态（评分、收藏、阅读进度），对应 "userState" 集合中的一个文档。
+// 它与 Series 分开存放，重新入库时 series 文档被 upsert 也不会影响这些数据。
+type SeriesState struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+
+	// UserID 指向状态所属的用户。未启用认证时为零值。
+	UserID primitive.ObjectID `bson:"userId"`
+
+	// SeriesID 指向所属的 Series 文档，每个用户对每个系列最多一条状态记录。
+	SeriesID primitive.ObjectID `bson:"seriesId"`
+
+	// Rating 是 0-5 的星级评分，0 表示未评分。
+	Rating int `bson:"rating"`
+
+	Favorite bool `bson:"favorite"`
+
+	// Read 表示该系列是否已读完。
+	Read bool `bson:"read"`
+
+	// LastReadImageID / LastReadPage 记录最后阅读到的位置，用于“继续阅读”。
+	LastReadImageID *primitive.ObjectID `bson:"lastReadImageId,omitempty"`
+	LastReadPage    int                 `bson:"lastReadPage"`
+	LastReadAt      *time.Time          `bson:"lastReadAt,omitempty"`
+
+	Timestamps
+}
+
+// SeriesStateUpdate 描述一次对 SeriesState 的部分更新，nil 字段保持原值不变。
+type SeriesStateUpdate struct {
+	Rating          *int                `json:"rating,omitempty"`
+	Favorite        *bool               `json:"favorite,omitempty"`
+	Read            *bool               `json:"read,omitempty"`
+	LastReadImageID *primitive.ObjectID `json:"lastReadImageId,omitempty"`
+	LastReadPage    *int                `json:"lastReadPage,omitempty"`
+}
+
+// Role 定义了用户的权限级别。
+type Role string
+
+const (
+	// RoleAdmin 可以修改配置、启动扫描和删除内容。
+	RoleAdmin Role = "admin"
+	// RoleViewer 只能浏览、搜索以及维护自己的个人状态。
+	RoleViewer Role = "viewer"
+)
+
+// User 代表一个登录账号，对应 "users" 集合中的一个文档。
+type User struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+
+	// Username 全局唯一。
+	Username string `bson:"username"`
+
+	// PasswordHash 是 bcrypt 哈希，永远不会通过 API 返回。
+	PasswordHash string `bson:"passwordHash" json:"-"`
+
+	Role Role `bson:"role"`
+
+	Timestamps
+}
+
+// Session 代表 Web UI 的一次登录会话，对应 "sessions" 集合中的一个文档。
+// 数据库中只保存会话令牌的 SHA-256 哈希，过期文档由 TTL 索引自动清理。
+type Session struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	TokenHash string             `bson:"tokenHash"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// APIToken 是供脚本使用的个人访问令牌，对应 "apiTokens" 集合中的一个文档。
+// 明文令牌只在创建时返回一次，数据库中只保存其 SHA-256 哈希。
+type APIToken struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	UserID primitive.ObjectID `bson:"userId"`
+
+	// Name 是用户为令牌起的备注名，例如 "nightly-backup"。
+	Name string `bson:"name"`
+
+	// Prefix 是明文令牌的前几位，便于用户在列表中辨认。
+	Prefix    string     `bson:"prefix"`
+	TokenHash string     `bson:"tokenHash" json:"-"`
+	LastUsed  *time.Time `bson:"lastUsedAt,omitempty"`
+
+	Timestamps
+}
+
+// QuarantineKind 说明文件被隔离的原因。
+type QuarantineKind string
+
+const (
+	// QuarantineUndecodable 表示入库时无法解码的文件 (损坏、暂不支持的格式或读取不稳定的挂载点)。
+	QuarantineUndecodable QuarantineKind = "undecodable"
+	// QuarantineCollision 表示归档或聚合时与库中已有系列文件夹同名而无法移动的文件夹。
+	QuarantineCollision QuarantineKind = "collision"
+)
+
+// QuarantineItem 代表隔离区中的一个文件，对应 "quarantine" 集合中的一个文档。
+// 隔离区中的文件旁边还有一个同名的 .json sidecar，内容与该文档一致，
+// 即使数据库丢失也能据此手动恢复。
+type QuarantineItem struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Kind QuarantineKind     `bson:"kind"`
+
+	// OriginalPath 是文件被隔离前的位置，恢复时移回这里。
+	OriginalPath string `bson:"originalPath"`
+
+	// QuarantinedPath 是文件在隔离区中的当前位置。
+	QuarantinedPath string `bson:"quarantinedPath"`
+
+	// TargetPath 是发生冲突的库内文件夹，仅 collision 类型有值。
+	TargetPath string `bson:"targetPath,omitempty"`
+
+	// SourceSidecar 是随文件一起隔离的下载器元数据文件的原始位置，没有时为空。
+	// 它在隔离区中保存为 QuarantinedPath + ".source.json"，恢复时一起移回。
+	SourceSidecar string `bson:"sourceSidecar,omitempty"`
+
+	// IsDir 为 true 时隔离的是整个文件夹，FileSize 为其中所有文件大小之和。
+	IsDir bool `bson:"isDir"`
+
+	FileName string `bson:"fileName"`
+	FileHash string `bson:"fileHash,omitempty"`
+	FileSize int64  `bson:"fileSize"`
+
+	// Error 是导致隔离的原始错误信息。
+	Error string `bson:"error"`
+
+	// SeriesName 是文件被隔离时所属的系列，仅用于展示。
+	SeriesName string `bson:"seriesName,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// TrashItem 代表回收站中的一个文件或文件夹，对应 "trash" 集合中的一个文档。
+// 回收站按删除日期分目录存放，每个日期目录下的 manifest.jsonl 中也保留着同样的记录，
+// 即使数据库丢失也能据此手动找回。
+type TrashItem struct {
+	ID primitive.ObjectID `bson:"_id,omitempty"`
+
+	// OriginalPath 是被删除前的位置，恢复时移回这里。
+	OriginalPath string `bson:"originalPath"`
+
+	// TrashedPath 是在回收站中的当前位置。
+	TrashedPath string `bson:"trashedPath"`
+
+	IsDir    bool   `bson:"isDir"`
+	FileName string `bson:"fileName"`
+	FileSize int64  `bson:"fileSize"`
+
+	// Source 是发起删除的模块，例如 preprocess、api。
+	Source string `bson:"source"`
+	// Reason 说明删除的原因，仅用于展示。
+	Reason string `bson:"reason,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt"`
+	// ExpiresAt 之后该条目会被后台任务永久删除；零值表示永不过期。
+	ExpiresAt time.Time `bson:"expiresAt,omitempty"`
+}
+
+// ScanStage 是一次扫描运行已经完成的最后一个阶段。
+type ScanStage string
+
+const (
+	// ScanStageStarted 表示运行已开始，但分类尚未完成。
+	ScanStageStarted ScanStage = "started"
+	// ScanStageClassified 表示分类已完成，文件都已进入中转站。
+	ScanStageClassified ScanStage = "classified"
+	// ScanStageArchived 表示聚合归档已完成，Changelog 已记录，正在入库。
+	ScanStageArchived ScanStage = "archived"
+	// ScanStageCompleted 表示运行已全部完成。
+	ScanStageCompleted ScanStage = "completed"
+)
+
+// PathChange 是聚合归档时的一次文件夹移动。
+// 路径中可能含有 "."，不能作为 MongoDB 文档的键，因此 changelog 以列表形式保存。
+type PathChange struct {
+	From string `bson:"from"`
+	To   string `bson:"to"`
+}
+
+// ScanRun 记录一次扫描运行及其各阶段的检查点，对应 "scanRuns" 集合中的一个文档。
+// 进程在运行中途退出后，下一次扫描从最后完成的阶段继续，而不是重新处理或丢失已移动的文件。
+type ScanRun struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	ScanPath string             `bson:"scanPath"`
+	Stage    ScanStage          `bson:"stage"`
+
+	// --- 分类阶段的检查点 ---
+	// SeriesNames 是本次进入中转站的系列 (包括压缩包)。
+	// 文件名数量可能很大，为避免超出文档大小限制只记录数量。
+	SeriesNames    []string       `bson:"seriesNames"`
+	ProcessedFiles int            `bson:"processedFiles"`
+	Classifiers    map[string]int `bson:"classifiers"`
+	Unclassified   []string       `bson:"unclassified"`
+
+	// --- 聚合归档阶段的检查点 ---
+	Changelog []PathChange `bson:"changelog"`
+
+	// --- 入库阶段的检查点 ---
+	// IngestedSeries 是已经完成入库的最终系列路径，按批追加。
+	IngestedSeries []string `bson:"ingestedSeries"`
+
+	CompletedAt *time.Time `bson:"completedAt,omitempty"`
+	Timestamps
+}
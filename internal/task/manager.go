@@ -1,8 +1,11 @@
 package task
 
 import (
-	"PICs_Manager/config"      // [新增] 引入config包以使用配置类型
+	"PICs_Manager/config" // [新增] 引入config包以使用配置类型
+	"PICs_Manager/pkg/fsutil"
 	"PICs_Manager/pkg/scanner" // 引入scanner包
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -20,6 +23,12 @@ const (
 	StatusFailed    TaskStatus = "failed"
 )
 
+// ErrTaskRunning 表示有任务正在运行，此时不能重新加载配置。
+var ErrTaskRunning = errors.New("有任务正在运行，请等待其完成后再试")
+
+// ErrLibraryBusy 表示库中正在运行任务或整理操作，此时不能开始另一个会移动库内文件的操作。
+var ErrLibraryBusy = errors.New("库正忙，请等待当前的任务或整理操作完成后再试")
+
 // Task 结构体代表一个具体的后台任务。
 type Task struct {
 	ID        string     `json:"id"`
@@ -28,8 +37,15 @@ type Task struct {
 	Error     string     `json:"error,omitempty"`
 	StartTime time.Time  `json:"startTime"`
 	EndTime   *time.Time `json:"endTime,omitempty"`
+	// Library 是任务所属的库，每个库同时只能运行一个任务。
+	Library string `json:"library"`
+	// Result 是扫描任务 (以及从中转站继续的任务) 的摘要，包括无法分类的文件。
+	Result *scanner.ScanReport `json:"result,omitempty"`
+	// Returned 是把中转站退回扫描目录的任务的结果。
+	Returned *scanner.StagingReturn `json:"returned,omitempty"`
 
-	scanPath string
+	scanPath  string
+	syncPaths []string // 非空时表示这是一个只重新入库指定系列文件夹的同步任务
 }
 
 // Manager 结构体是任务管理器。
@@ -37,44 +53,123 @@ type Manager struct {
 	tasks map[string]*Task
 	mu    sync.RWMutex
 
-	scanner *scanner.Orchestrator
-	config  *config.Config // [新增] 注入对全局配置的引用
+	scanners map[string]*scanner.Orchestrator // 按库名索引
+	config   *config.Config
+	edits    map[string]int // 每个库正在进行的整理操作数 (重命名、合并、删除等)
 }
 
-// NewManager 创建并返回一个新的任务管理器实例。
-// [修正] 函数现在接收扫描器和配置实例作为参数。
-func NewManager(s *scanner.Orchestrator, cfg *config.Config) *Manager {
+// NewManager 创建并返回一个新的任务管理器实例，scanners 是每个库的扫描器，以库名为键。
+func NewManager(scanners map[string]*scanner.Orchestrator, cfg *config.Config) *Manager {
 	return &Manager{
-		tasks:   make(map[string]*Task),
-		scanner: s,
-		config:  cfg, // 存储配置实例
+		tasks:    make(map[string]*Task),
+		scanners: scanners,
+		config:   cfg,
+		edits:    make(map[string]int),
+	}
+}
+
+// StartNewScanTask 为库 library 创建一个新的扫描任务，并立即在后台启动它。
+func (m *Manager) StartNewScanTask(library, path string) (string, error) {
+	return m.start(&Task{Library: library, scanPath: path}, m.runScan)
+}
+
+// StartSyncTask 创建一个只对库 library 中指定的系列文件夹重新入库的任务，
+// 用于隔离区冲突解决等在扫描流程之外改动了库内容的操作。
+func (m *Manager) StartSyncTask(library string, paths []string) (string, error) {
+	return m.start(&Task{Library: library, syncPaths: paths}, m.runSync)
+}
+
+// StartStagingResumeTask 创建一个从中转站继续上次中断的扫描的任务：把库 library 中转站中的系列归档并入库。
+func (m *Manager) StartStagingResumeTask(library string) (string, error) {
+	return m.start(&Task{Library: library}, m.runStagingResume)
+}
+
+// StartStagingReturnTask 创建一个把库 library 中转站中的内容退回扫描目录的任务。
+func (m *Manager) StartStagingReturnTask(library string) (string, error) {
+	return m.start(&Task{Library: library}, m.runStagingReturn)
+}
+
+// start 在同一个库没有其他任务运行时登记任务并在后台执行 run。
+func (m *Manager) start(newTask *Task, run func(*Task)) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.scanners[newTask.Library]; !ok {
+		return "", fmt.Errorf("找不到库: %s", newTask.Library)
+	}
+	if task := m.activeTask(newTask.Library); task != nil {
+		return "", fmt.Errorf("%w: 库 %s 的另一个任务正在进行中 (ID: %s)", ErrLibraryBusy, task.Library, task.ID)
 	}
+	if m.edits[newTask.Library] > 0 {
+		return "", fmt.Errorf("%w: 库 %s 正在进行整理操作", ErrLibraryBusy, newTask.Library)
+	}
+
+	newTask.ID = uuid.New().String()
+	newTask.Status = StatusPending
+	newTask.StartTime = time.Now()
+	m.tasks[newTask.ID] = newTask
+
+	go run(newTask)
+
+	return newTask.ID, nil
 }
 
-// StartNewScanTask 创建一个新的扫描任务，并立即在后台启动它。
-func (m *Manager) StartNewScanTask(path string) (string, error) {
+// BeginEdit 在库 library 没有任务运行时登记一次整理操作，有任务运行时返回 ErrLibraryBusy。
+// 登记期间该库不能启动新任务，操作结束后必须调用返回的 done。
+func (m *Manager) BeginEdit(library string) (done func(), err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if task := m.activeTask(library); task != nil {
+		return nil, fmt.Errorf("%w: 库 %s 的任务正在进行中 (ID: %s)", ErrLibraryBusy, library, task.ID)
+	}
+	m.edits[library]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.edits[library]--
+		})
+	}, nil
+}
+
+// activeTask 返回库 library 中还未结束的任务，没有时返回 nil。调用方需持有锁。
+func (m *Manager) activeTask(library string) *Task {
 	for _, task := range m.tasks {
-		if task.Status == StatusRunning {
-			return "", fmt.Errorf("另一个扫描任务正在进行中 (ID: %s)，请等待其完成后再试", task.ID)
+		if task.Library == library && task.active() {
+			return task
 		}
 	}
+	return nil
+}
 
-	taskID := uuid.New().String()
-	newTask := &Task{
-		ID:        taskID,
-		Status:    StatusPending,
-		Progress:  0,
-		StartTime: time.Now(),
-		scanPath:  path,
+// Reload 在所有库都没有任务运行时用 rebuild 创建的扫描器和 cfg 替换当前的扫描器和配置，有任务运行时返回 ErrTaskRunning。
+// rebuild 在持有锁时执行，期间不会有新任务启动；rebuild 失败时保留原有的扫描器和配置。
+func (m *Manager) Reload(cfg *config.Config, rebuild func() (map[string]*scanner.Orchestrator, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, task := range m.tasks {
+		if task.active() {
+			return fmt.Errorf("%w (库: %s, ID: %s)", ErrTaskRunning, task.Library, task.ID)
+		}
 	}
-	m.tasks[taskID] = newTask
 
-	go m.runScan(newTask)
+	next, err := rebuild()
+	if err != nil {
+		return err
+	}
+	for _, s := range m.scanners {
+		s.Close()
+	}
+	m.scanners, m.config = next, cfg
+	return nil
+}
 
-	return taskID, nil
+// active 判断任务是否还未结束。
+func (t *Task) active() bool {
+	return t.Status == StatusPending || t.Status == StatusRunning
 }
 
 // GetTaskStatus 根据任务ID检索特定任务的当前状态。
@@ -96,30 +191,87 @@ func (m *Manager) runScan(task *Task) {
 	task.Status = StatusRunning
 	m.mu.Unlock()
 
-	fmt.Printf("任务启动: %s, 扫描路径: %s\n", task.ID, task.scanPath)
+	fmt.Printf("任务启动: %s, 库: %s, 扫描路径: %s\n", task.ID, task.Library, task.scanPath)
 
 	m.mu.Lock()
 	task.Progress = 50.0
 	m.mu.Unlock()
 
 	// [修正] 创建一个此任务专用的扫描配置，并用任务的路径覆盖默认扫描路径。
-	taskScannerConfig := m.config.Scanner
+	lib, _ := m.config.Library(task.Library)
+	taskScannerConfig := lib.Scanner
 	taskScannerConfig.ScanPath = task.scanPath
 
 	// [修正] 调用真实的扫描器逻辑。
-	// 根据 cli/main.go 的用法，RunFullScan 接收一个配置且不返回错误。
-	// 注意：由于 RunFullScan 不返回错误，我们无法在此处捕获具体的执行失败。
-	// 任务状态将直接变为 "completed"。一个更健壮的实现需要 RunFullScan 返回一个 error。
-	m.scanner.RunFullScan(taskScannerConfig)
+	report, err := m.scanners[task.Library].RunFullScan(taskScannerConfig)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// 由于无法从 RunFullScan 捕获错误，我们直接将任务标记为完成。
-	task.Status = StatusCompleted
-	task.Progress = 100
-	fmt.Printf("任务 %s 已执行，标记为完成\n", task.ID)
+	task.Result = report
+	m.finish(task, err)
+	fmt.Printf("任务 %s 已执行，状态: %s\n", task.ID, task.Status)
+}
+
+// runSync 是执行同步任务的内部函数。
+func (m *Manager) runSync(task *Task) {
+	m.mu.Lock()
+	task.Status = StatusRunning
+	m.mu.Unlock()
+
+	fmt.Printf("同步任务启动: %s, 库: %s, 系列文件夹: %v\n", task.ID, task.Library, task.syncPaths)
+	lib, _ := m.config.Library(task.Library)
+	err := m.scanners[task.Library].SyncSeriesPaths(context.Background(), lib.Scanner.FinalLibraryPath, task.syncPaths)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
+	m.finish(task, err)
+}
+
+// runStagingResume 是从中转站继续扫描的内部函数。
+func (m *Manager) runStagingResume(task *Task) {
+	m.mu.Lock()
+	task.Status = StatusRunning
+	m.mu.Unlock()
+
+	fmt.Printf("中转站继续任务启动: %s, 库: %s\n", task.ID, task.Library)
+	lib, _ := m.config.Library(task.Library)
+	report, err := m.scanners[task.Library].ResumeStaging(context.Background(), lib.Scanner)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task.Result = report
+	m.finish(task, err)
+}
+
+// runStagingReturn 是把中转站退回扫描目录的内部函数。
+func (m *Manager) runStagingReturn(task *Task) {
+	m.mu.Lock()
+	task.Status = StatusRunning
+	m.mu.Unlock()
+
+	fmt.Printf("中转站退回任务启动: %s, 库: %s\n", task.ID, task.Library)
+	lib, _ := m.config.Library(task.Library)
+	result, err := scanner.ReturnStaging(fsutil.OS, lib.Scanner.StagingPath, lib.Scanner.ScanPath)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task.Returned = result
+	m.finish(task, err)
+}
+
+// finish 按 err 把任务标记为完成或失败，调用方需持有锁。
+func (m *Manager) finish(task *Task, err error) {
+	if err != nil {
+		task.Status = StatusFailed
+		task.Error = err.Error()
+	} else {
+		task.Status = StatusCompleted
+	}
+	task.Progress = 100
 	endTime := time.Now()
 	task.EndTime = &endTime
 }
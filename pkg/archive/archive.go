@@ -0,0 +1,244 @@
+// Package archive 让 ZIP/CBZ 压缩包可以作为一个系列被原地索引。
+// 压缩包内条目的路径写作 "<压缩包路径>!/<条目名>"，例如 "/lib/A/Foo/foo.cbz!/001.jpg"，
+// 凡是需要按 Image.FilePath 读取文件内容的地方都应通过 ReadFile/Open 读取，而不是直接使用 os 包。
+package archive
+
+import (
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Separator 分隔压缩包路径与包内条目名。
+const Separator = "!/"
+
+// comicInfoName 是 ComicRack 约定的元数据文件名。
+const comicInfoName = "ComicInfo.xml"
+
+// maxEntrySize 是读取单个条目时允许的最大解压后大小，防止构造的压缩包 (zip 炸弹) 耗尽内存。
+const maxEntrySize = 256 << 20
+
+// ErrEntryNotFound 表示压缩包中不存在指定条目。
+var ErrEntryNotFound = errors.New("压缩包中不存在该条目")
+
+// ErrEntryTooLarge 表示条目解压后超过 maxEntrySize。
+var ErrEntryTooLarge = fmt.Errorf("压缩包条目解压后超过 %d MiB", maxEntrySize>>20)
+
+var archiveExtensions = map[string]struct{}{
+	".zip": {},
+	".cbz": {},
+}
+
+// IsArchiveExtension 判断文件是否是可被索引的压缩包 (按扩展名，不读取内容)。
+func IsArchiveExtension(p string) bool {
+	_, ok := archiveExtensions[strings.ToLower(filepath.Ext(p))]
+	return ok
+}
+
+// JoinPath 拼接压缩包路径与包内条目名。
+func JoinPath(archivePath, entry string) string {
+	return archivePath + Separator + entry
+}
+
+// SplitPath 拆分压缩包条目路径；普通文件路径返回 ok=false。
+func SplitPath(p string) (archivePath, entry string, ok bool) {
+	idx := strings.Index(p, Separator)
+	if idx < 0 || !IsArchiveExtension(p[:idx]) {
+		return "", "", false
+	}
+	return p[:idx], p[idx+len(Separator):], true
+}
+
+// FileName 返回一个在系列目录内唯一的文件名：
+// 普通文件为基本名，压缩包条目为 "<压缩包文件名>!/<条目名>"。
+func FileName(p string) string {
+	if archivePath, entry, ok := SplitPath(p); ok {
+		return JoinPath(filepath.Base(archivePath), entry)
+	}
+	return filepath.Base(p)
+}
+
+// ReadFile 读取普通文件或压缩包条目的全部内容。
+func ReadFile(p string) ([]byte, error) {
+	return ReadFileFS(fsutil.OS, p)
+}
+
+// ReadFileFS 与 ReadFile 相同，只是从 fsys 中读取。
+func ReadFileFS(fsys fsutil.FS, p string) ([]byte, error) {
+	archivePath, entry, ok := SplitPath(p)
+	if !ok {
+		return fsutil.ReadFile(fsys, p)
+	}
+	r, err := OpenReaderFS(fsys, archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开压缩包 %s 失败: %w", archivePath, err)
+	}
+	defer r.Close()
+	f := findEntry(r.zr, entry)
+	if f == nil {
+		return nil, fmt.Errorf("%w: %s", ErrEntryNotFound, p)
+	}
+	return readEntry(f)
+}
+
+// Open 打开普通文件或压缩包条目。压缩包条目会被整体读入内存，以便调用方可以 Seek。
+func Open(p string) (io.ReadSeekCloser, error) {
+	if _, _, ok := SplitPath(p); !ok {
+		return os.Open(p)
+	}
+	data, err := ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+type nopCloser struct{ *bytes.Reader }
+
+func (nopCloser) Close() error { return nil }
+
+// Entry 是压缩包中的一个媒体条目。
+type Entry struct {
+	Name string
+	file *zip.File
+}
+
+// Read 读取条目内容。
+func (e Entry) Read() ([]byte, error) {
+	return readEntry(e.file)
+}
+
+// Reader 是一个已打开的压缩包，用于一次性遍历其中的所有图片，避免逐条目重复解析目录。
+type Reader struct {
+	zr   *zip.Reader
+	file io.Closer
+}
+
+// OpenReader 打开压缩包。
+func OpenReader(archivePath string) (*Reader, error) {
+	return OpenReaderFS(fsutil.OS, archivePath)
+}
+
+// OpenReaderFS 与 OpenReader 相同，只是从 fsys 中打开压缩包。
+func OpenReaderFS(fsys fsutil.FS, archivePath string) (*Reader, error) {
+	f, err := fsys.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Reader{zr: zr, file: f}, nil
+}
+
+// Close 关闭压缩包。
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// Images 返回压缩包中所有在图片允许列表内的条目，按条目名排序 (即阅读顺序)。
+// 以 "." 或 "__MACOSX" 开头的系统垃圾文件会被忽略。
+func (r *Reader) Images() []Entry {
+	var entries []Entry
+	for _, f := range r.zr.File {
+		if f.FileInfo().IsDir() || isJunk(f.Name) || !formats.IsImageExtension(f.Name) {
+			continue
+		}
+		entries = append(entries, Entry{Name: f.Name, file: f})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// ComicInfo 是 ComicInfo.xml 中与系列命名相关的字段。
+type ComicInfo struct {
+	Series string `xml:"Series"`
+	Title  string `xml:"Title"`
+}
+
+// ComicInfo 读取压缩包根目录下的 ComicInfo.xml，不存在时返回 nil。
+func (r *Reader) ComicInfo() (*ComicInfo, error) {
+	f := findEntry(r.zr, comicInfoName)
+	if f == nil {
+		return nil, nil
+	}
+	data, err := readEntry(f)
+	if err != nil {
+		return nil, err
+	}
+	var info ComicInfo
+	if err := xml.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", comicInfoName, err)
+	}
+	return &info, nil
+}
+
+// SeriesName 决定压缩包对应的系列名：优先使用 ComicInfo.xml 的 Series (其次 Title)，
+// 否则使用去掉扩展名的压缩包文件名。返回值未做文件名清理，由调用方负责。
+func (r *Reader) SeriesName(archivePath string) string {
+	if info, err := r.ComicInfo(); err == nil && info != nil {
+		for _, name := range []string{info.Series, info.Title} {
+			if name = strings.TrimSpace(name); name != "" {
+				return name
+			}
+		}
+	}
+	base := filepath.Base(archivePath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func findEntry(r *zip.Reader, name string) *zip.File {
+	for _, f := range r.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// readEntry 读取条目的全部内容。头部声明的大小可以伪造，因此除了检查 UncompressedSize64，
+// 还限制实际读取的字节数，超过 maxEntrySize 时返回 ErrEntryTooLarge。
+func readEntry(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxEntrySize {
+		return nil, fmt.Errorf("%s: %w", f.Name, ErrEntryTooLarge)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEntrySize {
+		return nil, fmt.Errorf("%s: %w", f.Name, ErrEntryTooLarge)
+	}
+	return data, nil
+}
+
+func isJunk(name string) bool {
+	for _, part := range strings.Split(path.Clean(name), "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
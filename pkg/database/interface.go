@@ -5,16 +5,29 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"time"
 )
 
 // Store 是一个顶层接口，它组合了所有特定数据模型的存储接口。
 type Store interface {
 	Series() SeriesStore
 	Images() ImageStore
+	SmartCollections() SmartCollectionStore
+	UserState() UserStateStore
+	Users() UserStore
+	Sessions() SessionStore
+	APITokens() APITokenStore
+	Quarantine() QuarantineStore
+	Trash() TrashStore
+	ScanRuns() ScanRunStore
 	EnsureIndexes(ctx context.Context) error
 	CheckSeriesCompleteness(ctx context.Context, seriesID primitive.ObjectID) (isComplete bool, expected int, actual int64, err error)
 	FindMissingFiles(ctx context.Context, series *models.Series) (missingFileNames []string, err error)
 	DropAllCollections(ctx context.Context) error
+	QuerySeries(ctx context.Context, query *models.SmartQuery, page, limit int) (seriesList []models.Series, total int64, err error)
+	// Library 返回一个库的 Store：内容集合位于 database 数据库 (为空时使用当前数据库) 中并加上 collectionPrefix 前缀，
+	// 用户、会话和 API 令牌仍与当前 Store 共用。
+	Library(database, collectionPrefix string) Store
 }
 
 // SeriesStore 定义了所有与 Series 模型相关的数据库操作。
@@ -44,11 +57,94 @@ type ImageStore interface {
 	SearchByName(ctx context.Context, query string, page, limit int) ([]models.Image, int64, error)
 	FindSimilarByPHash(ctx context.Context, pHash string, limit int) ([]models.Image, error)
 	Delete(ctx context.Context, id primitive.ObjectID) error
+	DeleteBySeriesID(ctx context.Context, seriesID primitive.ObjectID) (int64, error)
 	CountBySeriesID(ctx context.Context, seriesID primitive.ObjectID) (int64, error)
 	BulkWrite(ctx context.Context, models []mongo.WriteModel) error
 	FindImagesByPathPrefix(ctx context.Context, pathPrefix string) ([]models.Image, error)
 	GetFirstImage(ctx context.Context, seriesID primitive.ObjectID) (*models.Image, error)
+	GetFirstWithSource(ctx context.Context, seriesID primitive.ObjectID) (*models.Image, error)
 	GetAllByFileName(ctx context.Context, fileName string) ([]models.Image, error)
 	UpdateMetadataByPath(ctx context.Context, filePath, fileHash, pHash, thumbnail string) error
 	GetAllBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]models.Image, error)
+	Query(ctx context.Context, filter *models.ImageFilter, page, limit int) ([]models.Image, int64, error)
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.Image, error)
+	FindWithoutEXIF(ctx context.Context) ([]models.Image, error)
+	SetEXIF(ctx context.Context, id primitive.ObjectID, exif *models.ImageEXIF) error
+	UpdateThumbnail(ctx context.Context, id primitive.ObjectID, thumbnail string, width, height int) error
+}
+
+// SmartCollectionStore 定义了所有与 SmartCollection (已保存查询) 模型相关的数据库操作。
+type SmartCollectionStore interface {
+	Create(ctx context.Context, collection *models.SmartCollection) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.SmartCollection, error)
+	List(ctx context.Context) ([]models.SmartCollection, error)
+	Update(ctx context.Context, collection *models.SmartCollection) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// UserStateStore 定义了所有与 SeriesState (个人评分/收藏/阅读进度) 模型相关的数据库操作。
+// 所有方法都以用户为维度，不同用户的状态互不影响。
+type UserStateStore interface {
+	GetBySeriesID(ctx context.Context, userID, seriesID primitive.ObjectID) (*models.SeriesState, error)
+	Upsert(ctx context.Context, userID, seriesID primitive.ObjectID, update *models.SeriesStateUpdate) (*models.SeriesState, error)
+	DeleteBySeriesID(ctx context.Context, seriesID primitive.ObjectID) error
+}
+
+// UserStore 定义了所有与 User 模型相关的数据库操作。
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	CountByRole(ctx context.Context, role models.Role) (int64, error)
+}
+
+// SessionStore 定义了所有与 Web 登录会话相关的数据库操作。
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	DeleteByTokenHash(ctx context.Context, tokenHash string) error
+	DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// APITokenStore 定义了所有与个人 API 令牌相关的数据库操作。
+type APITokenStore interface {
+	Create(ctx context.Context, token *models.APIToken) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.APIToken, error)
+	ListByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.APIToken, error)
+	Delete(ctx context.Context, userID, id primitive.ObjectID) error
+	DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error
+	TouchLastUsed(ctx context.Context, id primitive.ObjectID) error
+}
+
+// QuarantineStore 定义了所有与隔离区记录相关的数据库操作。
+// 它只负责记录本身，文件的移动由 pkg/quarantine 完成。
+type QuarantineStore interface {
+	Create(ctx context.Context, item *models.QuarantineItem) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error)
+	List(ctx context.Context, kind models.QuarantineKind, page, limit int) ([]models.QuarantineItem, int64, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// TrashStore 定义了所有与回收站记录相关的数据库操作。
+// 它只负责记录本身，文件的移动由 pkg/trash 完成。
+type TrashStore interface {
+	Create(ctx context.Context, item *models.TrashItem) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error)
+	List(ctx context.Context, page, limit int) ([]models.TrashItem, int64, error)
+	ListExpired(ctx context.Context, before time.Time) ([]models.TrashItem, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// ScanRunStore 定义了所有与扫描运行记录 (各阶段的检查点) 相关的数据库操作。
+type ScanRunStore interface {
+	Create(ctx context.Context, run *models.ScanRun) error
+	// FindUnfinished 返回最近一次尚未完成的运行，没有时返回 nil。
+	FindUnfinished(ctx context.Context) (*models.ScanRun, error)
+	// Update 保存运行的当前阶段及其检查点，IngestedSeries 除外 (由 AddIngestedSeries 追加)。
+	Update(ctx context.Context, run *models.ScanRun) error
+	// AddIngestedSeries 追加一批已完成入库的系列路径。
+	AddIngestedSeries(ctx context.Context, id primitive.ObjectID, seriesPaths []string) error
 }
@@ -0,0 +1,17 @@
+package database
+
+import "PICs_Manager/config"
+
+// LibraryStores 返回 cfg 中每个库的 Store，以库名为键，都与 root 共用连接以及用户、会话和 API 令牌。
+// 没有配置 libraries 时唯一的 default 库直接使用 root。
+func LibraryStores(root Store, cfg *config.Config) map[string]Store {
+	stores := make(map[string]Store)
+	for _, lib := range cfg.LibraryConfigs() {
+		if len(cfg.Libraries) == 0 {
+			stores[lib.Name] = root
+			continue
+		}
+		stores[lib.Name] = root.Library(lib.Database, lib.CollectionPrefix)
+	}
+	return stores
+}
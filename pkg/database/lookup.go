@@ -0,0 +1,20 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetExisting 用 get 按 ID 读取一条记录，记录不存在 (get 返回 nil) 时返回 notFound。
+// 回收站和隔离区等按 ID 操作记录的模块用它区分"记录不存在"和查询失败。
+func GetExisting[T any](ctx context.Context, get func(context.Context, primitive.ObjectID) (*T, error), id primitive.ObjectID, notFound error) (*T, error) {
+	item, err := get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, notFound
+	}
+	return item, nil
+}
@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Query 按技术元数据筛选图片并分页。limit <= 0 时返回全部匹配结果。
+// 指定了 SeriesID 时按文件名排序 (与系列内浏览一致)，否则按 _id 排序。
+func (i *imageStore) Query(ctx context.Context, filter *models.ImageFilter, page, limit int) ([]models.Image, int64, error) {
+	imageList := []models.Image{}
+	mongoFilter, err := buildImageFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOpts := options.Find()
+	if filter != nil && filter.SeriesID != nil {
+		findOpts.SetSort(bson.D{{Key: "fileName", Value: 1}})
+	} else {
+		findOpts.SetSort(bson.D{{Key: "_id", Value: 1}})
+	}
+	if limit > 0 {
+		if page < 1 {
+			page = 1
+		}
+		findOpts.SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	}
+
+	cursor, err := i.coll.Find(ctx, mongoFilter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &imageList); err != nil {
+		return nil, 0, err
+	}
+	total, err := i.coll.CountDocuments(ctx, mongoFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return imageList, total, nil
+}
+
+// buildImageFilter 将 ImageFilter 翻译为 images 集合上的查询条件。
+func buildImageFilter(filter *models.ImageFilter) (bson.M, error) {
+	mongoFilter := bson.M{}
+	if filter == nil {
+		return mongoFilter, nil
+	}
+	if filter.SeriesID != nil {
+		mongoFilter["seriesId"] = *filter.SeriesID
+	}
+	if widthRange := intRange(filter.MinWidth, filter.MaxWidth); widthRange != nil {
+		mongoFilter["width"] = widthRange
+	}
+	if heightRange := intRange(filter.MinHeight, filter.MaxHeight); heightRange != nil {
+		mongoFilter["height"] = heightRange
+	}
+	if filter.Format != "" {
+		mongoFilter["format"] = filter.Format
+	}
+	if filter.Author != "" {
+		mongoFilter["source.author"] = filter.Author
+	}
+	if filter.SourceTag != "" {
+		mongoFilter["source.tags"] = filter.SourceTag
+	}
+	if filter.Animated != nil {
+		if *filter.Animated {
+			mongoFilter["frameCount"] = bson.M{"$gt": 1}
+		} else {
+			// 旧文档可能没有 frameCount 字段，同样视为单帧
+			mongoFilter["frameCount"] = bson.M{"$not": bson.M{"$gt": 1}}
+		}
+	}
+
+	switch filter.MediaType {
+	case "":
+	case models.MediaTypeImage:
+		// 旧文档没有 mediaType 字段，同样视为静态图片
+		mongoFilter["mediaType"] = bson.M{"$in": bson.A{models.MediaTypeImage, nil}}
+	case models.MediaTypeAnimation, models.MediaTypeVideo:
+		mongoFilter["mediaType"] = filter.MediaType
+	default:
+		return nil, fmt.Errorf("无效的媒体类型: %s", filter.MediaType)
+	}
+
+	switch filter.Orientation {
+	case "":
+	case models.OrientationPortrait:
+		mongoFilter["$expr"] = bson.M{"$gt": bson.A{"$height", "$width"}}
+	case models.OrientationLandscape:
+		mongoFilter["$expr"] = bson.M{"$gt": bson.A{"$width", "$height"}}
+	case models.OrientationSquare:
+		mongoFilter["$expr"] = bson.M{"$eq": bson.A{"$width", "$height"}}
+		// 尚未回填尺寸的旧文档宽高都为 0，不应被当作正方形
+		if _, ok := mongoFilter["width"]; !ok {
+			mongoFilter["width"] = bson.M{"$gt": 0}
+		}
+	default:
+		return nil, fmt.Errorf("无效的画面方向: %s", filter.Orientation)
+	}
+	return mongoFilter, nil
+}
@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// quarantineStore 封装了与 "quarantine" 集合相关的所有操作。
+type quarantineStore struct {
+	coll *mongo.Collection
+}
+
+// --- quarantineStore 方法实现 ---
+
+func (q *quarantineStore) Create(ctx context.Context, item *models.QuarantineItem) error {
+	if item.ID.IsZero() {
+		item.ID = primitive.NewObjectID()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	_, err := q.coll.InsertOne(ctx, item)
+	return err
+}
+
+func (q *quarantineStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	var item models.QuarantineItem
+	err := q.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List 按隔离时间倒序分页返回隔离记录，kind 为空时返回所有类型。
+func (q *quarantineStore) List(ctx context.Context, kind models.QuarantineKind, page, limit int) ([]models.QuarantineItem, int64, error) {
+	items := []models.QuarantineItem{}
+	skip := (page - 1) * limit
+
+	filter := bson.M{}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	opts := options.Find().
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := q.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := q.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (q *quarantineStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := q.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,73 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// scanRunStore 封装了与 "scanRuns" 集合相关的所有操作。
+type scanRunStore struct {
+	coll *mongo.Collection
+}
+
+// --- scanRunStore 方法实现 ---
+
+func (s *scanRunStore) Create(ctx context.Context, run *models.ScanRun) error {
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	if run.IngestedSeries == nil {
+		run.IngestedSeries = []string{} // 保存为空数组而不是 null，否则 $push 会失败
+	}
+	now := time.Now()
+	run.CreatedAt, run.UpdatedAt = now, now
+	_, err := s.coll.InsertOne(ctx, run)
+	return err
+}
+
+// FindUnfinished 按创建时间倒序查找第一个未完成的运行。
+func (s *scanRunStore) FindUnfinished(ctx context.Context) (*models.ScanRun, error) {
+	var run models.ScanRun
+	filter := bson.M{"stage": bson.M{"$ne": models.ScanStageCompleted}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if err := s.coll.FindOne(ctx, filter, opts).Decode(&run); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (s *scanRunStore) Update(ctx context.Context, run *models.ScanRun) error {
+	run.UpdatedAt = time.Now()
+	update := bson.M{"$set": bson.M{
+		"scanPath":       run.ScanPath,
+		"stage":          run.Stage,
+		"seriesNames":    run.SeriesNames,
+		"processedFiles": run.ProcessedFiles,
+		"classifiers":    run.Classifiers,
+		"unclassified":   run.Unclassified,
+		"changelog":      run.Changelog,
+		"completedAt":    run.CompletedAt,
+		"updatedAt":      run.UpdatedAt,
+	}}
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": run.ID}, update)
+	return err
+}
+
+func (s *scanRunStore) AddIngestedSeries(ctx context.Context, id primitive.ObjectID, seriesPaths []string) error {
+	update := bson.M{
+		"$push": bson.M{"ingestedSeries": bson.M{"$each": seriesPaths}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
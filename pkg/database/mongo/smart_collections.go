@@ -0,0 +1,247 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// smartCollectionStore 封装了与 "smartCollections" 集合相关的所有操作。
+type smartCollectionStore struct {
+	coll *mongo.Collection
+}
+
+// --- smartCollectionStore 方法实现 ---
+
+func (c *smartCollectionStore) Create(ctx context.Context, collection *models.SmartCollection) error {
+	collection.ID = primitive.NewObjectID()
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = time.Now()
+	_, err := c.coll.InsertOne(ctx, collection)
+	return err
+}
+
+func (c *smartCollectionStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.SmartCollection, error) {
+	var collection models.SmartCollection
+	err := c.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&collection)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// List 按名称排序返回所有已保存的查询。智能集合数量通常很少，因此不做分页。
+func (c *smartCollectionStore) List(ctx context.Context) ([]models.SmartCollection, error) {
+	collections := []models.SmartCollection{}
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := c.coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (c *smartCollectionStore) Update(ctx context.Context, collection *models.SmartCollection) error {
+	collection.UpdatedAt = time.Now()
+	filter := bson.M{"_id": collection.ID}
+	update := bson.M{"$set": bson.M{
+		"name":        collection.Name,
+		"description": collection.Description,
+		"query":       collection.Query,
+		"updatedAt":   collection.UpdatedAt,
+	}}
+	res, err := c.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("智能集合 %s 不存在", collection.ID.Hex())
+	}
+	return nil
+}
+
+func (c *smartCollectionStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := c.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// QuerySeries 根据 SmartQuery 实时计算匹配的系列列表，并支持分页。
+// 系列级条件直接作用于 series 集合；分辨率、相似度等图片级条件和评分、收藏等个人状态条件
+// 先在 images / userState 集合中求出命中的 seriesId，再作为 _id 条件合并进系列查询。
+func (s *Store) QuerySeries(ctx context.Context, query *models.SmartQuery, page, limit int) ([]models.Series, int64, error) {
+	seriesList := []models.Series{}
+	skip := (page - 1) * limit
+
+	filter, err := s.buildSeriesFilter(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOpts := options.Find().
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "sortName", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := s.series.coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &seriesList); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.series.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return seriesList, total, nil
+}
+
+// buildSeriesFilter 将 SmartQuery 翻译为 series 集合上的查询条件。
+func (s *Store) buildSeriesFilter(ctx context.Context, query *models.SmartQuery) (bson.M, error) {
+	filter := bson.M{}
+	if query == nil {
+		return filter, nil
+	}
+
+	if query.Text != "" {
+		filter["name"] = bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(query.Text), Options: "i"}}
+	}
+	if len(query.Tags) > 0 {
+		filter["source.tags"] = bson.M{"$all": query.Tags}
+	}
+	if createdRange := timeRange(query.CreatedAfter, query.CreatedBefore); createdRange != nil {
+		filter["createdAt"] = createdRange
+	}
+	if countRange := intRange(query.MinImageCount, query.MaxImageCount); countRange != nil {
+		filter["imageCount"] = countRange
+	}
+
+	// --- 图片级条件 ---
+	imageFilter := bson.M{}
+	if widthRange := intRange(query.MinWidth, query.MaxWidth); widthRange != nil {
+		imageFilter["width"] = widthRange
+	}
+	if heightRange := intRange(query.MinHeight, query.MaxHeight); heightRange != nil {
+		imageFilter["height"] = heightRange
+	}
+	if query.SimilarToImageID != nil {
+		var ref models.Image
+		err := s.images.coll.FindOne(ctx, bson.M{"_id": *query.SimilarToImageID}).Decode(&ref)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, fmt.Errorf("参考图片 %s 不存在", query.SimilarToImageID.Hex())
+			}
+			return nil, err
+		}
+		imageFilter["perceptualHash"] = ref.PerceptualHash
+	}
+
+	// 图片级和个人状态条件最终都落在 _id 上，可能同时存在多个，用 $and 合并
+	var idConds []bson.M
+	if len(imageFilter) > 0 {
+		seriesIDs, err := s.images.coll.Distinct(ctx, "seriesId", imageFilter)
+		if err != nil {
+			return nil, fmt.Errorf("按图片条件筛选系列失败: %w", err)
+		}
+		idConds = append(idConds, bson.M{"$in": seriesIDs})
+	}
+
+	// --- 个人状态条件 ---
+	stateFilter := bson.M{"userId": query.UserID}
+	if query.Favorite != nil && *query.Favorite {
+		stateFilter["favorite"] = true
+	}
+	if query.MinRating != nil {
+		stateFilter["rating"] = bson.M{"$gte": *query.MinRating}
+	}
+	if len(stateFilter) > 1 {
+		seriesIDs, err := s.userState.coll.Distinct(ctx, "seriesId", stateFilter)
+		if err != nil {
+			return nil, fmt.Errorf("按个人状态筛选系列失败: %w", err)
+		}
+		idConds = append(idConds, bson.M{"$in": seriesIDs})
+	}
+	if query.Favorite != nil && !*query.Favorite {
+		// 未收藏 = 没有状态记录或 favorite 为 false
+		seriesIDs, err := s.userState.coll.Distinct(ctx, "seriesId", bson.M{"userId": query.UserID, "favorite": true})
+		if err != nil {
+			return nil, fmt.Errorf("按个人状态筛选系列失败: %w", err)
+		}
+		idConds = append(idConds, bson.M{"$nin": seriesIDs})
+	}
+	if query.Unread != nil {
+		// 从未打开过的系列没有状态记录，同样视为未读，因此以“已读”集合取反
+		readIDs, err := s.userState.coll.Distinct(ctx, "seriesId", bson.M{"userId": query.UserID, "read": true})
+		if err != nil {
+			return nil, fmt.Errorf("按阅读状态筛选系列失败: %w", err)
+		}
+		if *query.Unread {
+			idConds = append(idConds, bson.M{"$nin": readIDs})
+		} else {
+			idConds = append(idConds, bson.M{"$in": readIDs})
+		}
+	}
+
+	switch len(idConds) {
+	case 0:
+	case 1:
+		filter["_id"] = idConds[0]
+	default:
+		and := make(bson.A, 0, len(idConds))
+		for _, cond := range idConds {
+			and = append(and, bson.M{"_id": cond})
+		}
+		filter["$and"] = and
+	}
+
+	return filter, nil
+}
+
+// intRange 根据可选的上下限构建 $gte/$lte 条件，两者都为 nil 时返回 nil。
+func intRange(min, max *int) bson.M {
+	if min == nil && max == nil {
+		return nil
+	}
+	r := bson.M{}
+	if min != nil {
+		r["$gte"] = *min
+	}
+	if max != nil {
+		r["$lte"] = *max
+	}
+	return r
+}
+
+// timeRange 与 intRange 相同，但作用于时间字段。
+func timeRange(after, before *time.Time) bson.M {
+	if after == nil && before == nil {
+		return nil
+	}
+	r := bson.M{}
+	if after != nil {
+		r["$gte"] = *after
+	}
+	if before != nil {
+		r["$lte"] = *before
+	}
+	return r
+}
@@ -4,6 +4,7 @@ import (
 	"PICs_Manager/config"
 	"PICs_Manager/internal/models"
 	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/romanize"
 	"context"
 	"errors"
 	"fmt"
@@ -20,9 +21,17 @@ import (
 
 // Store 是 database.Store 接口的MongoDB实现。
 type Store struct {
-	db     *mongo.Database
-	series *seriesStore
-	images *imageStore
+	db               *mongo.Database
+	series           *seriesStore
+	images           *imageStore
+	smartCollections *smartCollectionStore
+	userState        *userStateStore
+	users            *userStore
+	sessions         *sessionStore
+	apiTokens        *apiTokenStore
+	quarantine       *quarantineStore
+	trash            *trashStore
+	scanRuns         *scanRunStore
 }
 
 // 确保 Store 实现了 database.Store 接口 (编译时检查)
@@ -31,6 +40,8 @@ var _ database.Store = (*Store)(nil)
 // seriesStore 封装了与 "series" 集合相关的所有操作。
 type seriesStore struct {
 	coll *mongo.Collection
+	// imagesColl 是同一个库的 images 集合名，用于 $lookup。
+	imagesColl string
 }
 
 // GetAllSeries 返回数据库中所有系列的原始文档列表。
@@ -72,15 +83,38 @@ func NewStore(ctx context.Context, cfg *config.Config) (database.Store, error) {
 	slog.Info("MongoDB 连接成功")
 
 	db := client.Database(cfg.Database.Name)
-	ss := &seriesStore{coll: db.Collection("series")}
-	is := &imageStore{coll: db.Collection("images")}
+	store := newStore(db, "")
+	store.users = &userStore{coll: db.Collection("users")}
+	store.sessions = &sessionStore{coll: db.Collection("sessions")}
+	store.apiTokens = &apiTokenStore{coll: db.Collection("apiTokens")}
+	return store, nil
+}
 
-	store := &Store{
-		db:     db,
-		series: ss,
-		images: is,
+// newStore 创建库内容 (系列、图片、集合、个人状态、隔离区、回收站) 的集合，集合名加上 prefix。
+// 用户、会话和 API 令牌不属于任何库，由调用方设置。
+func newStore(db *mongo.Database, prefix string) *Store {
+	return &Store{
+		db:               db,
+		series:           &seriesStore{coll: db.Collection(prefix + "series"), imagesColl: prefix + "images"},
+		images:           &imageStore{coll: db.Collection(prefix + "images")},
+		smartCollections: &smartCollectionStore{coll: db.Collection(prefix + "smartCollections")},
+		userState:        &userStateStore{coll: db.Collection(prefix + "userState")},
+		quarantine:       &quarantineStore{coll: db.Collection(prefix + "quarantine")},
+		trash:            &trashStore{coll: db.Collection(prefix + "trash")},
+		scanRuns:         &scanRunStore{coll: db.Collection(prefix + "scanRuns")},
 	}
-	return store, nil
+}
+
+// Library 返回一个库的 Store：内容集合位于 database 数据库 (为空时使用当前数据库) 中并加上 collectionPrefix 前缀，
+// 与当前 Store 共用连接以及用户、会话和 API 令牌集合。
+func (s *Store) Library(database, collectionPrefix string) database.Store {
+	db := s.db
+	if database != "" && database != s.db.Name() {
+		db = s.db.Client().Database(database)
+	}
+	lib := newStore(db, collectionPrefix)
+	lib.users, lib.sessions, lib.apiTokens = s.users, s.sessions, s.apiTokens
+	return lib
 }
 
 func (s *Store) Series() database.SeriesStore {
@@ -91,6 +125,38 @@ func (s *Store) Images() database.ImageStore {
 	return s.images
 }
 
+func (s *Store) SmartCollections() database.SmartCollectionStore {
+	return s.smartCollections
+}
+
+func (s *Store) UserState() database.UserStateStore {
+	return s.userState
+}
+
+func (s *Store) Users() database.UserStore {
+	return s.users
+}
+
+func (s *Store) Sessions() database.SessionStore {
+	return s.sessions
+}
+
+func (s *Store) APITokens() database.APITokenStore {
+	return s.apiTokens
+}
+
+func (s *Store) Quarantine() database.QuarantineStore {
+	return s.quarantine
+}
+
+func (s *Store) Trash() database.TrashStore {
+	return s.trash
+}
+
+func (s *Store) ScanRuns() database.ScanRunStore {
+	return s.scanRuns
+}
+
 func (s *Store) EnsureIndexes(ctx context.Context) error {
 	slog.Info("正在确保数据库索引存在...")
 	imageIndexes := []mongo.IndexModel{
@@ -118,6 +184,21 @@ func (s *Store) EnsureIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "seriesId", Value: 1}, {Key: "fileName", Value: 1}},
 			Options: options.Index().SetUnique(true).SetName("idx_seriesid_filename_unique"),
 		},
+
+		{
+			Keys:    bson.D{{Key: "width", Value: 1}, {Key: "height", Value: 1}},
+			Options: options.Index().SetName("idx_width_height"),
+		},
+
+		{
+			Keys:    bson.D{{Key: "source.author", Value: 1}},
+			Options: options.Index().SetName("idx_source_author").SetSparse(true),
+		},
+
+		{
+			Keys:    bson.D{{Key: "source.tags", Value: 1}},
+			Options: options.Index().SetName("idx_source_tags").SetSparse(true),
+		},
 	}
 	if _, err := s.images.coll.Indexes().CreateMany(ctx, imageIndexes); err != nil {
 		slog.Error("为 images 集合创建索引失败", "error", err)
@@ -134,12 +215,146 @@ func (s *Store) EnsureIndexes(ctx context.Context) error {
 			Keys:    bson.D{{Key: "name", Value: 1}},
 			Options: options.Index().SetUnique(true).SetName("idx_name_unique").SetDefaultLanguage("none"),
 		},
+		{
+			Keys:    bson.D{{Key: "sortName", Value: 1}},
+			Options: options.Index().SetName("idx_sortname"),
+		},
 	}
 	if _, err := s.series.coll.Indexes().CreateMany(ctx, seriesIndexes); err != nil {
 		slog.Error("为 series 集合创建索引失败", "error", err)
 		return err
 	}
+	if err := s.series.backfillSortNames(ctx); err != nil {
+		slog.Error("为旧系列补全 sortName 失败", "error", err)
+		return err
+	}
 	slog.Info("Series 集合索引已验证/创建。")
+
+	smartCollectionIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_name_unique"),
+		},
+	}
+	if _, err := s.smartCollections.coll.Indexes().CreateMany(ctx, smartCollectionIndexes); err != nil {
+		slog.Error("为 smartCollections 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("SmartCollections 集合索引已验证/创建。")
+
+	// 引入账号之前写入的个人状态没有 userId，归属到最早的管理员；还没有管理员时归属到关闭认证时使用的本地用户
+	var ownerID primitive.ObjectID
+	if s.users != nil {
+		admin, err := s.users.firstAdmin(ctx)
+		if err != nil {
+			slog.Error("查询管理员失败", "error", err)
+			return err
+		}
+		if admin != nil {
+			ownerID = admin.ID
+		}
+	}
+	if claimed, err := s.userState.claimUnowned(ctx, ownerID); err != nil {
+		slog.Error("为旧的个人状态补全 userId 失败", "error", err)
+		return err
+	} else if claimed > 0 {
+		slog.Info("已为旧的个人状态补全 userId", "数量", claimed, "用户", ownerID.Hex())
+	}
+	userStateIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "seriesId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_userid_seriesid_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "favorite", Value: 1}, {Key: "rating", Value: -1}},
+			Options: options.Index().SetName("idx_userid_favorite_rating"),
+		},
+	}
+	if _, err := s.userState.coll.Indexes().CreateMany(ctx, userStateIndexes); err != nil {
+		slog.Error("为 userState 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("UserState 集合索引已验证/创建。")
+
+	if _, err := s.users.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("idx_username_unique"),
+	}); err != nil {
+		slog.Error("为 users 集合创建索引失败", "error", err)
+		return err
+	}
+
+	sessionIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_tokenhash_unique"),
+		},
+		{
+			// TTL 索引：会话过期后由 MongoDB 自动删除
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("idx_expiresat_ttl"),
+		},
+	}
+	if _, err := s.sessions.coll.Indexes().CreateMany(ctx, sessionIndexes); err != nil {
+		slog.Error("为 sessions 集合创建索引失败", "error", err)
+		return err
+	}
+
+	apiTokenIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("idx_tokenhash_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("idx_userid"),
+		},
+	}
+	if _, err := s.apiTokens.coll.Indexes().CreateMany(ctx, apiTokenIndexes); err != nil {
+		slog.Error("为 apiTokens 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("Users/Sessions/APITokens 集合索引已验证/创建。")
+
+	quarantineIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("idx_kind_createdat"),
+		},
+	}
+	if _, err := s.quarantine.coll.Indexes().CreateMany(ctx, quarantineIndexes); err != nil {
+		slog.Error("为 quarantine 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("Quarantine 集合索引已验证/创建。")
+
+	trashIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("idx_createdat"),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetName("idx_expiresat"),
+		},
+	}
+	if _, err := s.trash.coll.Indexes().CreateMany(ctx, trashIndexes); err != nil {
+		slog.Error("为 trash 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("Trash 集合索引已验证/创建。")
+
+	scanRunIndexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "stage", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("idx_stage_createdat"),
+		},
+	}
+	if _, err := s.scanRuns.coll.Indexes().CreateMany(ctx, scanRunIndexes); err != nil {
+		slog.Error("为 scanRuns 集合创建索引失败", "error", err)
+		return err
+	}
+	slog.Info("ScanRuns 集合索引已验证/创建。")
 	return nil
 }
 
@@ -148,10 +363,48 @@ func (s *Store) EnsureIndexes(ctx context.Context) error {
 func (s *seriesStore) Create(ctx context.Context, series *models.Series) error {
 	series.CreatedAt = time.Now()
 	series.UpdatedAt = time.Now()
+	series.SortName = romanize.SortName(series.Name, series.SortNameOverride)
 	_, err := s.coll.InsertOne(ctx, series)
 	return err
 }
 
+// backfillSortNames 为引入 sortName 之前创建的系列补全该字段，
+// 并重新计算设置了排序名覆盖的系列 (早期版本没有把覆盖转为小写)。
+func (s *seriesStore) backfillSortNames(ctx context.Context) error {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"sortName": bson.M{"$exists": false}},
+		bson.M{"sortNameOverride": bson.M{"$exists": true}},
+	}}
+	cursor, err := s.coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"name": 1, "sortName": 1, "sortNameOverride": 1}))
+	if err != nil {
+		return err
+	}
+	var missing []models.Series
+	if err := cursor.All(ctx, &missing); err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(missing))
+	for _, series := range missing {
+		sortName := romanize.SortName(series.Name, series.SortNameOverride)
+		if series.SortName == sortName {
+			continue
+		}
+		update := bson.M{"$set": bson.M{"sortName": sortName}}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": series.ID}).SetUpdate(update))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	if err := s.BulkWrite(ctx, writes); err != nil {
+		return err
+	}
+	slog.Info("已为旧系列补全 sortName", "count", len(writes))
+	return nil
+}
+
 func (s *seriesStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Series, error) {
 	var series models.Series
 	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&series)
@@ -182,11 +435,11 @@ func (s *seriesStore) List(ctx context.Context, page, limit int) ([]models.Serie
 	skip := (page - 1) * limit
 
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$sort", Value: bson.D{{Key: "path", Value: 1}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "sortName", Value: 1}, {Key: "path", Value: 1}}}},
 		bson.D{{Key: "$skip", Value: int64(skip)}},
 		bson.D{{Key: "$limit", Value: int64(limit)}},
 		bson.D{{Key: "$lookup", Value: bson.D{
-			{Key: "from", Value: "images"},
+			{Key: "from", Value: s.imagesColl},
 			{Key: "localField", Value: "_id"},
 			{Key: "foreignField", Value: "seriesId"},
 			{Key: "pipeline", Value: mongo.Pipeline{
@@ -200,6 +453,9 @@ func (s *seriesStore) List(ctx context.Context, page, limit int) ([]models.Serie
 		}}},
 		bson.D{{Key: "$project", Value: bson.D{
 			{Key: "name", Value: 1},
+			{Key: "sortName", Value: 1},
+			{Key: "sortNameOverride", Value: 1},
+			{Key: "source", Value: 1},
 			{Key: "path", Value: 1},
 			{Key: "imageCount", Value: 1},
 			{Key: "createdAt", Value: 1},
@@ -225,10 +481,18 @@ func (s *seriesStore) List(ctx context.Context, page, limit int) ([]models.Serie
 	return seriesList, total, nil
 }
 
+// Update 更新系列的名称、路径和排序名覆盖 (sortName 随之重新计算)。
 func (s *seriesStore) Update(ctx context.Context, series *models.Series) error {
 	series.UpdatedAt = time.Now()
+	series.SortName = romanize.SortName(series.Name, series.SortNameOverride)
 	filter := bson.M{"_id": series.ID}
-	update := bson.M{"$set": bson.M{"name": series.Name, "updatedAt": series.UpdatedAt}}
+	update := bson.M{"$set": bson.M{
+		"name":             series.Name,
+		"path":             series.Path,
+		"sortName":         series.SortName,
+		"sortNameOverride": series.SortNameOverride,
+		"updatedAt":        series.UpdatedAt,
+	}}
 	_, err := s.coll.UpdateOne(ctx, filter, update)
 	return err
 }
@@ -298,7 +562,14 @@ func (i *imageStore) ListBySeriesID(ctx context.Context, seriesID primitive.Obje
 func (i *imageStore) SearchByName(ctx context.Context, query string, page, limit int) ([]models.Image, int64, error) {
 	var imageList []models.Image
 	skip := (page - 1) * limit
-	filter := bson.M{"fileName": bson.M{"$regex": query, "$options": "i"}}
+	// 除文件名外，也搜索下载器元数据中的标题、作者和原始标签
+	pattern := bson.M{"$regex": query, "$options": "i"}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"fileName": pattern},
+		bson.M{"source.title": pattern},
+		bson.M{"source.author": pattern},
+		bson.M{"source.tags": pattern},
+	}}
 
 	findOpts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
 	cursor, err := i.coll.Find(ctx, filter, findOpts)
@@ -337,6 +608,14 @@ func (i *imageStore) Delete(ctx context.Context, id primitive.ObjectID) error {
 	return err
 }
 
+func (i *imageStore) DeleteBySeriesID(ctx context.Context, seriesID primitive.ObjectID) (int64, error) {
+	res, err := i.coll.DeleteMany(ctx, bson.M{"seriesId": seriesID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
 func (s *seriesStore) UpdateMetadata(ctx context.Context, seriesID primitive.ObjectID, imageCount int, thumbnail string) error {
 	filter := bson.M{"_id": seriesID}
 	update := bson.M{"$set": bson.M{
@@ -456,7 +735,15 @@ func (s *seriesStore) SearchByName(ctx context.Context, nameQuery string, page,
 
 	// 使用 primitive.Regex 来安全地构建正则表达式，防止注入
 	// QuoteMeta 会转义查询字符串中的所有特殊正则字符
-	filter := bson.M{"name": bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(nameQuery), Options: "i"}}}
+	// 除名称外，也匹配下载器元数据中的标题、作者、原始标签和来源 URL
+	pattern := bson.M{"$regex": primitive.Regex{Pattern: regexp.QuoteMeta(nameQuery), Options: "i"}}
+	filter := bson.M{"$or": bson.A{
+		bson.M{"name": pattern},
+		bson.M{"source.title": pattern},
+		bson.M{"source.author": pattern},
+		bson.M{"source.tags": pattern},
+		bson.M{"source.url": pattern},
+	}}
 
 	// 设置查找选项，包括分页和排序
 	findOpts := options.Find().
@@ -501,6 +788,7 @@ func (s *seriesStore) FindOrCreateByName(ctx context.Context, seriesName string,
 		"$setOnInsert": bson.M{
 			"_id":        primitive.NewObjectID(),
 			"name":       seriesName,
+			"sortName":   romanize.SortName(seriesName, ""),
 			"imageCount": 0,
 			"createdAt":  time.Now(),
 		},
@@ -644,6 +932,22 @@ func (i *imageStore) GetFirstImage(ctx context.Context, seriesID primitive.Objec
 	return &image, nil
 }
 
+// GetFirstWithSource 按文件名排序，获取系列中第一张带下载器元数据的图片，没有时返回 nil。
+func (i *imageStore) GetFirstWithSource(ctx context.Context, seriesID primitive.ObjectID) (*models.Image, error) {
+	var image models.Image
+	filter := bson.M{"seriesId": seriesID, "source": bson.M{"$type": "object"}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "fileName", Value: 1}}).SetProjection(bson.M{"thumbnail": 0})
+
+	err := i.coll.FindOne(ctx, filter, opts).Decode(&image)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
 // DropAllCollections 删除当前数据库中的所有已知集合，主要用于测试环境的重置。
 func (s *Store) DropAllCollections(ctx context.Context) error {
 	slog.Warn("正在删除所有集合...", "database", s.db.Name())
@@ -655,6 +959,25 @@ func (s *Store) DropAllCollections(ctx context.Context) error {
 		slog.Error("删除 images 集合失败", "error", err)
 		return err
 	}
+	if err := s.smartCollections.coll.Drop(ctx); err != nil {
+		slog.Error("删除 smartCollections 集合失败", "error", err)
+		return err
+	}
+	if err := s.userState.coll.Drop(ctx); err != nil {
+		slog.Error("删除 userState 集合失败", "error", err)
+		return err
+	}
+	if err := s.scanRuns.coll.Drop(ctx); err != nil {
+		slog.Error("删除 scanRuns 集合失败", "error", err)
+		return err
+	}
+	// 注意：users / apiTokens 不在重置范围内，避免测试重置后所有人都无法登录；
+	// quarantine / trash 也不在范围内，因为它们的记录对应着磁盘上仍然存在的文件；
+	// 会话可以安全地清空。
+	if err := s.sessions.coll.Drop(ctx); err != nil {
+		slog.Error("删除 sessions 集合失败", "error", err)
+		return err
+	}
 	slog.Info("所有集合已成功删除。")
 	return nil
 }
@@ -763,3 +1086,68 @@ func (i *imageStore) GetAllBySeriesID(ctx context.Context, seriesID primitive.Ob
 
 	return images, nil
 }
+
+func (i *imageStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.Image, error) {
+	var image models.Image
+	err := i.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&image)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &image, nil
+}
+
+// FindWithoutEXIF 查找没有 exif 字段的所有图片 (不含视频)：既包括在读取 EXIF 之前入库、缩略图没有按方向校正的图片，
+// 也包括本身不含 EXIF 的图片。只投影维护任务需要的字段，避免把所有缩略图读入内存。
+func (i *imageStore) FindWithoutEXIF(ctx context.Context) ([]models.Image, error) {
+	var imageList []models.Image
+	filter := bson.M{
+		"exif":      bson.M{"$exists": false},
+		"mediaType": bson.M{"$ne": models.MediaTypeVideo},
+	}
+	opts := options.Find().SetProjection(bson.M{"seriesId": 1, "fileName": 1, "filePath": 1})
+
+	cursor, err := i.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &imageList); err != nil {
+		return nil, err
+	}
+	return imageList, nil
+}
+
+// SetEXIF 记录单张图片的 EXIF。
+func (i *imageStore) SetEXIF(ctx context.Context, id primitive.ObjectID, exif *models.ImageEXIF) error {
+	update := bson.M{"$set": bson.M{"exif": exif, "updatedAt": time.Now()}}
+	res, err := i.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("图片 %s 不存在", id.Hex())
+	}
+	return nil
+}
+
+// UpdateThumbnail 更新单张图片的缩略图和显示尺寸。
+func (i *imageStore) UpdateThumbnail(ctx context.Context, id primitive.ObjectID, thumbnail string, width, height int) error {
+	update := bson.M{"$set": bson.M{
+		"thumbnail": thumbnail,
+		"width":     width,
+		"height":    height,
+		"updatedAt": time.Now(),
+	}}
+	res, err := i.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("图片 %s 不存在", id.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trashStore 封装了与 "trash" 集合相关的所有操作。
+type trashStore struct {
+	coll *mongo.Collection
+}
+
+// --- trashStore 方法实现 ---
+
+func (t *trashStore) Create(ctx context.Context, item *models.TrashItem) error {
+	if item.ID.IsZero() {
+		item.ID = primitive.NewObjectID()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	_, err := t.coll.InsertOne(ctx, item)
+	return err
+}
+
+func (t *trashStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	var item models.TrashItem
+	err := t.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List 按删除时间倒序分页返回回收站记录。
+func (t *trashStore) List(ctx context.Context, page, limit int) ([]models.TrashItem, int64, error) {
+	items := []models.TrashItem{}
+	skip := (page - 1) * limit
+
+	opts := options.Find().
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := t.coll.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := t.coll.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// ListExpired 返回过期时间早于 before 的记录 (没有过期时间的记录永不返回)。
+func (t *trashStore) ListExpired(ctx context.Context, before time.Time) ([]models.TrashItem, error) {
+	items := []models.TrashItem{}
+	filter := bson.M{"expiresAt": bson.M{"$exists": true, "$lte": before}}
+	cursor, err := t.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "expiresAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (t *trashStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := t.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,116 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userStateStore 封装了与 "userState" 集合相关的所有操作。
+type userStateStore struct {
+	coll *mongo.Collection
+}
+
+// --- userStateStore 方法实现 ---
+
+// GetBySeriesID 获取用户对系列的个人状态。系列从未被该用户标记过时返回 nil, nil。
+func (u *userStateStore) GetBySeriesID(ctx context.Context, userID, seriesID primitive.ObjectID) (*models.SeriesState, error) {
+	var state models.SeriesState
+	err := u.coll.FindOne(ctx, bson.M{"userId": userID, "seriesId": seriesID}).Decode(&state)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Upsert 对系列的个人状态执行部分更新，记录不存在时自动创建，并返回更新后的完整文档。
+func (u *userStateStore) Upsert(ctx context.Context, userID, seriesID primitive.ObjectID, update *models.SeriesStateUpdate) (*models.SeriesState, error) {
+	now := time.Now()
+	set := bson.M{"updatedAt": now}
+	// 未被本次更新覆盖的字段在首次插入时需要有默认值
+	setOnInsert := bson.M{
+		"_id":       primitive.NewObjectID(),
+		"userId":    userID,
+		"seriesId":  seriesID,
+		"createdAt": now,
+	}
+	defaults := bson.M{"rating": 0, "favorite": false, "read": false, "lastReadPage": 0}
+
+	if update.Rating != nil {
+		set["rating"] = *update.Rating
+	}
+	if update.Favorite != nil {
+		set["favorite"] = *update.Favorite
+	}
+	if update.Read != nil {
+		set["read"] = *update.Read
+	}
+	if update.LastReadImageID != nil {
+		set["lastReadImageId"] = *update.LastReadImageID
+		set["lastReadAt"] = now
+	}
+	if update.LastReadPage != nil {
+		set["lastReadPage"] = *update.LastReadPage
+		set["lastReadAt"] = now
+	}
+	for field, value := range defaults {
+		if _, ok := set[field]; !ok {
+			setOnInsert[field] = value
+		}
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var state models.SeriesState
+	err := u.coll.FindOneAndUpdate(ctx, bson.M{"userId": userID, "seriesId": seriesID}, bson.M{"$set": set, "$setOnInsert": setOnInsert}, opts).Decode(&state)
+	if err != nil {
+		return nil, fmt.Errorf("更新系列 %s 的个人状态失败: %w", seriesID.Hex(), err)
+	}
+	return &state, nil
+}
+
+// DeleteBySeriesID 删除所有用户在该系列上的状态，用于系列被删除时的级联清理。
+func (u *userStateStore) DeleteBySeriesID(ctx context.Context, seriesID primitive.ObjectID) error {
+	_, err := u.coll.DeleteMany(ctx, bson.M{"seriesId": seriesID})
+	return err
+}
+
+// claimUnowned 把没有 userId 的个人状态 (引入账号之前写入的记录) 归属到 ownerID，否则它们不会显示给任何用户。
+// ownerID 已经有同一系列的状态时保留 ownerID 自己的记录。返回归属的记录数。
+func (u *userStateStore) claimUnowned(ctx context.Context, ownerID primitive.ObjectID) (int, error) {
+	cursor, err := u.coll.Find(ctx, bson.M{"userId": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	var states []models.SeriesState
+	if err := cursor.All(ctx, &states); err != nil {
+		return 0, err
+	}
+	claimed := 0
+	for _, state := range states {
+		owned, err := u.GetBySeriesID(ctx, ownerID, state.SeriesID)
+		if err != nil {
+			return claimed, err
+		}
+		if owned != nil {
+			if _, err := u.coll.DeleteOne(ctx, bson.M{"_id": state.ID}); err != nil {
+				return claimed, err
+			}
+			continue
+		}
+		if _, err := u.coll.UpdateOne(ctx, bson.M{"_id": state.ID}, bson.M{"$set": bson.M{"userId": ownerID}}); err != nil {
+			return claimed, err
+		}
+		claimed++
+	}
+	return claimed, nil
+}
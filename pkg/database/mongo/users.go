@@ -0,0 +1,208 @@
+package mongo
+
+import (
+	"PICs_Manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// userStore 封装了与 "users" 集合相关的所有操作。
+type userStore struct {
+	coll *mongo.Collection
+}
+
+// sessionStore 封装了与 "sessions" 集合相关的所有操作。
+type sessionStore struct {
+	coll *mongo.Collection
+}
+
+// apiTokenStore 封装了与 "apiTokens" 集合相关的所有操作。
+type apiTokenStore struct {
+	coll *mongo.Collection
+}
+
+// --- userStore 方法实现 ---
+
+func (u *userStore) Create(ctx context.Context, user *models.User) error {
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	_, err := u.coll.InsertOne(ctx, user)
+	return err
+}
+
+func (u *userStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return u.findOne(ctx, bson.M{"_id": id})
+}
+
+func (u *userStore) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return u.findOne(ctx, bson.M{"username": username})
+}
+
+func (u *userStore) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	err := u.coll.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (u *userStore) List(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+	cursor, err := u.coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Update 更新用户的角色和密码哈希。用户名创建后不可修改。
+func (u *userStore) Update(ctx context.Context, user *models.User) error {
+	user.UpdatedAt = time.Now()
+	filter := bson.M{"_id": user.ID}
+	update := bson.M{"$set": bson.M{
+		"role":         user.Role,
+		"passwordHash": user.PasswordHash,
+		"updatedAt":    user.UpdatedAt,
+	}}
+	res, err := u.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("用户 %s 不存在", user.ID.Hex())
+	}
+	return nil
+}
+
+func (u *userStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := u.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (u *userStore) CountByRole(ctx context.Context, role models.Role) (int64, error) {
+	return u.coll.CountDocuments(ctx, bson.M{"role": role})
+}
+
+// firstAdmin 返回最早创建的管理员，没有管理员时返回 nil, nil。
+func (u *userStore) firstAdmin(ctx context.Context) (*models.User, error) {
+	var user models.User
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	err := u.coll.FindOne(ctx, bson.M{"role": models.RoleAdmin}, opts).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// --- sessionStore 方法实现 ---
+
+func (s *sessionStore) Create(ctx context.Context, session *models.Session) error {
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	_, err := s.coll.InsertOne(ctx, session)
+	return err
+}
+
+// GetByTokenHash 查找未过期的会话。TTL 索引的清理有延迟，因此这里仍需显式比较过期时间。
+func (s *sessionStore) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	var session models.Session
+	filter := bson.M{"tokenHash": tokenHash, "expiresAt": bson.M{"$gt": time.Now()}}
+	err := s.coll.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *sessionStore) DeleteByTokenHash(ctx context.Context, tokenHash string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"tokenHash": tokenHash})
+	return err
+}
+
+func (s *sessionStore) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"userId": userID})
+	return err
+}
+
+// --- apiTokenStore 方法实现 ---
+
+func (a *apiTokenStore) Create(ctx context.Context, token *models.APIToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+	token.UpdatedAt = time.Now()
+	_, err := a.coll.InsertOne(ctx, token)
+	return err
+}
+
+func (a *apiTokenStore) GetByTokenHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := a.coll.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (a *apiTokenStore) ListByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.APIToken, error) {
+	tokens := []models.APIToken{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := a.coll.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete 删除令牌。同时按 userId 过滤，保证用户只能吊销自己的令牌。
+func (a *apiTokenStore) Delete(ctx context.Context, userID, id primitive.ObjectID) error {
+	res, err := a.coll.DeleteOne(ctx, bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("令牌 %s 不存在", id.Hex())
+	}
+	return nil
+}
+
+func (a *apiTokenStore) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := a.coll.DeleteMany(ctx, bson.M{"userId": userID})
+	return err
+}
+
+func (a *apiTokenStore) TouchLastUsed(ctx context.Context, id primitive.ObjectID) error {
+	_, err := a.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": time.Now()}})
+	return err
+}
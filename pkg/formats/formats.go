@@ -0,0 +1,165 @@
+// Package formats 是全项目唯一的媒体格式注册表。
+// 它负责注册所有图片解码器，并维护“哪些扩展名会被流水线当作图片/视频处理”的允许列表，
+// 预处理、分类、入库、哈希和缩略图各阶段都应通过这里判断，而不是各自维护一份列表。
+package formats
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	// 匿名导入 (blank import) 所有支持的 image 解码器
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// decodableExtensions 是已注册解码器能够处理的扩展名及其对应格式名 (image.Decode 返回的 format)。
+var decodableExtensions = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".webp": "webp",
+	".bmp":  "bmp",
+	".tif":  "tiff",
+	".tiff": "tiff",
+}
+
+// videoExtensions 是 videometa 能够解析容器头部的视频扩展名及其容器格式。
+var videoExtensions = map[string]string{
+	".mp4":  "mp4",
+	".m4v":  "mp4",
+	".mov":  "mp4",
+	".webm": "webm",
+	".mkv":  "matroska",
+}
+
+var (
+	mu           sync.RWMutex
+	allowed      = defaultAllowed(decodableExtensions)
+	allowedVideo = defaultAllowed(videoExtensions)
+)
+
+func defaultAllowed(known map[string]string) map[string]struct{} {
+	m := make(map[string]struct{}, len(known))
+	for ext := range known {
+		m[ext] = struct{}{}
+	}
+	return m
+}
+
+// SetAllowedExtensions 用配置中的扩展名列表替换允许列表。
+// 扩展名不区分大小写，可带或不带前导点；传入空列表时恢复为全部可解码格式。
+// 列表中包含没有解码器的扩展名时返回错误，且不修改当前设置。
+func SetAllowedExtensions(exts []string) error {
+	next, err := buildAllowed(exts, decodableExtensions, "图片")
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	allowed = next
+	mu.Unlock()
+	return nil
+}
+
+// SetAllowedVideoExtensions 与 SetAllowedExtensions 相同，但作用于视频扩展名列表。
+func SetAllowedVideoExtensions(exts []string) error {
+	next, err := buildAllowed(exts, videoExtensions, "视频")
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	allowedVideo = next
+	mu.Unlock()
+	return nil
+}
+
+// CheckExtensions 检查图片和视频扩展名列表是否都受支持，不修改当前设置。
+func CheckExtensions(images, videos []string) error {
+	if _, err := buildAllowed(images, decodableExtensions, "图片"); err != nil {
+		return err
+	}
+	_, err := buildAllowed(videos, videoExtensions, "视频")
+	return err
+}
+
+func buildAllowed(exts []string, known map[string]string, kind string) (map[string]struct{}, error) {
+	if len(exts) == 0 {
+		return defaultAllowed(known), nil
+	}
+	next := make(map[string]struct{}, len(exts))
+	for _, ext := range exts {
+		normalized := normalize(ext)
+		if _, ok := known[normalized]; !ok {
+			return nil, fmt.Errorf("不支持的%s扩展名 '%s'，可用: %s", kind, ext, strings.Join(sortedKeys(known), ", "))
+		}
+		next[normalized] = struct{}{}
+	}
+	return next, nil
+}
+
+// IsImageExtension 判断文件是否应被当作图片处理 (按扩展名，不读取内容)。
+func IsImageExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := allowed[ext]
+	return ok
+}
+
+// IsVideoExtension 判断文件是否应被当作视频处理 (按扩展名，不读取内容)。
+func IsVideoExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := allowedVideo[ext]
+	return ok
+}
+
+// IsMediaExtension 判断文件是否是流水线需要处理的媒体文件 (图片或视频)。
+func IsMediaExtension(path string) bool {
+	return IsImageExtension(path) || IsVideoExtension(path)
+}
+
+// Allowed 返回当前图片允许列表 (已排序)。
+func Allowed() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedKeys(allowed)
+}
+
+// AllowedVideo 返回当前视频允许列表 (已排序)。
+func AllowedVideo() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return sortedKeys(allowedVideo)
+}
+
+// Decodable 返回所有已注册解码器支持的扩展名 (已排序)。
+func Decodable() []string {
+	return sortedKeys(decodableExtensions)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func normalize(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,164 @@
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FS 是扫描流水线使用的文件系统操作。路径都是宿主系统的路径 (filepath 风格)，
+// OS 直接操作磁盘，MemFS 在内存中模拟，用于不接触磁盘地运行整条流水线。
+type FS interface {
+	Open(name string) (File, error)
+	// Create 创建或截断 name 并以读写方式打开。
+	Create(name string) (File, error)
+	// ReadDir 按文件名排序返回目录项。
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	// Rename 的语义与 os.Rename 相同。
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+}
+
+// File 是 FS 打开的文件。
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Stat() (fs.FileInfo, error)
+}
+
+// OS 是直接操作磁盘的 FS。Rename 使用 Move，跨文件系统时退回到校验过的复制。
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (File, error)   { return os.Open(name) }
+func (osFS) Create(name string) (File, error) { return os.Create(name) }
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error)   { return os.ReadDir(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Rename(oldpath, newpath string) error         { return Move(oldpath, newpath) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) RemoveAll(path string) error                  { return os.RemoveAll(path) }
+
+func (osFS) RenameWithProgress(oldpath, newpath string, progress func(Progress)) error {
+	return MoveWithProgress(oldpath, newpath, progress)
+}
+
+// RenameWithProgress 与 fsys.Rename 相同；fsys 支持报告移动进度时 (例如 OS 跨文件系统复制) 调用 progress。
+func RenameWithProgress(fsys FS, oldpath, newpath string, progress func(Progress)) error {
+	if r, ok := fsys.(interface {
+		RenameWithProgress(oldpath, newpath string, progress func(Progress)) error
+	}); ok {
+		return r.RenameWithProgress(oldpath, newpath, progress)
+	}
+	return fsys.Rename(oldpath, newpath)
+}
+
+// ReadFile 读取 fsys 中 name 的全部内容。
+func ReadFile(fsys FS, name string) ([]byte, error) {
+	if fsys == OS {
+		return os.ReadFile(name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// WriteFile 把 data 写入 fsys 中的 name，文件已存在时截断。
+func WriteFile(fsys FS, name string, data []byte) error {
+	f, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AppendFile 把 data 追加到 fsys 中 name 的末尾，文件不存在时创建。
+// 除 OS 以外的 fsys 没有追加模式，读出原有内容后整体重写。
+func AppendFile(fsys FS, name string, data []byte) error {
+	if fsys == OS {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	old, err := ReadFile(fsys, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return WriteFile(fsys, name, append(old, data...))
+}
+
+// Exists 判断 fsys 中是否存在 name。
+func Exists(fsys FS, name string) bool {
+	_, err := fsys.Stat(name)
+	return err == nil
+}
+
+// WalkDir 与 filepath.WalkDir 相同，只是通过 fsys 遍历 root。
+func WalkDir(fsys FS, root string, fn fs.WalkDirFunc) error {
+	if fsys == OS {
+		return filepath.WalkDir(root, fn)
+	}
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkDir(fsys FS, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		if err = fn(path, d, err); err != nil {
+			if err == filepath.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		if err := walkDir(fsys, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
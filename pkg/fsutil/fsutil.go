@@ -0,0 +1,57 @@
+// Package fsutil 提供库内文件整理时共用的文件系统辅助函数。
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// ErrExist 表示要把文件移回的原始位置已被其他文件占用。
+var ErrExist = errors.New("原始位置已存在同名文件")
+
+// AvailablePath 在 fsys 中 path 已存在时返回 "name (n).ext" 形式的第一个可用路径。
+func AvailablePath(fsys FS, path string) string {
+	if _, err := fsys.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := fsys.Stat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// MoveBack 把回收站、隔离区中的 from 移回它原来的位置 to，必要时先创建上级目录。
+// to 已存在时返回 ErrExist，不会覆盖它。
+func MoveBack(fsys FS, from, to string) error {
+	if Exists(fsys, to) {
+		return fmt.Errorf("%w: %s", ErrExist, to)
+	}
+	if err := fsys.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("无法创建原始目录: %w", err)
+	}
+	if err := fsys.Rename(from, to); err != nil {
+		return fmt.Errorf("恢复文件失败: %w", err)
+	}
+	return nil
+}
+
+// DirSize 返回 fsys 中 root 下所有文件的大小之和，无法读取的文件不计入。
+func DirSize(fsys FS, root string) int64 {
+	var size int64
+	WalkDir(fsys, root, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,314 @@
+package fsutil
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS 是完全在内存中的 FS，用于测试。可以被多个 goroutine 同时使用。
+// 文件系统的根目录 (filepath.Dir(p) == p 的路径) 总是存在。
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	dir     bool
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFS 创建一个空的 MemFS。
+func NewMemFS() *MemFS {
+	return &MemFS{nodes: make(map[string]*memNode)}
+}
+
+func memKey(name string) string {
+	return filepath.Clean(name)
+}
+
+func isRoot(key string) bool {
+	return filepath.Dir(key) == key
+}
+
+// lookup 返回 key 对应的节点，根目录返回一个临时的目录节点。调用方需持有锁。
+func (m *MemFS) lookup(key string) (*memNode, bool) {
+	if isRoot(key) {
+		return &memNode{dir: true, mode: fs.ModeDir | 0755}, true
+	}
+	n, ok := m.nodes[key]
+	return n, ok
+}
+
+// parentIsDir 判断 key 的父目录是否存在。调用方需持有锁。
+func (m *MemFS) parentIsDir(key string) bool {
+	parent, ok := m.lookup(filepath.Dir(key))
+	return ok && parent.dir
+}
+
+func (m *MemFS) Open(name string) (File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key := memKey(name)
+	n, ok := m.lookup(key)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{fsys: m, key: key, node: n, readOnly: true}, nil
+}
+
+func (m *MemFS) Create(name string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memKey(name)
+	if !m.parentIsDir(key) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	n, ok := m.lookup(key)
+	if ok && n.dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if !ok {
+		n = &memNode{mode: 0644}
+		m.nodes[key] = n
+	}
+	n.data, n.modTime = nil, time.Now()
+	return &memFile{fsys: m, key: key, node: n}, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key := memKey(name)
+	n, ok := m.lookup(key)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: fs.ErrNotExist}
+	}
+	if !n.dir {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: fs.ErrInvalid}
+	}
+	var entries []fs.DirEntry
+	for path, child := range m.nodes {
+		if path != key && filepath.Dir(path) == key {
+			entries = append(entries, fs.FileInfoToDirEntry(child.info(path)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key := memKey(name)
+	n, ok := m.lookup(key)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return n.info(key), nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memKey(path)
+	var missing []string
+	for ; !isRoot(key); key = filepath.Dir(key) {
+		n, ok := m.nodes[key]
+		if ok {
+			if !n.dir {
+				return &fs.PathError{Op: "mkdir", Path: key, Err: fs.ErrExist}
+			}
+			break
+		}
+		missing = append(missing, key)
+	}
+	for _, dir := range missing {
+		m.nodes[dir] = &memNode{dir: true, mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// Rename 与 os.Rename 一样：文件会覆盖已存在的文件，文件夹只能覆盖空文件夹。
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldKey, newKey := memKey(oldpath), memKey(newpath)
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	src, ok := m.nodes[oldKey]
+	if !ok {
+		return linkErr(fs.ErrNotExist)
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if !m.parentIsDir(newKey) {
+		return linkErr(fs.ErrNotExist)
+	}
+	if dst, ok := m.lookup(newKey); ok {
+		switch {
+		case src.dir != dst.dir:
+			return linkErr(fs.ErrExist)
+		case dst.dir && m.hasChildren(newKey):
+			return linkErr(fs.ErrExist)
+		}
+	}
+	if src.dir && strings.HasPrefix(newKey, oldKey+string(filepath.Separator)) {
+		return linkErr(fs.ErrInvalid)
+	}
+
+	moved := map[string]*memNode{newKey: src}
+	for path, n := range m.nodes {
+		if rel, ok := strings.CutPrefix(path, oldKey+string(filepath.Separator)); ok {
+			moved[filepath.Join(newKey, rel)] = n
+			delete(m.nodes, path)
+		}
+	}
+	delete(m.nodes, oldKey)
+	for path, n := range moved {
+		m.nodes[path] = n
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memKey(name)
+	if _, ok := m.nodes[key]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if m.hasChildren(key) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+	}
+	delete(m.nodes, key)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := memKey(path)
+	prefix := key + string(filepath.Separator)
+	if isRoot(key) {
+		prefix = key
+	}
+	for p := range m.nodes {
+		if p == key || strings.HasPrefix(p, prefix) {
+			delete(m.nodes, p)
+		}
+	}
+	return nil
+}
+
+// hasChildren 判断 key 下是否还有子项。调用方需持有锁。
+func (m *MemFS) hasChildren(key string) bool {
+	for path := range m.nodes {
+		if path != key && filepath.Dir(path) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *memNode) info(path string) fs.FileInfo {
+	return memInfo{name: filepath.Base(path), size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+// memFile 是 MemFS 中打开的文件。写入直接修改节点内容，因此文件改名后仍然可以继续写入。
+type memFile struct {
+	fsys     *MemFS
+	key      string
+	node     *memNode
+	offset   int64
+	readOnly bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fsys.mu.RLock()
+	defer f.fsys.mu.RUnlock()
+	if f.node.dir {
+		return 0, &fs.PathError{Op: "read", Path: f.key, Err: fs.ErrInvalid}
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.readOnly {
+		return 0, &fs.PathError{Op: "write", Path: f.key, Err: fs.ErrPermission}
+	}
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fsys.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fsys.mu.RUnlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.key, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.key, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.fsys.mu.RLock()
+	defer f.fsys.mu.RUnlock()
+	return f.node.info(f.key), nil
+}
+
+func (f *memFile) Close() error { return nil }
@@ -0,0 +1,265 @@
+package fsutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Progress 是一次跨文件系统移动的进度，只有退回到复制时才会报告。
+type Progress struct {
+	Files      int
+	TotalFiles int
+	Bytes      int64
+	TotalBytes int64
+	// Current 是刚刚复制完成的源文件。
+	Current string
+}
+
+// Move 把文件或文件夹 src 移动到 dst，语义与 os.Rename 相同。
+// src 和 dst 位于不同的文件系统 (例如下载盘和库盘) 时 os.Rename 会失败，此时退回到
+// 复制 + fsync + 哈希校验，并对目标的父目录 fsync 后才删除源文件；复制中途失败时删除已复制的部分，源文件保持不变。
+func Move(src, dst string) error {
+	return MoveWithProgress(src, dst, nil)
+}
+
+// MoveWithProgress 与 Move 相同，退回到复制时每复制完一个文件调用一次 progress (可以为 nil)。
+func MoveWithProgress(src, dst string, progress func(Progress)) error {
+	err := os.Rename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return moveFile(src, dst, info, progress)
+	}
+	return moveDir(src, dst, progress)
+}
+
+// LogProgress 返回一个每完成 10% 的字节数记录一次日志的进度回调，用于大文件夹的跨文件系统移动。
+func LogProgress(logf func(format string, args ...any), label string) func(Progress) {
+	next := int64(10)
+	return func(p Progress) {
+		if p.TotalBytes <= 0 {
+			return
+		}
+		percent := p.Bytes * 100 / p.TotalBytes
+		if percent < next && p.Files < p.TotalFiles {
+			return
+		}
+		for next <= percent {
+			next += 10
+		}
+		logf("跨文件系统移动 %s: %d%% (%d/%d 个文件, %d/%d 字节)", label, percent, p.Files, p.TotalFiles, p.Bytes, p.TotalBytes)
+	}
+}
+
+// moveFile 跨文件系统移动单个文件。与 os.Rename 一样会覆盖已存在的 dst，
+// 先写入同目录下的临时文件，校验后再改名，dst 不会出现写了一半的内容。
+func moveFile(src, dst string, info fs.FileInfo, progress func(Progress)) error {
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if err := copySymlink(src, dst); err != nil {
+			return err
+		}
+	} else if err := copyVerified(src, dst, info); err != nil {
+		return err
+	}
+	// 改名只修改了目录项，目录落盘前断电会丢失 dst，此时不能删除源文件
+	if err := syncDir(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", dst, err)
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return os.Remove(src)
+	}
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("已复制到 %s，但无法删除源文件: %w", dst, err)
+	}
+	if progress != nil {
+		progress(Progress{Files: 1, TotalFiles: 1, Bytes: info.Size(), TotalBytes: info.Size(), Current: src})
+	}
+	return nil
+}
+
+// moveDir 跨文件系统移动文件夹。与 os.Rename 一样，dst 已存在时失败。
+func moveDir(src, dst string, progress func(Progress)) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.LinkError{Op: "move", Old: src, New: dst, Err: fs.ErrExist}
+	}
+
+	total := Progress{}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total.TotalFiles++
+			total.TotalBytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			return copySymlink(path, target)
+		case !d.Type().IsRegular():
+			return fmt.Errorf("无法移动特殊文件 %s", path)
+		}
+		if err := copyVerified(path, target, info); err != nil {
+			return err
+		}
+		total.Files++
+		total.Bytes += info.Size()
+		total.Current = path
+		if progress != nil {
+			progress(total)
+		}
+		return nil
+	})
+	if err != nil {
+		// dst 在开始前不存在，其中的内容都是这次复制的，源文件夹保持不变
+		os.RemoveAll(dst)
+		return fmt.Errorf("跨文件系统移动 %s -> %s 失败，已撤销复制: %w", src, dst, err)
+	}
+
+	// 目录的修改时间在写入子项后才能恢复
+	filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				rel, _ := filepath.Rel(src, path)
+				os.Chtimes(filepath.Join(dst, rel), time.Now(), info.ModTime())
+			}
+		}
+		return nil
+	})
+	// 新建的目录和改名后的文件都只是目录项，全部落盘后才能删除源文件夹
+	if err := syncTree(dst); err != nil {
+		os.RemoveAll(dst)
+		return fmt.Errorf("跨文件系统移动 %s -> %s 失败，已撤销复制: %w", src, dst, err)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return fmt.Errorf("已复制到 %s，但无法删除源文件夹: %w", dst, err)
+	}
+	return nil
+}
+
+// copyVerified 把 src 复制到 dst：写入同目录下的临时文件并 fsync，重新读取比较 SHA-256，
+// 一致后保留权限和修改时间并改名为 dst。重新读取的内容通常来自页缓存而不是磁盘，
+// 校验只能发现复制过程中的错误，不能发现介质上的损坏。改名后由调用方对 dst 的父目录 fsync。
+func copyVerified(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".moving-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	done := false
+	defer func() {
+		if !done {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	srcHash := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(in, srcHash)); err != nil {
+		return fmt.Errorf("复制 %s 失败: %w", src, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", dst, err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dstHash := sha256.New()
+	if _, err := io.Copy(dstHash, tmp); err != nil {
+		return fmt.Errorf("校验 %s 失败: %w", dst, err)
+	}
+	if !sameSum(srcHash, dstHash) {
+		return fmt.Errorf("校验 %s 失败: 复制后的 SHA-256 与源文件不一致", dst)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	done = true
+
+	os.Chmod(tmpPath, info.Mode().Perm())
+	os.Chtimes(tmpPath, time.Now(), info.ModTime())
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// syncTree 对 root 及其下的每个目录 fsync，最后对 root 的父目录 fsync，使 root 本身的目录项也落盘。
+func syncTree(root string) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return syncDir(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(root))
+}
+
+func copySymlink(src, dst string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(link, dst)
+}
+
+func sameSum(a, b hash.Hash) bool {
+	return bytes.Equal(a.Sum(nil), b.Sum(nil))
+}
+
+// isCrossDevice 判断 os.Rename 的错误是否因为源和目标位于不同的文件系统。
+func isCrossDevice(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr) && errors.Is(linkErr.Err, errCrossDevice)
+}
@@ -0,0 +1,158 @@
+package fsutil
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMove(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		// files 是移动前 root 下的文件 (路径 -> 内容)，"->" 开头的内容表示符号链接
+		files map[string]string
+		src   string
+		dst   string
+		// copy 为 true 时直接调用跨文件系统时使用的复制实现，否则调用 Move
+		copy    bool
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name:  "rename file",
+			files: map[string]string{"a/x.txt": "x"},
+			src:   "a/x.txt", dst: "b.txt",
+			want: map[string]string{"b.txt": "x"},
+		},
+		{
+			name:  "copy file over an existing file",
+			files: map[string]string{"a/x.txt": "new", "b/x.txt": "old"},
+			src:   "a/x.txt", dst: "b/x.txt", copy: true,
+			want: map[string]string{"b/x.txt": "new"},
+		},
+		{
+			name:  "copy symlink",
+			files: map[string]string{"a/link": "->target"},
+			src:   "a/link", dst: "link", copy: true,
+			want: map[string]string{"link": "->target"},
+		},
+		{
+			name: "copy directory tree",
+			files: map[string]string{
+				"src/1.png":      "one",
+				"src/sub/2.png":  "two",
+				"src/sub/link":   "->1.png",
+				"src/empty/.nil": "",
+			},
+			src: "src", dst: "lib/dst", copy: true,
+			want: map[string]string{
+				"lib/dst/1.png":      "one",
+				"lib/dst/sub/2.png":  "two",
+				"lib/dst/sub/link":   "->1.png",
+				"lib/dst/empty/.nil": "",
+			},
+		},
+		{
+			name:  "copy directory onto an existing directory",
+			files: map[string]string{"src/1.png": "one", "dst/2.png": "two"},
+			src:   "src", dst: "dst", copy: true,
+			want:    map[string]string{"src/1.png": "one", "dst/2.png": "two"},
+			wantErr: fs.ErrExist,
+		},
+		{
+			name:  "missing source",
+			files: map[string]string{"a.txt": "a"},
+			src:   "missing", dst: "b.txt", copy: true,
+			want:    map[string]string{"a.txt": "a"},
+			wantErr: fs.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if target, ok := strings.CutPrefix(content, "->"); ok {
+					if err := os.Symlink(target, path); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.MkdirAll(filepath.Join(root, "lib"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			src, dst := filepath.Join(root, tt.src), filepath.Join(root, tt.dst)
+			var err error
+			if tt.copy {
+				err = copyMove(src, dst)
+			} else {
+				err = Move(src, dst)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("move error = %v, want %v", err, tt.wantErr)
+			}
+
+			got := map[string]string{}
+			filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				rel, _ := filepath.Rel(root, path)
+				if d.Type()&fs.ModeSymlink != 0 {
+					target, _ := os.Readlink(path)
+					got[filepath.ToSlash(rel)] = "->" + target
+					return nil
+				}
+				data, _ := os.ReadFile(path)
+				got[filepath.ToSlash(rel)] = string(data)
+				if info, err := d.Info(); err == nil && (!info.ModTime().Equal(mtime) || info.Mode().Perm() != 0640) {
+					t.Errorf("%s lost its modification time or mode: %v %v", rel, info.ModTime(), info.Mode())
+				}
+				return nil
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files after move = %v, want %v", sortedKeys(got), sortedKeys(tt.want))
+			}
+		})
+	}
+}
+
+// copyMove 与 MoveWithProgress 在 os.Rename 返回跨设备错误后的行为相同。
+func copyMove(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return moveFile(src, dst, info, nil)
+	}
+	return moveDir(src, dst, nil)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k+"="+m[k])
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// errCrossDevice 是 rename 跨文件系统时返回的错误。
+const errCrossDevice = syscall.EXDEV
+
+// syncDir 对目录执行 fsync，使其中新建、改名的条目在断电后仍然存在。
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
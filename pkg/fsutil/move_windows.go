@@ -0,0 +1,11 @@
+package fsutil
+
+import "syscall"
+
+// errCrossDevice 是 MoveFileEx 跨卷且未允许复制时返回的 ERROR_NOT_SAME_DEVICE。
+const errCrossDevice = syscall.Errno(17)
+
+// syncDir 在 Windows 上无需也无法对目录执行 fsync，NTFS 的目录项由文件系统日志保证。
+func syncDir(path string) error {
+	return nil
+}
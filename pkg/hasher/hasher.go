@@ -1,14 +1,13 @@
 package hasher
 
 import (
+	// 匿名导入格式注册表，确保所有支持的解码器都已注册
+	_ "PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"image"
-	// 匿名导入 (blank import) image解码器
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
 	"os"
 
@@ -30,7 +29,12 @@ func CalculatePerceptualHashFromImage(img image.Image) string {
 
 // CalculateSHA256 计算并返回一个文件的SHA-256哈希值。
 func CalculateSHA256(filePath string) (string, error) {
-	file, err := os.Open(filePath)
+	return CalculateSHA256FS(fsutil.OS, filePath)
+}
+
+// CalculateSHA256FS 与 CalculateSHA256 相同，只是从 fsys 中读取文件。
+func CalculateSHA256FS(fsys fsutil.FS, filePath string) (string, error) {
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		return "", err
 	}
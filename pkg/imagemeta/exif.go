@@ -0,0 +1,236 @@
+package imagemeta
+
+import (
+	"PICs_Manager/internal/models"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// EXIF 标签 ID (TIFF/EXIF 2.3 规范)
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFDPointer   = 0x8769
+	tagDateTimeOriginal = 0x9003
+
+	typeASCII = 2
+	typeShort = 3
+	typeLong  = 4
+
+	exifDateLayout = "2006:01:02 15:04:05"
+)
+
+// ErrNoEXIF 表示文件中没有 EXIF 数据，这是绝大多数 PNG/GIF 的正常情况。
+var ErrNoEXIF = errors.New("文件中未找到 EXIF 数据")
+
+// EXIF 是我们关心的那一小部分 EXIF 字段。
+type EXIF struct {
+	CameraMake  string
+	CameraModel string
+	Software    string
+	DateTaken   *time.Time
+	// Orientation 取值 1-8，0 表示文件中没有该标签。
+	Orientation int
+}
+
+// Model 把 EXIF 转换为数据库模型。
+func (e *EXIF) Model() *models.ImageEXIF {
+	return &models.ImageEXIF{
+		CameraMake:  e.CameraMake,
+		CameraModel: e.CameraModel,
+		Software:    e.Software,
+		DateTaken:   e.DateTaken,
+		Orientation: e.Orientation,
+	}
+}
+
+// ParseEXIF 从 JPEG (APP1)、PNG (eXIf) 或 WebP (EXIF chunk) 文件内容中提取 EXIF 字段。
+// 这是一个只读取少量标签的纯 Go 解析器，遇到任何越界或格式错误都会返回错误而不是 panic。
+func ParseEXIF(data []byte) (*EXIF, error) {
+	tiff, err := findTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(tiff)
+}
+
+// findTIFF 根据容器格式定位 EXIF 的 TIFF 数据块。
+func findTIFF(data []byte) ([]byte, error) {
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return findTIFFInJPEG(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return findTIFFInPNG(data)
+	case len(data) > 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return findTIFFInWebP(data)
+	}
+	return nil, ErrNoEXIF
+}
+
+func findTIFFInJPEG(data []byte) ([]byte, error) {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, ErrNoEXIF
+		}
+		marker := data[pos+1]
+		// SOS 之后是压缩数据，EOI 是文件结尾，都不会再出现 APP1
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, ErrNoEXIF
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return nil, ErrNoEXIF
+		}
+		payload := data[pos+4 : pos+2+segLen]
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return payload[6:], nil
+		}
+		pos += 2 + segLen
+	}
+	return nil, ErrNoEXIF
+}
+
+func findTIFFInPNG(data []byte) ([]byte, error) {
+	pos := 8
+	for pos+8 <= len(data) {
+		chunkLen := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		if chunkLen < 0 || pos+8+chunkLen > len(data) {
+			return nil, ErrNoEXIF
+		}
+		if chunkType == "eXIf" {
+			return data[pos+8 : pos+8+chunkLen], nil
+		}
+		if chunkType == "IDAT" || chunkType == "IEND" {
+			return nil, ErrNoEXIF
+		}
+		pos += 12 + chunkLen // len + type + data + crc
+	}
+	return nil, ErrNoEXIF
+}
+
+func findTIFFInWebP(data []byte) ([]byte, error) {
+	pos := 12
+	for pos+8 <= len(data) {
+		chunkType := string(data[pos : pos+4])
+		chunkLen := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		if chunkLen < 0 || pos+8+chunkLen > len(data) {
+			return nil, ErrNoEXIF
+		}
+		if chunkType == "EXIF" {
+			// 部分编码器会保留 JPEG 风格的 "Exif\0\0" 前缀
+			return bytes.TrimPrefix(data[pos+8:pos+8+chunkLen], []byte("Exif\x00\x00")), nil
+		}
+		pos += 8 + chunkLen + chunkLen%2 // RIFF chunk 按偶数字节对齐
+	}
+	return nil, ErrNoEXIF
+}
+
+// parseTIFF 解析 TIFF 头、IFD0 以及 EXIF 子 IFD 中我们关心的标签。
+func parseTIFF(tiff []byte) (*EXIF, error) {
+	if len(tiff) < 8 {
+		return nil, ErrNoEXIF
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("无效的 TIFF 字节序标记")
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return nil, errors.New("无效的 TIFF 标识")
+	}
+
+	result := &EXIF{}
+	var dateTime, dateTimeOriginal string
+	var exifIFD uint32
+
+	readIFD(tiff, order, order.Uint32(tiff[4:8]), func(tag, typ uint16, count uint32, valueField []byte) {
+		switch tag {
+		case tagMake:
+			result.CameraMake = readASCII(tiff, order, typ, count, valueField)
+		case tagModel:
+			result.CameraModel = readASCII(tiff, order, typ, count, valueField)
+		case tagSoftware:
+			result.Software = readASCII(tiff, order, typ, count, valueField)
+		case tagDateTime:
+			dateTime = readASCII(tiff, order, typ, count, valueField)
+		case tagOrientation:
+			if typ == typeShort {
+				result.Orientation = int(order.Uint16(valueField[0:2]))
+			}
+		case tagExifIFDPointer:
+			if typ == typeLong {
+				exifIFD = order.Uint32(valueField)
+			}
+		}
+	})
+	if exifIFD != 0 {
+		readIFD(tiff, order, exifIFD, func(tag, typ uint16, count uint32, valueField []byte) {
+			if tag == tagDateTimeOriginal {
+				dateTimeOriginal = readASCII(tiff, order, typ, count, valueField)
+			}
+		})
+	}
+
+	// 拍摄时间优先使用 DateTimeOriginal，DateTime 通常是最后修改时间
+	for _, raw := range []string{dateTimeOriginal, dateTime} {
+		if t, err := time.ParseInLocation(exifDateLayout, raw, time.Local); err == nil {
+			result.DateTaken = &t
+			break
+		}
+	}
+	if result.Orientation < 0 || result.Orientation > 8 {
+		result.Orientation = 0
+	}
+	return result, nil
+}
+
+// readIFD 遍历一个 IFD 中的所有条目，越界的 IFD 会被直接忽略。
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32, visit func(tag, typ uint16, count uint32, valueField []byte)) {
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return
+	}
+	n := int(order.Uint16(tiff[offset : offset+2]))
+	start := int(offset) + 2
+	for i := 0; i < n; i++ {
+		entry := start + i*12
+		if entry+12 > len(tiff) {
+			return
+		}
+		visit(
+			order.Uint16(tiff[entry:entry+2]),
+			order.Uint16(tiff[entry+2:entry+4]),
+			order.Uint32(tiff[entry+4:entry+8]),
+			tiff[entry+8:entry+12],
+		)
+	}
+}
+
+// readASCII 读取 ASCII 类型的标签值。不超过 4 字节的值直接内联在条目中，否则 valueField 是偏移量。
+func readASCII(tiff []byte, order binary.ByteOrder, typ uint16, count uint32, valueField []byte) string {
+	if typ != typeASCII || count == 0 {
+		return ""
+	}
+	var raw []byte
+	if count <= 4 {
+		raw = valueField[:count]
+	} else {
+		offset := uint64(order.Uint32(valueField))
+		if offset+uint64(count) > uint64(len(tiff)) {
+			return ""
+		}
+		raw = tiff[offset : offset+uint64(count)]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+}
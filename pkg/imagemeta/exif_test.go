@@ -0,0 +1,208 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEXIF(t *testing.T) {
+	taken := time.Date(2023, 5, 6, 7, 8, 9, 0, time.Local)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	full := []tiffEntry{
+		{tagMake, typeASCII, ascii("Canon")},
+		{tagModel, typeASCII, ascii("EOS R5")},
+		{tagSoftware, typeASCII, ascii("Firmware 1.0")},
+		{tagDateTime, typeASCII, ascii("2024:01:02 03:04:05")},
+		{tagOrientation, typeShort, short(6)},
+	}
+	fullEXIF := &EXIF{CameraMake: "Canon", CameraModel: "EOS R5", Software: "Firmware 1.0", DateTaken: &taken, Orientation: 6}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *EXIF
+		wantErr error // 为 nil 且 want 也为 nil 时只要求返回错误
+	}{
+		{
+			name: "jpeg big-endian prefers DateTimeOriginal",
+			data: jpegWithEXIF(buildTIFF(binary.BigEndian, full, []tiffEntry{{tagDateTimeOriginal, typeASCII, ascii("2023:05:06 07:08:09")}})),
+			want: fullEXIF,
+		},
+		{
+			name: "png little-endian falls back to DateTime",
+			data: pngWithEXIF(buildTIFF(binary.LittleEndian, full, nil)),
+			want: &EXIF{CameraMake: "Canon", CameraModel: "EOS R5", Software: "Firmware 1.0", DateTaken: &modified, Orientation: 6},
+		},
+		{
+			name: "webp with jpeg-style prefix",
+			data: webpWithEXIF(append([]byte("Exif\x00\x00"), buildTIFF(binary.LittleEndian, []tiffEntry{{tagOrientation, typeShort, short(3)}}, nil)...)),
+			want: &EXIF{Orientation: 3},
+		},
+		{
+			name: "short ascii values are stored inline",
+			data: jpegWithEXIF(buildTIFF(binary.LittleEndian, []tiffEntry{{tagMake, typeASCII, ascii("HTC")}}, nil)),
+			want: &EXIF{CameraMake: "HTC"},
+		},
+		{
+			name: "out-of-range orientation is dropped",
+			data: jpegWithEXIF(buildTIFF(binary.BigEndian, []tiffEntry{{tagOrientation, typeShort, short(9)}}, nil)),
+			want: &EXIF{},
+		},
+		{
+			name: "wrong tag types are ignored",
+			data: jpegWithEXIF(buildTIFF(binary.BigEndian, []tiffEntry{{tagMake, typeShort, short(1)}, {tagOrientation, typeLong, []byte{0, 0, 0, 6}}}, nil)),
+			want: &EXIF{},
+		},
+		{
+			name: "ifd offset past the end is ignored",
+			data: jpegWithEXIF([]byte{'I', 'I', 42, 0, 0xff, 0xff, 0, 0}),
+			want: &EXIF{},
+		},
+		{
+			name:    "png without eXIf chunk",
+			data:    append([]byte("\x89PNG\r\n\x1a\n"), chunk(binary.BigEndian, "IDAT", nil, true)...),
+			wantErr: ErrNoEXIF,
+		},
+		{
+			name:    "jpeg segment longer than the file",
+			data:    []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x', 'i', 'f'},
+			wantErr: ErrNoEXIF,
+		},
+		{
+			name:    "unknown container",
+			data:    []byte("GIF89a"),
+			wantErr: ErrNoEXIF,
+		},
+		{
+			name: "invalid byte order",
+			data: jpegWithEXIF([]byte("XX\x00\x2a\x00\x00\x00\x08")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEXIF(tt.data)
+			if tt.want == nil {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("ParseEXIF() = %+v, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEXIF() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEXIF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// byteOrder 是 binary.LittleEndian 和 binary.BigEndian 共同实现的接口。
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type tiffEntry struct {
+	tag, typ uint16
+	value    []byte
+}
+
+func ascii(s string) []byte { return append([]byte(s), 0) }
+
+func short(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+
+// buildTIFF 生成一个只有 IFD0 (以及 exif 非空时的 EXIF 子 IFD) 的 TIFF 数据块。
+// short 的值按大端给出，写入时转换为 order；超过 4 字节的值写在所有 IFD 之后。
+func buildTIFF(order byteOrder, ifd0, exif []tiffEntry) []byte {
+	if len(exif) > 0 {
+		ifd0 = append(append([]tiffEntry(nil), ifd0...), tiffEntry{tagExifIFDPointer, typeLong, nil})
+	}
+	ifdSize := func(entries []tiffEntry) int { return 2 + 12*len(entries) + 4 }
+	exifOffset := 8 + ifdSize(ifd0)
+	dataOffset := exifOffset
+	if len(exif) > 0 {
+		dataOffset += ifdSize(exif)
+	}
+
+	var data []byte
+	writeIFD := func(entries []tiffEntry) []byte {
+		ifd := order.AppendUint16(nil, uint16(len(entries)))
+		for _, e := range entries {
+			ifd = order.AppendUint16(ifd, e.tag)
+			ifd = order.AppendUint16(ifd, e.typ)
+			field := make([]byte, 4)
+			switch {
+			case e.tag == tagExifIFDPointer:
+				order.PutUint32(field, uint32(exifOffset))
+				ifd = order.AppendUint32(ifd, 1)
+			case e.typ == typeShort:
+				order.PutUint16(field, binary.BigEndian.Uint16(e.value))
+				ifd = order.AppendUint32(ifd, 1)
+			case len(e.value) <= 4:
+				copy(field, e.value)
+				ifd = order.AppendUint32(ifd, uint32(len(e.value)))
+			default:
+				order.PutUint32(field, uint32(dataOffset+len(data)))
+				data = append(data, e.value...)
+				ifd = order.AppendUint32(ifd, uint32(len(e.value)))
+			}
+			ifd = append(ifd, field...)
+		}
+		return order.AppendUint32(ifd, 0)
+	}
+
+	tiff := []byte("II")
+	if order == binary.BigEndian {
+		tiff = []byte("MM")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = append(tiff, writeIFD(ifd0)...)
+	if len(exif) > 0 {
+		tiff = append(tiff, writeIFD(exif)...)
+	}
+	return append(tiff, data...)
+}
+
+func jpegWithEXIF(tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(payload)+2))
+	data = append(data, payload...)
+	return append(data, 0xFF, 0xD9)
+}
+
+func pngWithEXIF(tiff []byte) []byte {
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = append(data, chunk(binary.BigEndian, "eXIf", tiff, true)...)
+	return append(data, chunk(binary.BigEndian, "IEND", nil, true)...)
+}
+
+func webpWithEXIF(exif []byte) []byte {
+	body := append([]byte("WEBP"), chunk(binary.LittleEndian, "EXIF", exif, false)...)
+	data := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body)))
+	return append(data, body...)
+}
+
+// chunk 生成一个 PNG chunk (长度在类型之前，末尾有 CRC) 或 RIFF chunk (类型在长度之前，按偶数字节对齐)。
+// 解析器不校验 CRC，这里写入 0。
+func chunk(order byteOrder, typ string, data []byte, png bool) []byte {
+	var c []byte
+	if png {
+		c = order.AppendUint32(nil, uint32(len(data)))
+		c = append(c, typ...)
+	} else {
+		c = order.AppendUint32([]byte(typ), uint32(len(data)))
+	}
+	c = append(c, data...)
+	if png {
+		return append(c, 0, 0, 0, 0)
+	}
+	return append(c, bytes.Repeat([]byte{0}, len(data)%2)...)
+}
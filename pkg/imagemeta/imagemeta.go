@@ -0,0 +1,97 @@
+// Package imagemeta 从已读入内存的图片中提取技术元数据 (尺寸、格式、色彩模型、动画帧数和 EXIF)。
+package imagemeta
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+)
+
+// Info 是一张图片的技术元数据。
+type Info struct {
+	// Width / Height 是按 EXIF 方向校正后的显示尺寸。
+	Width      int
+	Height     int
+	Format     string
+	FileSize   int64
+	ColorModel string
+	// FrameCount 只对 GIF 有意义，其余格式恒为 1。
+	FrameCount int
+	// Duration 是 GIF 动画一轮播放的总时长 (秒)。
+	Duration float64
+	// EXIF 在文件不含 EXIF 时为 nil。
+	EXIF *EXIF
+}
+
+// Extract 根据原始文件内容和已解码的图片计算技术元数据。
+// img 和 format 应来自调用方对同一份 data 的 image.Decode，避免重复解码。
+func Extract(data []byte, img image.Image, format string) Info {
+	info := Info{
+		Format:     format,
+		FileSize:   int64(len(data)),
+		FrameCount: 1,
+	}
+	if img != nil {
+		bounds := img.Bounds()
+		info.Width, info.Height = bounds.Dx(), bounds.Dy()
+		info.ColorModel = colorModelName(img.ColorModel())
+	}
+	if format == "gif" {
+		// DecodeConfig 拿不到帧数，只能完整解码一次；解码失败时保留默认值 1
+		if g, err := gif.DecodeAll(bytes.NewReader(data)); err == nil {
+			info.FrameCount = len(g.Image)
+			for _, delay := range g.Delay {
+				info.Duration += float64(delay) / 100 // 单位为 1/100 秒
+			}
+		}
+	}
+	if exif, err := ParseEXIF(data); err == nil {
+		info.EXIF = exif
+		// 方向 5-8 包含 90° 旋转，显示尺寸与像素尺寸宽高互换
+		if exif.Orientation >= 5 && exif.Orientation <= 8 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	return info
+}
+
+// Orientation 返回 EXIF 方向标签，没有 EXIF 时返回 1 (正常朝向)。
+func (i Info) Orientation() int {
+	if i.EXIF == nil || i.EXIF.Orientation == 0 {
+		return 1
+	}
+	return i.EXIF.Orientation
+}
+
+// colorModelName 把标准库的色彩模型映射为可读名称。
+func colorModelName(m color.Model) string {
+	switch m {
+	case color.RGBAModel:
+		return "RGBA"
+	case color.RGBA64Model:
+		return "RGBA64"
+	case color.NRGBAModel:
+		return "NRGBA"
+	case color.NRGBA64Model:
+		return "NRGBA64"
+	case color.AlphaModel:
+		return "Alpha"
+	case color.Alpha16Model:
+		return "Alpha16"
+	case color.GrayModel:
+		return "Gray"
+	case color.Gray16Model:
+		return "Gray16"
+	case color.YCbCrModel:
+		return "YCbCr"
+	case color.NYCbCrAModel:
+		return "NYCbCrA"
+	case color.CMYKModel:
+		return "CMYK"
+	}
+	if _, ok := m.(color.Palette); ok {
+		return "Paletted"
+	}
+	return "Unknown"
+}
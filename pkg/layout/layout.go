@@ -0,0 +1,198 @@
+// Package layout 决定系列文件夹在最终库中的归档位置。
+// 扫描归档、库内整理 (重命名、拆分) 和布局迁移都通过同一个 Strategy 计算目标目录，
+// 因此切换 scanner.libraryLayout 后只需执行一次迁移，就能让已有的库与新文件保持一致。
+package layout
+
+import (
+	"PICs_Manager/pkg/romanize"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 可用的布局名称，对应 scanner.libraryLayout 配置项。
+const (
+	FirstLetter     = "first-letter"
+	FirstTwoLetters = "first-two-letters"
+	Artist          = "artist"
+	Date            = "date"
+	Flat            = "flat"
+)
+
+// otherBucket 是无法归入任何字母的系列所在的目录。
+const otherBucket = "#"
+
+// unsortedBucket 是 artist 布局中找不到作者信息的系列所在的目录。
+const unsortedBucket = "_unsorted"
+
+// SeriesInfo 是计算归档位置所需的系列信息。
+// 扫描归档新系列时系列尚未入库，只有 Name 和 CreatedAt (当前时间) 可用。
+type SeriesInfo struct {
+	Name      string
+	SortName  string // 排序名 (含手动覆盖)，为空时按 Name 罗马字化
+	Author    string // 下载器元数据中的作者 (Series.Source.Author)，没有时为空
+	CreatedAt time.Time
+}
+
+// Strategy 是一种最终库布局。
+type Strategy interface {
+	// Name 返回布局名称。
+	Name() string
+	// Bucket 返回系列所在的归档目录 (相对于最终库根目录)，为空表示直接放在根目录下。
+	Bucket(info SeriesInfo) string
+	// Depth 返回归档目录的层数，即系列文件夹 (或聚合目录) 位于根目录下的第几层减一。
+	Depth() int
+	// FixedBuckets 返回需要预先创建、且是根目录下唯一合法的目录；返回 nil 表示不限制。
+	FixedBuckets() []string
+}
+
+// New 按名称创建布局，名称为空时使用默认的 first-letter。
+func New(name string) (Strategy, error) {
+	switch name {
+	case "", FirstLetter:
+		return firstLetter{}, nil
+	case FirstTwoLetters:
+		return firstTwoLetters{}, nil
+	case Artist:
+		return artist{}, nil
+	case Date:
+		return date{}, nil
+	case Flat:
+		return flat{}, nil
+	}
+	return nil, fmt.Errorf("未知的库布局 '%s'，可选 %s, %s, %s, %s, %s", name, FirstLetter, FirstTwoLetters, Artist, Date, Flat)
+}
+
+// SeriesDir 返回系列文件夹在 root 下的完整路径。
+func SeriesDir(s Strategy, root string, info SeriesInfo) string {
+	return filepath.Join(root, s.Bucket(info), info.Name)
+}
+
+// --- first-letter: A-Z / # (默认) ---
+
+type firstLetter struct{}
+
+func (firstLetter) Name() string { return FirstLetter }
+func (firstLetter) Depth() int   { return 1 }
+
+func (firstLetter) Bucket(info SeriesInfo) string {
+	if r := alphaNums(info); len(r) > 0 && r[0] >= 'A' && r[0] <= 'Z' {
+		return string(r[0])
+	}
+	return otherBucket
+}
+
+func (firstLetter) FixedBuckets() []string {
+	buckets := make([]string, 0, 27)
+	for r := 'A'; r <= 'Z'; r++ {
+		buckets = append(buckets, string(r))
+	}
+	return append(buckets, otherBucket)
+}
+
+// --- first-two-letters: AB / A / # ---
+
+type firstTwoLetters struct{}
+
+func (firstTwoLetters) Name() string           { return FirstTwoLetters }
+func (firstTwoLetters) Depth() int             { return 1 }
+func (firstTwoLetters) FixedBuckets() []string { return nil }
+
+func (firstTwoLetters) Bucket(info SeriesInfo) string {
+	r := alphaNums(info)
+	if len(r) == 0 || r[0] < 'A' || r[0] > 'Z' {
+		return otherBucket
+	}
+	if len(r) == 1 {
+		return string(r[0])
+	}
+	return string(r[:2])
+}
+
+// --- artist: 按作者分目录 ---
+
+type artist struct{}
+
+// leadingBracket 匹配常见的 "[社团 (作者)] 标题" 或 "[作者] 标题" 命名。
+var leadingBracket = regexp.MustCompile(`^\s*[\[【]([^\]】]+)[\]】]`)
+var innerParen = regexp.MustCompile(`[(（]([^)）]+)[)）]`)
+
+func (artist) Name() string           { return Artist }
+func (artist) Depth() int             { return 1 }
+func (artist) FixedBuckets() []string { return nil }
+
+// Bucket 依次使用下载器元数据中的作者、名称开头方括号中的作者 (括号内优先于社团名)。
+// 新扫描的系列还没有入库，因此只会按名称归类；入库后可以重新执行迁移，按元数据中的作者归档。
+func (artist) Bucket(info SeriesInfo) string {
+	if strings.TrimSpace(info.Author) != "" {
+		return sanitize(info.Author)
+	}
+	if m := leadingBracket.FindStringSubmatch(info.Name); m != nil {
+		name := m[1]
+		if p := innerParen.FindStringSubmatch(name); p != nil {
+			name = p[1]
+		}
+		if name = strings.TrimSpace(name); name != "" {
+			return sanitize(name)
+		}
+	}
+	return unsortedBucket
+}
+
+// --- date: 按入库年月分目录 (2024/05) ---
+
+type date struct{}
+
+func (date) Name() string           { return Date }
+func (date) Depth() int             { return 2 }
+func (date) FixedBuckets() []string { return nil }
+
+func (date) Bucket(info SeriesInfo) string {
+	t := info.CreatedAt
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return filepath.Join(t.Format("2006"), t.Format("01"))
+}
+
+// --- flat: 所有系列直接放在根目录下 ---
+
+type flat struct{}
+
+func (flat) Name() string                  { return Flat }
+func (flat) Depth() int                    { return 0 }
+func (flat) FixedBuckets() []string        { return nil }
+func (flat) Bucket(info SeriesInfo) string { return "" }
+
+// alphaNums 返回排序名中的所有字母和数字 (大写)，与列表排序使用同一套罗马字规则。
+func alphaNums(info SeriesInfo) []rune {
+	name := info.SortName
+	if name == "" {
+		name = info.Name
+	}
+	var out []rune
+	for _, r := range romanize.Romanize(name) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			out = append(out, unicode.ToUpper(r))
+		}
+	}
+	return out
+}
+
+// sanitize 去掉不能出现在目录名中的字符。
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return unsortedBucket
+	}
+	return name
+}
@@ -0,0 +1,84 @@
+package layout
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	created := time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		layout string
+		info   SeriesInfo
+		want   string
+	}{
+		{FirstLetter, SeriesInfo{Name: "alpha"}, "A"},
+		{FirstLetter, SeriesInfo{Name: "東方"}, "D"}, // 按拼音
+		{FirstLetter, SeriesInfo{Name: "[Circle] title"}, "C"},
+		{FirstLetter, SeriesInfo{Name: "123"}, otherBucket},
+		{FirstLetter, SeriesInfo{Name: "!!!"}, otherBucket},
+		{FirstLetter, SeriesInfo{Name: "東方", SortName: "touhou"}, "T"},
+		{"", SeriesInfo{Name: "zeta"}, "Z"},
+		{FirstTwoLetters, SeriesInfo{Name: "alpha"}, "AL"},
+		{FirstTwoLetters, SeriesInfo{Name: "a"}, "A"},
+		{FirstTwoLetters, SeriesInfo{Name: "a-b"}, "AB"},
+		{FirstTwoLetters, SeriesInfo{Name: "7up"}, otherBucket},
+		{Artist, SeriesInfo{Name: "[Circle (Author)] title", Author: " Meta/Author "}, "Meta_Author"},
+		{Artist, SeriesInfo{Name: "[Circle (Author)] title"}, "Author"},
+		{Artist, SeriesInfo{Name: "【作者】标题"}, "作者"},
+		{Artist, SeriesInfo{Name: "[ ] title"}, unsortedBucket},
+		{Artist, SeriesInfo{Name: "[..] title"}, unsortedBucket},
+		{Artist, SeriesInfo{Name: "title"}, unsortedBucket},
+		{Date, SeriesInfo{Name: "x", CreatedAt: created}, filepath.Join("2024", "05")},
+		{Flat, SeriesInfo{Name: "x"}, ""},
+	}
+	for _, tt := range tests {
+		s, err := New(tt.layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Bucket(tt.info); got != tt.want {
+			t.Errorf("%s.Bucket(%+v) = %q, want %q", s.Name(), tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantName  string
+		wantDepth int
+	}{
+		{"", FirstLetter, 1},
+		{FirstLetter, FirstLetter, 1},
+		{FirstTwoLetters, FirstTwoLetters, 1},
+		{Artist, Artist, 1},
+		{Date, Date, 2},
+		{Flat, Flat, 0},
+	}
+	for _, tt := range tests {
+		s, err := New(tt.name)
+		if err != nil {
+			t.Fatalf("New(%q): %v", tt.name, err)
+		}
+		if s.Name() != tt.wantName || s.Depth() != tt.wantDepth {
+			t.Errorf("New(%q) = %s with depth %d, want %s with depth %d", tt.name, s.Name(), s.Depth(), tt.wantName, tt.wantDepth)
+		}
+	}
+	if _, err := New("by-color"); err == nil {
+		t.Error("New(\"by-color\") succeeded, want an error")
+	}
+}
+
+func TestSeriesDir(t *testing.T) {
+	s, _ := New(FirstLetter)
+	root := filepath.FromSlash("/lib")
+	if got, want := SeriesDir(s, root, SeriesInfo{Name: "beta"}), filepath.Join(root, "B", "beta"); got != want {
+		t.Errorf("SeriesDir() = %q, want %q", got, want)
+	}
+	s, _ = New(Flat)
+	if got, want := SeriesDir(s, root, SeriesInfo{Name: "beta"}), filepath.Join(root, "beta"); got != want {
+		t.Errorf("SeriesDir() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,697 @@
+// Package library 提供对最终库中系列的整理操作 (重命名、合并、拆分、移动和删除)。
+// 每个操作都会同时移动磁盘上的文件并更新数据库中的 series / images 文档；
+// 数据库更新失败时会把已经移动的文件移回原处，保证磁盘与数据库一致。
+package library
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/archive"
+	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/layout"
+	"PICs_Manager/pkg/romanize"
+	"PICs_Manager/pkg/sidecar"
+	"PICs_Manager/pkg/trash"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// aggSuffix 与 scanner 中聚合父目录的后缀保持一致。
+const aggSuffix = "_agg"
+
+var (
+	// ErrSeriesNotFound 表示系列不存在。
+	ErrSeriesNotFound = errors.New("系列不存在")
+	// ErrNameTaken 表示目标名称已被其他系列或文件夹占用。
+	ErrNameTaken = errors.New("该名称已被占用")
+	// ErrInvalidRequest 表示请求参数无效，例如非法名称或选择了不属于该系列的图片。
+	ErrInvalidRequest = errors.New("无效的请求")
+)
+
+// 所有整理操作串行执行，避免两个请求同时移动同一批文件。
+var opMu sync.Mutex
+
+// Editor 定义了对库中系列的整理操作。
+type Editor interface {
+	// Rename 重命名系列，必要时移动到新的归档字母目录。
+	Rename(ctx context.Context, seriesID primitive.ObjectID, newName string) (*models.Series, error)
+	// Merge 把 srcID 的全部文件并入 dstID 并删除 srcID，同名文件以 "name (n).ext" 的形式并存。
+	Merge(ctx context.Context, srcID, dstID primitive.ObjectID) (*models.Series, error)
+	// Split 把选中的图片移动到一个新系列中，并返回新系列。
+	Split(ctx context.Context, srcID primitive.ObjectID, imageIDs []primitive.ObjectID, newName string) (*models.Series, error)
+	// MoveImages 把选中的图片 (可来自不同系列) 移动到 dstID，并返回更新后的目标系列。
+	MoveImages(ctx context.Context, imageIDs []primitive.ObjectID, dstID primitive.ObjectID) (*models.Series, error)
+	// DeleteImages 把选中的图片移入回收站并删除其记录，返回受影响的系列。
+	DeleteImages(ctx context.Context, imageIDs []primitive.ObjectID) ([]primitive.ObjectID, error)
+	// DeleteSeries 把整个系列文件夹移入回收站，并删除系列、其所有图片以及个人状态记录。
+	DeleteSeries(ctx context.Context, seriesID primitive.ObjectID) error
+	// SetSortName 设置系列的排序名覆盖 (空字符串表示恢复自动罗马字化)，归档目录随之变化时移动系列文件夹。
+	SetSortName(ctx context.Context, seriesID primitive.ObjectID, override string) (*models.Series, error)
+	// MigrateLayout 把整个库整理成当前布局，dryRun 为 true 时只返回移动计划。
+	MigrateLayout(ctx context.Context, dryRun bool) (*MigrationReport, error)
+}
+
+type fsEditor struct {
+	libraryPath string
+	layout      layout.Strategy
+	bin         trash.Manager
+	store       database.Store
+}
+
+// NewEditor 创建一个作用于 finalLibraryPath 的 Editor，新建或重命名的系列按 libraryLayout 归档，删除的文件会被移入 bin。
+// bin 为 nil 时删除操作返回 trash.ErrNotConfigured，其余操作不受影响。
+func NewEditor(finalLibraryPath string, libraryLayout layout.Strategy, bin trash.Manager, store database.Store) (Editor, error) {
+	absPath, err := filepath.Abs(finalLibraryPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取最终库路径的绝对路径 '%s': %w", finalLibraryPath, err)
+	}
+	return &fsEditor{libraryPath: absPath, layout: libraryLayout, bin: bin, store: store}, nil
+}
+
+// move 记录一次文件移动，以便在失败时回滚。
+type move struct{ from, to string }
+
+type mover struct{ done []move }
+
+func (m *mover) rename(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := fsutil.Move(from, to); err != nil {
+		return err
+	}
+	m.done = append(m.done, move{from, to})
+	return nil
+}
+
+// renameWithSidecar 移动媒体文件，并把它旁边的下载器元数据文件一起移走，保持原有的命名方式。
+func (m *mover) renameWithSidecar(from, to string) error {
+	sc := sidecar.Path(from)
+	if err := m.rename(from, to); err != nil {
+		return err
+	}
+	if sc == "" {
+		return nil
+	}
+	return m.rename(sc, sidecar.MovedPath(sc, from, to))
+}
+
+// rollback 按相反顺序撤销所有已完成的移动。
+func (m *mover) rollback() {
+	for i := len(m.done) - 1; i >= 0; i-- {
+		mv := m.done[i]
+		if err := fsutil.Move(mv.to, mv.from); err != nil {
+			log.Printf("严重错误: 回滚移动 %s -> %s 失败: %v", mv.to, mv.from, err)
+		}
+	}
+}
+
+func (e *fsEditor) Rename(ctx context.Context, seriesID primitive.ObjectID, newName string) (*models.Series, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	series, err := e.loadSeries(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	newName, err = validateName(newName)
+	if err != nil {
+		return nil, err
+	}
+	if newName == series.Name {
+		return series, nil
+	}
+	if err := e.ensureNameFree(ctx, newName); err != nil {
+		return nil, err
+	}
+
+	oldDir := series.Path
+	newDir := e.seriesDir(series, newName)
+	if _, err := os.Stat(newDir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNameTaken, newDir)
+	}
+	images, err := e.store.Images().GetAllBySeriesID(ctx, series.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mv mover
+	if err := mv.rename(oldDir, newDir); err != nil {
+		return nil, fmt.Errorf("移动系列文件夹失败: %w", err)
+	}
+	rewrite := func(path string) string { return rebase(path, oldDir, newDir) }
+	if err := e.relocateImages(ctx, images, series.ID, rewrite); err != nil {
+		mv.rollback()
+		return nil, fmt.Errorf("更新图片记录失败，已撤销移动: %w", err)
+	}
+	renamed := *series
+	renamed.Name, renamed.Path = newName, newDir
+	if err := e.store.Series().Update(ctx, &renamed); err != nil {
+		e.restoreImages(ctx, images)
+		mv.rollback()
+		return nil, fmt.Errorf("更新系列记录失败，已撤销移动: %w", err)
+	}
+	e.removeEmptyParent(oldDir)
+	return &renamed, nil
+}
+
+func (e *fsEditor) SetSortName(ctx context.Context, seriesID primitive.ObjectID, override string) (*models.Series, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	series, err := e.loadSeries(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	override = strings.TrimSpace(override)
+	if override == series.SortNameOverride {
+		return series, nil
+	}
+
+	updated := *series
+	updated.SortNameOverride = override
+	newDir := e.seriesDir(&updated, series.Name)
+	if newDir == series.Path {
+		if err := e.store.Series().Update(ctx, &updated); err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNameTaken, newDir)
+	}
+
+	// 归档目录变化时复用布局迁移的移动逻辑，它会同时更新系列和图片的路径
+	updated.Path = series.Path
+	if err := e.moveUnit(ctx, unit{path: series.Path, series: []models.Series{updated}}, newDir); err != nil {
+		// moveUnit 的回滚会写回带新覆盖值的记录，这里恢复为原始记录
+		if rerr := e.store.Series().Update(ctx, series); rerr != nil {
+			log.Printf("严重错误: 回滚系列 %s 的排序名失败: %v", series.Name, rerr)
+		}
+		return nil, err
+	}
+	e.removeEmptyParent(series.Path)
+	return e.store.Series().GetByID(ctx, series.ID)
+}
+
+func (e *fsEditor) Merge(ctx context.Context, srcID, dstID primitive.ObjectID) (*models.Series, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	if srcID == dstID {
+		return nil, fmt.Errorf("%w: 不能把系列合并到自身", ErrInvalidRequest)
+	}
+	src, err := e.loadSeries(ctx, srcID)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := e.loadSeries(ctx, dstID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := e.store.Images().GetAllBySeriesID(ctx, src.ID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("读取系列文件夹失败: %w", err)
+	}
+
+	// 元数据文件跟随对应的媒体文件移动，避免媒体文件因重名改名后与元数据文件对不上
+	sidecars := make(map[string]struct{})
+	for _, entry := range entries {
+		if sc := sidecar.Path(filepath.Join(src.Path, entry.Name())); !entry.IsDir() && sc != "" {
+			sidecars[sc] = struct{}{}
+		}
+	}
+
+	// 逐个移动顶层条目 (图片、压缩包或子目录)，并记录新旧路径的对应关系
+	var mv mover
+	renamed := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		from := filepath.Join(src.Path, entry.Name())
+		if _, ok := sidecars[from]; ok {
+			continue
+		}
+		to := fsutil.AvailablePath(fsutil.OS, filepath.Join(dst.Path, entry.Name()))
+		if err := mv.renameWithSidecar(from, to); err != nil {
+			mv.rollback()
+			return nil, fmt.Errorf("移动 %s 失败，已撤销: %w", entry.Name(), err)
+		}
+		renamed[from] = to
+	}
+	rewrite := func(path string) string {
+		for from, to := range renamed {
+			if p, ok := rebaseEntry(path, from, to); ok {
+				return p
+			}
+		}
+		return rebase(path, src.Path, dst.Path)
+	}
+	if err := e.relocateImages(ctx, images, dst.ID, rewrite); err != nil {
+		mv.rollback()
+		return nil, fmt.Errorf("更新图片记录失败，已撤销移动: %w", err)
+	}
+	if err := e.store.Series().Delete(ctx, src.ID); err != nil {
+		e.restoreImages(ctx, images)
+		mv.rollback()
+		return nil, fmt.Errorf("删除源系列失败，已撤销移动: %w", err)
+	}
+
+	if err := e.store.UserState().DeleteBySeriesID(ctx, src.ID); err != nil {
+		log.Printf("警告: 清理系列 %s 的个人状态失败: %v", src.ID.Hex(), err)
+	}
+	if err := os.Remove(src.Path); err != nil {
+		log.Printf("警告: 删除已合并的系列文件夹 %s 失败: %v", src.Path, err)
+	}
+	e.removeEmptyParent(src.Path)
+	return e.refreshMetadata(ctx, dst)
+}
+
+func (e *fsEditor) Split(ctx context.Context, srcID primitive.ObjectID, imageIDs []primitive.ObjectID, newName string) (*models.Series, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	if len(imageIDs) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要选择一张图片", ErrInvalidRequest)
+	}
+	src, err := e.loadSeries(ctx, srcID)
+	if err != nil {
+		return nil, err
+	}
+	newName, err = validateName(newName)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.ensureNameFree(ctx, newName); err != nil {
+		return nil, err
+	}
+	newDir := layout.SeriesDir(e.layout, e.libraryPath, layout.SeriesInfo{Name: newName, CreatedAt: time.Now()})
+	if _, err := os.Stat(newDir); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNameTaken, newDir)
+	}
+
+	images, err := e.selectImages(ctx, imageIDs, src)
+	if err != nil {
+		return nil, err
+	}
+
+	mv, rewrite, err := moveFiles(images, newDir)
+	if err != nil {
+		os.Remove(newDir)
+		return nil, err
+	}
+	created := &models.Series{ID: primitive.NewObjectID(), Name: newName, Path: newDir}
+	if err := e.store.Series().Create(ctx, created); err != nil {
+		mv.rollback()
+		os.Remove(newDir)
+		return nil, fmt.Errorf("创建新系列失败，已撤销移动: %w", err)
+	}
+	if err := e.relocateImages(ctx, images, created.ID, rewrite); err != nil {
+		e.store.Series().Delete(ctx, created.ID)
+		mv.rollback()
+		os.Remove(newDir)
+		return nil, fmt.Errorf("更新图片记录失败，已撤销移动: %w", err)
+	}
+
+	if _, err := e.refreshMetadata(ctx, src); err != nil {
+		log.Printf("警告: 更新系列 %s 的元数据失败: %v", src.Name, err)
+	}
+	return e.refreshMetadata(ctx, created)
+}
+
+func (e *fsEditor) MoveImages(ctx context.Context, imageIDs []primitive.ObjectID, dstID primitive.ObjectID) (*models.Series, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	if len(imageIDs) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要选择一张图片", ErrInvalidRequest)
+	}
+	dst, err := e.loadSeries(ctx, dstID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := e.selectImages(ctx, imageIDs, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 已经在目标系列中的图片无需移动
+	pending := images[:0]
+	for _, img := range images {
+		if img.SeriesID != dst.ID {
+			pending = append(pending, img)
+		}
+	}
+	if len(pending) == 0 {
+		return dst, nil
+	}
+
+	mv, rewrite, err := moveFiles(pending, dst.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.relocateImages(ctx, pending, dst.ID, rewrite); err != nil {
+		mv.rollback()
+		return nil, fmt.Errorf("更新图片记录失败，已撤销移动: %w", err)
+	}
+	e.refreshSeries(ctx, sourceSeries(pending))
+	return e.refreshMetadata(ctx, dst)
+}
+
+func (e *fsEditor) DeleteImages(ctx context.Context, imageIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	if len(imageIDs) == 0 {
+		return nil, fmt.Errorf("%w: 至少需要选择一张图片", ErrInvalidRequest)
+	}
+	images, err := e.selectImages(ctx, imageIDs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.bin == nil {
+		return nil, trash.ErrNotConfigured
+	}
+	var trashed []*models.TrashItem
+	writes := make([]mongo.WriteModel, 0, len(images))
+	for _, img := range images {
+		item, err := e.bin.Discard(ctx, img.FilePath, trash.SourceAPI, "删除图片")
+		if err != nil {
+			e.untrash(ctx, trashed)
+			return nil, fmt.Errorf("移动 %s 到回收站失败，已撤销: %w", img.FileName, err)
+		}
+		trashed = append(trashed, item)
+		if sc := sidecar.Path(img.FilePath); sc != "" {
+			if item, err := e.bin.Discard(ctx, sc, trash.SourceAPI, "删除图片 "+img.FileName+" 的元数据"); err != nil {
+				log.Printf("警告: 移动元数据文件 %s 到回收站失败: %v", sc, err)
+			} else {
+				trashed = append(trashed, item)
+			}
+		}
+		writes = append(writes, mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": img.ID}))
+	}
+	if err := e.store.Images().BulkWrite(ctx, writes); err != nil {
+		e.untrash(ctx, trashed)
+		return nil, fmt.Errorf("删除图片记录失败，已撤销移动: %w", err)
+	}
+
+	affected := sourceSeries(images)
+	e.refreshSeries(ctx, affected)
+	return affected, nil
+}
+
+func (e *fsEditor) DeleteSeries(ctx context.Context, seriesID primitive.ObjectID) error {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	series, err := e.store.Series().GetByID(ctx, seriesID)
+	if err != nil {
+		return err
+	}
+	if series == nil {
+		return ErrSeriesNotFound
+	}
+
+	// 文件夹已经不存在时只清理数据库记录
+	var trashed []*models.TrashItem
+	if _, err := os.Stat(series.Path); err == nil {
+		if !isWithin(series.Path, e.libraryPath) {
+			return fmt.Errorf("%w: 系列路径 '%s' 不在库内", ErrInvalidRequest, series.Path)
+		}
+		if e.bin == nil {
+			return trash.ErrNotConfigured
+		}
+		item, err := e.bin.Discard(ctx, series.Path, trash.SourceAPI, "删除系列 "+series.Name)
+		if err != nil {
+			return fmt.Errorf("移动系列文件夹到回收站失败: %w", err)
+		}
+		trashed = append(trashed, item)
+	}
+	if _, err := e.store.Images().DeleteBySeriesID(ctx, series.ID); err != nil {
+		e.untrash(ctx, trashed)
+		return fmt.Errorf("删除图片记录失败，已撤销移动: %w", err)
+	}
+	if err := e.store.Series().Delete(ctx, series.ID); err != nil {
+		// 图片记录已删除，文件夹留在回收站中，重新扫描前需要手动找回
+		return fmt.Errorf("删除系列记录失败: %w", err)
+	}
+	if err := e.store.UserState().DeleteBySeriesID(ctx, series.ID); err != nil {
+		log.Printf("警告: 清理系列 %s 的个人状态失败: %v", series.ID.Hex(), err)
+	}
+	e.removeEmptyParent(series.Path)
+	return nil
+}
+
+// untrash 把本次操作中已经移入回收站的条目恢复原位，用于回滚。
+func (e *fsEditor) untrash(ctx context.Context, items []*models.TrashItem) {
+	for i := len(items) - 1; i >= 0; i-- {
+		if _, err := e.bin.Restore(ctx, items[i].ID); err != nil {
+			log.Printf("严重错误: 从回收站恢复 %s 失败: %v", items[i].OriginalPath, err)
+		}
+	}
+}
+
+// moveFiles 把图片文件移动到 dir 中，同名时自动追加序号。
+// 返回已完成的移动 (用于回滚) 以及旧路径到新路径的改写函数。
+func moveFiles(images []models.Image, dir string) (*mover, func(string) string, error) {
+	mv := &mover{}
+	newPaths := make(map[string]string, len(images))
+	for _, img := range images {
+		to := fsutil.AvailablePath(fsutil.OS, filepath.Join(dir, filepath.Base(img.FilePath)))
+		if err := mv.renameWithSidecar(img.FilePath, to); err != nil {
+			mv.rollback()
+			return nil, nil, fmt.Errorf("移动 %s 失败，已撤销: %w", img.FileName, err)
+		}
+		newPaths[img.FilePath] = to
+	}
+	rewrite := func(path string) string {
+		if to, ok := newPaths[path]; ok {
+			return to
+		}
+		return path
+	}
+	return mv, rewrite, nil
+}
+
+// sourceSeries 返回图片所属的系列 ID (去重)。
+func sourceSeries(images []models.Image) []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]struct{})
+	ids := []primitive.ObjectID{}
+	for _, img := range images {
+		if _, ok := seen[img.SeriesID]; !ok {
+			seen[img.SeriesID] = struct{}{}
+			ids = append(ids, img.SeriesID)
+		}
+	}
+	return ids
+}
+
+// refreshSeries 重新计算多个系列的元数据，失败只记录日志。
+func (e *fsEditor) refreshSeries(ctx context.Context, ids []primitive.ObjectID) {
+	for _, id := range ids {
+		if _, err := e.refreshMetadata(ctx, &models.Series{ID: id}); err != nil {
+			log.Printf("警告: 更新系列 %s 的元数据失败: %v", id.Hex(), err)
+		}
+	}
+}
+
+func (e *fsEditor) loadSeries(ctx context.Context, id primitive.ObjectID) (*models.Series, error) {
+	series, err := e.store.Series().GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if series == nil {
+		return nil, ErrSeriesNotFound
+	}
+	// 只整理位于最终库内的系列文件夹，防止路径异常的旧记录把文件移出库外
+	info, err := os.Stat(series.Path)
+	if err != nil || !info.IsDir() || !isWithin(series.Path, e.libraryPath) {
+		return nil, fmt.Errorf("%w: 系列 '%s' 的路径 '%s' 不是库内的文件夹，请先重新扫描", ErrInvalidRequest, series.Name, series.Path)
+	}
+	return series, nil
+}
+
+// selectImages 加载选中的图片并确认它们可以单独移动；src 不为 nil 时还要求图片都属于 src。
+func (e *fsEditor) selectImages(ctx context.Context, imageIDs []primitive.ObjectID, src *models.Series) ([]models.Image, error) {
+	images := make([]models.Image, 0, len(imageIDs))
+	seen := make(map[primitive.ObjectID]struct{}, len(imageIDs))
+	for _, id := range imageIDs {
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		img, err := e.store.Images().GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if img == nil {
+			return nil, fmt.Errorf("%w: 图片 %s 不存在", ErrInvalidRequest, id.Hex())
+		}
+		if src != nil && img.SeriesID != src.ID {
+			return nil, fmt.Errorf("%w: 图片 %s 不属于系列 '%s'", ErrInvalidRequest, id.Hex(), src.Name)
+		}
+		if _, _, inArchive := archive.SplitPath(img.FilePath); inArchive {
+			return nil, fmt.Errorf("%w: 图片 %s 位于压缩包内，不能单独移动或删除", ErrInvalidRequest, img.FileName)
+		}
+		if !isWithin(img.FilePath, e.libraryPath) {
+			return nil, fmt.Errorf("%w: 图片 %s 不在库内，请先重新扫描", ErrInvalidRequest, img.FileName)
+		}
+		images = append(images, *img)
+	}
+	return images, nil
+}
+
+// ensureNameFree 确认没有其他系列使用 name。
+func (e *fsEditor) ensureNameFree(ctx context.Context, name string) error {
+	existing, err := e.store.Series().GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: 系列 '%s' 已存在，可以考虑合并", ErrNameTaken, name)
+	}
+	return nil
+}
+
+// seriesDir 计算重命名后的文件夹：位于聚合目录 (_agg) 中的系列留在原聚合目录内，
+// 其余系列按新名称重新计算在当前布局中的归档目录。
+func (e *fsEditor) seriesDir(series *models.Series, newName string) string {
+	parent := filepath.Dir(series.Path)
+	if strings.HasSuffix(filepath.Base(parent), aggSuffix) {
+		return filepath.Join(parent, newName)
+	}
+	info := layout.SeriesInfo{
+		Name:      newName,
+		SortName:  romanize.SortName(newName, series.SortNameOverride),
+		CreatedAt: series.CreatedAt,
+	}
+	if series.Source != nil {
+		info.Author = series.Source.Author
+	}
+	return layout.SeriesDir(e.layout, e.libraryPath, info)
+}
+
+// relocateImages 按 rewrite 改写图片路径，并把它们归入 seriesID。
+func (e *fsEditor) relocateImages(ctx context.Context, images []models.Image, seriesID primitive.ObjectID, rewrite func(string) string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(images))
+	now := time.Now()
+	for _, img := range images {
+		newPath := rewrite(img.FilePath)
+		update := bson.M{"$set": bson.M{
+			"seriesId":  seriesID,
+			"filePath":  newPath,
+			"fileName":  archive.FileName(newPath),
+			"updatedAt": now,
+		}}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": img.ID}).SetUpdate(update))
+	}
+	return e.store.Images().BulkWrite(ctx, writes)
+}
+
+// restoreImages 尽力把图片记录恢复为操作前的状态，用于回滚。
+func (e *fsEditor) restoreImages(ctx context.Context, images []models.Image) {
+	writes := make([]mongo.WriteModel, 0, len(images))
+	for _, img := range images {
+		update := bson.M{"$set": bson.M{"seriesId": img.SeriesID, "filePath": img.FilePath, "fileName": img.FileName}}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": img.ID}).SetUpdate(update))
+	}
+	if len(writes) == 0 {
+		return
+	}
+	if err := e.store.Images().BulkWrite(ctx, writes); err != nil {
+		log.Printf("严重错误: 回滚图片记录失败，磁盘与数据库可能不一致，请重新扫描: %v", err)
+	}
+}
+
+// refreshMetadata 重新计算系列的图片数量和封面。
+func (e *fsEditor) refreshMetadata(ctx context.Context, series *models.Series) (*models.Series, error) {
+	count, err := e.store.Images().CountBySeriesID(ctx, series.ID)
+	if err != nil {
+		return nil, err
+	}
+	var thumbnail string
+	first, err := e.store.Images().GetFirstImage(ctx, series.ID)
+	if err != nil {
+		return nil, err
+	}
+	if first != nil {
+		thumbnail = first.Thumbnail
+	}
+	if err := e.store.Series().UpdateMetadata(ctx, series.ID, int(count), thumbnail); err != nil {
+		return nil, err
+	}
+	return e.store.Series().GetByID(ctx, series.ID)
+}
+
+// removeEmptyParent 在系列被移走后逐级删除变空的聚合目录和归档目录，
+// 但保留布局要求预先存在的固定归档目录 (例如 A-Z)。
+func (e *fsEditor) removeEmptyParent(dir string) {
+	fixed := make(map[string]struct{})
+	for _, b := range e.layout.FixedBuckets() {
+		fixed[filepath.Join(e.libraryPath, b)] = struct{}{}
+	}
+	for parent := filepath.Dir(dir); isWithin(parent, e.libraryPath); parent = filepath.Dir(parent) {
+		if _, ok := fixed[parent]; ok {
+			return
+		}
+		if entries, err := os.ReadDir(parent); err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(parent); err != nil {
+			return
+		}
+	}
+}
+
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>|`) {
+		return "", fmt.Errorf("%w: 系列名称 '%s' 为空或包含非法字符", ErrInvalidRequest, name)
+	}
+	if strings.HasSuffix(name, aggSuffix) {
+		return "", fmt.Errorf("%w: 系列名称不能以 %s 结尾", ErrInvalidRequest, aggSuffix)
+	}
+	return name, nil
+}
+
+// rebase 把 oldDir 下的路径改写到 newDir 下 (同样适用于压缩包条目路径)。
+func rebase(path, oldDir, newDir string) string {
+	if rel, err := filepath.Rel(oldDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join(newDir, rel)
+	}
+	return path
+}
+
+// rebaseEntry 在 path 正是 from，或是 from 目录/压缩包内的条目时，把它改写到 to。
+func rebaseEntry(path, from, to string) (string, bool) {
+	switch {
+	case path == from:
+		return to, true
+	case strings.HasPrefix(path, from+archive.Separator):
+		return to + strings.TrimPrefix(path, from), true
+	case strings.HasPrefix(path, from+string(filepath.Separator)):
+		return to + strings.TrimPrefix(path, from), true
+	}
+	return "", false
+}
+
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != "." && !strings.HasPrefix(rel, "..")
+}
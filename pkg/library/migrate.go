@@ -0,0 +1,234 @@
+package library
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/archive"
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/layout"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MigrationMove 是布局迁移中的一次文件夹移动。
+type MigrationMove struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Series int    `json:"series"` // 随之更新路径的系列数量
+	Error  string `json:"error,omitempty"`
+}
+
+// MigrationReport 是布局迁移的结果。
+type MigrationReport struct {
+	Layout    string          `json:"layout"`
+	DryRun    bool            `json:"dryRun"`
+	Moved     []MigrationMove `json:"moved"`
+	Failed    []MigrationMove `json:"failed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// unit 是迁移时作为整体移动的文件夹：一个系列文件夹或一个聚合目录 (_agg) 及其中的所有系列。
+type unit struct {
+	path   string
+	series []models.Series
+}
+
+// MigrateLayout 把最终库中的所有系列文件夹移动到当前布局对应的位置，并同步更新 series.path 和 images.filePath。
+// 迁移不依赖旧布局：它从根目录向下查找已入库的系列文件夹、聚合目录以及直接包含媒体文件的文件夹，
+// 其余目录都被视为旧布局的归档目录，迁移完成后如果变空就会被删除。
+// dryRun 为 true 时只计算移动计划，不修改磁盘和数据库。
+func (e *fsEditor) MigrateLayout(ctx context.Context, dryRun bool) (*MigrationReport, error) {
+	opMu.Lock()
+	defer opMu.Unlock()
+
+	all, err := e.store.Series().GetAllSeries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("读取系列列表失败: %w", err)
+	}
+	byPath := make(map[string]models.Series, len(all))
+	for _, s := range all {
+		byPath[filepath.Clean(s.Path)] = s
+	}
+	units, err := e.findUnits(e.libraryPath, byPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MigrationReport{Layout: e.layout.Name(), DryRun: dryRun, Moved: []MigrationMove{}, Failed: []MigrationMove{}}
+	for _, u := range units {
+		target := filepath.Join(e.libraryPath, e.layout.Bucket(unitInfo(u)), filepath.Base(u.path))
+		if target == u.path {
+			report.Unchanged++
+			continue
+		}
+		move := MigrationMove{From: u.path, To: target, Series: len(u.series)}
+		if _, err := os.Stat(target); err == nil {
+			move.Error = "目标位置已存在同名文件夹"
+			report.Failed = append(report.Failed, move)
+			continue
+		}
+		if !dryRun {
+			if err := e.moveUnit(ctx, u, target); err != nil {
+				move.Error = err.Error()
+				report.Failed = append(report.Failed, move)
+				continue
+			}
+		}
+		report.Moved = append(report.Moved, move)
+	}
+
+	if !dryRun {
+		for _, b := range e.layout.FixedBuckets() {
+			if err := os.MkdirAll(filepath.Join(e.libraryPath, b), 0755); err != nil {
+				log.Printf("警告: 无法创建归档目录 %s: %v", b, err)
+			}
+		}
+		e.removeEmptyDirs(e.libraryPath)
+	}
+	return report, nil
+}
+
+// findUnits 从 dir 向下查找需要整体移动的文件夹。
+func (e *fsEditor) findUnits(dir string, byPath map[string]models.Series) ([]unit, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录 %s 失败: %w", dir, err)
+	}
+	var units []unit
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasSuffix(entry.Name(), aggSuffix):
+			u := unit{path: path}
+			for p, s := range byPath {
+				if filepath.Dir(p) == path {
+					u.series = append(u.series, s)
+				}
+			}
+			units = append(units, u)
+		case isSeriesDir(path, byPath):
+			u := unit{path: path}
+			if s, ok := byPath[path]; ok {
+				u.series = []models.Series{s}
+			}
+			units = append(units, u)
+		default:
+			sub, err := e.findUnits(path, byPath)
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, sub...)
+		}
+	}
+	return units, nil
+}
+
+// isSeriesDir 判断 path 是否是一个系列文件夹：已入库，或者直接包含媒体文件/压缩包 (尚未入库的系列)。
+func isSeriesDir(path string, byPath map[string]models.Series) bool {
+	if _, ok := byPath[path]; ok {
+		return true
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && (formats.IsMediaExtension(entry.Name()) || archive.IsArchiveExtension(entry.Name())) {
+			return true
+		}
+	}
+	return false
+}
+
+// unitInfo 返回计算归档位置所用的系列信息。
+// 聚合目录按其中最早入库的系列归档，与扫描时聚合目录总是创建在成员所在归档目录中的行为一致。
+func unitInfo(u unit) layout.SeriesInfo {
+	name := filepath.Base(u.path)
+	if len(u.series) == 0 {
+		return layout.SeriesInfo{Name: strings.TrimSuffix(name, aggSuffix)}
+	}
+	members := append([]models.Series(nil), u.series...)
+	sort.Slice(members, func(i, j int) bool { return members[i].CreatedAt.Before(members[j].CreatedAt) })
+	first := members[0]
+	if strings.HasSuffix(name, aggSuffix) {
+		name = first.Name
+	}
+	info := layout.SeriesInfo{Name: name, SortName: first.SortName, CreatedAt: first.CreatedAt}
+	if first.Source != nil {
+		info.Author = first.Source.Author
+	}
+	return info
+}
+
+// moveUnit 移动文件夹并更新其中所有系列和图片的路径，数据库更新失败时撤销移动。
+func (e *fsEditor) moveUnit(ctx context.Context, u unit, target string) error {
+	var mv mover
+	if err := mv.rename(u.path, target); err != nil {
+		return fmt.Errorf("移动文件夹失败: %w", err)
+	}
+	var done []models.Series
+	var images []models.Image
+	rollback := func() {
+		e.restoreImages(ctx, images)
+		for _, s := range done {
+			if err := e.store.Series().Update(ctx, &s); err != nil {
+				log.Printf("严重错误: 回滚系列 %s 的路径失败: %v", s.Name, err)
+			}
+		}
+		mv.rollback()
+	}
+	rewrite := func(path string) string { return rebase(path, u.path, target) }
+	for _, s := range u.series {
+		seriesImages, err := e.store.Images().GetAllBySeriesID(ctx, s.ID)
+		if err != nil {
+			rollback()
+			return err
+		}
+		if err := e.relocateImages(ctx, seriesImages, s.ID, rewrite); err != nil {
+			rollback()
+			return fmt.Errorf("更新图片记录失败，已撤销移动: %w", err)
+		}
+		images = append(images, seriesImages...)
+		moved := s
+		moved.Path = rewrite(s.Path)
+		if err := e.store.Series().Update(ctx, &moved); err != nil {
+			rollback()
+			return fmt.Errorf("更新系列记录失败，已撤销移动: %w", err)
+		}
+		done = append(done, s)
+	}
+	return nil
+}
+
+// removeEmptyDirs 自底向上删除 root 下所有空目录，保留当前布局的固定归档目录和 root 本身。
+func (e *fsEditor) removeEmptyDirs(root string) {
+	fixed := make(map[string]struct{})
+	for _, b := range e.layout.FixedBuckets() {
+		fixed[filepath.Join(e.libraryPath, b)] = struct{}{}
+	}
+	var walk func(dir string) bool
+	walk = func(dir string) bool {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false
+		}
+		empty := true
+		for _, entry := range entries {
+			if !entry.IsDir() || !walk(filepath.Join(dir, entry.Name())) {
+				empty = false
+			}
+		}
+		if _, ok := fixed[dir]; ok || dir == root || !empty {
+			return false
+		}
+		return os.Remove(dir) == nil
+	}
+	walk(root)
+}
@@ -15,7 +15,7 @@ func InitLogger() error {
 
 	// 从配置中获取日志级别
 	logLevel := new(slog.LevelVar)
-	if err := setLogLevel(config.C.Logger.Level, logLevel); err != nil {
+	if err := setLogLevel(config.Current().Logger.Level, logLevel); err != nil {
 		return err
 	}
 
@@ -25,7 +25,7 @@ func InitLogger() error {
 	}
 
 	// 根据配置选择日志格式 (text 或 json)
-	if config.C.Logger.Format == "json" {
+	if config.Current().Logger.Format == "json" {
 		logHandler = slog.NewJSONHandler(os.Stdout, handlerOpts)
 	} else {
 		logHandler = slog.NewTextHandler(os.Stdout, handlerOpts)
@@ -1,9 +1,18 @@
 package maintenance
 
 import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/archive"
+	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/fsutil"
 	"PICs_Manager/pkg/hasher"
+	"PICs_Manager/pkg/imagemeta"
+	"PICs_Manager/pkg/thumbnailer"
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -17,16 +26,18 @@ import (
 type Maintenance interface {
 	GenerateFileManifest(ctx context.Context, libraryPath, outputPath string) error
 	BackupDatabase(ctx context.Context, dbURI, dbName, outputPath string) error
+	RegenerateOrientedThumbnails(ctx context.Context, store database.Store) (int, error)
 }
 
 type defaultMaintenance struct {
+	fs         fsutil.FS
 	logger     *log.Logger
 	logFile    *os.File
 	numWorkers int
 }
 
-// NewMaintenance 创建一个新的维护模块实例
-func NewMaintenance(logDir string, workerCount int) (Maintenance, error) {
+// NewMaintenance 创建一个新的维护模块实例，媒体库和清单文件通过 fsys 访问
+func NewMaintenance(logDir string, fsys fsutil.FS, workerCount int) (Maintenance, error) {
 	logFilePath := filepath.Join(logDir, "maintenance.log")
 	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -37,6 +48,7 @@ func NewMaintenance(logDir string, workerCount int) (Maintenance, error) {
 		workerCount = runtime.NumCPU()
 	}
 	return &defaultMaintenance{
+		fs:         fsys,
 		logger:     logger,
 		logFile:    file,
 		numWorkers: workerCount,
@@ -50,7 +62,7 @@ func (m *defaultMaintenance) GenerateFileManifest(ctx context.Context, libraryPa
 	// 1. 创建输出文件
 	manifestFileName := fmt.Sprintf("manifest_%s.txt", time.Now().Format("2006-01-02"))
 	manifestPath := filepath.Join(outputPath, manifestFileName)
-	file, err := os.Create(manifestPath)
+	file, err := m.fs.Create(manifestPath)
 	if err != nil {
 		return fmt.Errorf("无法创建清单文件: %w", err)
 	}
@@ -74,7 +86,7 @@ func (m *defaultMaintenance) GenerateFileManifest(ctx context.Context, libraryPa
 	go func() {
 		defer writeWg.Done()
 		for line := range results {
-			if _, err := file.WriteString(line); err != nil {
+			if _, err := io.WriteString(file, line); err != nil {
 				m.logger.Printf("错误: 写入清单文件失败: %v", err)
 			}
 		}
@@ -82,7 +94,7 @@ func (m *defaultMaintenance) GenerateFileManifest(ctx context.Context, libraryPa
 
 	// 3. 分发任务
 	m.logger.Println("开始扫描文件并分发任务...")
-	err = filepath.WalkDir(libraryPath, func(path string, d os.DirEntry, err error) error {
+	err = fsutil.WalkDir(m.fs, libraryPath, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -108,7 +120,7 @@ func (m *defaultMaintenance) GenerateFileManifest(ctx context.Context, libraryPa
 func (m *defaultMaintenance) manifestWorker(wg *sync.WaitGroup, tasks <-chan string, results chan<- string) {
 	defer wg.Done()
 	for path := range tasks {
-		hash, err := hasher.CalculateSHA256(path)
+		hash, err := hasher.CalculateSHA256FS(m.fs, path)
 		if err != nil {
 			m.logger.Printf("警告: 计算文件 %s 的哈希失败: %v", path, err)
 			continue
@@ -155,3 +167,96 @@ func (m *defaultMaintenance) BackupDatabase(ctx context.Context, dbURI, dbName,
 	m.logger.Println("--- 数据库备份成功 ---")
 	return nil
 }
+
+// RegenerateOrientedThumbnails 修复在读取 EXIF 之前入库的图片 (主要是手机照片)：
+// 对数据库中还没有 EXIF 记录的图片重新从文件读取 EXIF 并记录下来，方向不是 1 的图片重新生成缩略图并校正存储的宽高。
+// 记录过 EXIF 的图片下次不再检查；本身不含 EXIF 的图片每次都会重新读取。返回重新生成缩略图的图片数量。
+func (m *defaultMaintenance) RegenerateOrientedThumbnails(ctx context.Context, store database.Store) (int, error) {
+	m.logger.Println("--- 开始重新生成需要方向校正的缩略图 ---")
+
+	images, err := store.Images().FindWithoutEXIF(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("查询需要检查的图片失败: %w", err)
+	}
+	m.logger.Printf("找到 %d 张没有 EXIF 记录的图片，重新从文件读取。", len(images))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	updated := 0
+	affectedSeries := make(map[string]models.Image)
+	tasks := make(chan models.Image, m.numWorkers)
+
+	for i := 0; i < m.numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for img := range tasks {
+				regenerated, err := m.regenerateThumbnail(ctx, store, img)
+				if err != nil {
+					m.logger.Printf("警告: 重新生成 %s 的缩略图失败: %v", img.FilePath, err)
+					continue
+				}
+				if !regenerated {
+					continue
+				}
+				mu.Lock()
+				updated++
+				affectedSeries[img.SeriesID.Hex()] = img
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, img := range images {
+		tasks <- img
+	}
+	close(tasks)
+	wg.Wait()
+
+	// 系列封面取自第一张图片的缩略图，需要同步刷新
+	for _, img := range affectedSeries {
+		first, err := store.Images().GetFirstImage(ctx, img.SeriesID)
+		if err != nil || first == nil {
+			continue
+		}
+		count, err := store.Images().CountBySeriesID(ctx, img.SeriesID)
+		if err != nil {
+			m.logger.Printf("警告: 无法统计系列 %s 的图片数量: %v", img.SeriesID.Hex(), err)
+			continue
+		}
+		if err := store.Series().UpdateMetadata(ctx, img.SeriesID, int(count), first.Thumbnail); err != nil {
+			m.logger.Printf("警告: 更新系列 %s 的封面失败: %v", img.SeriesID.Hex(), err)
+		}
+	}
+
+	m.logger.Printf("--- 缩略图方向校正完毕，共更新 %d 张图片，涉及 %d 个系列 ---", updated, len(affectedSeries))
+	return updated, nil
+}
+
+// regenerateThumbnail 重新读取 img 的 EXIF，方向需要校正时重新生成缩略图，然后记录 EXIF。
+// 返回是否重新生成了缩略图；文件不含 EXIF 时什么都不做。
+func (m *defaultMaintenance) regenerateThumbnail(ctx context.Context, store database.Store, img models.Image) (bool, error) {
+	data, err := archive.ReadFileFS(m.fs, img.FilePath)
+	if err != nil {
+		return false, err
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	meta := imagemeta.Extract(data, decoded, format)
+	if meta.EXIF == nil {
+		return false, nil
+	}
+	regenerated := meta.Orientation() > 1
+	if regenerated {
+		thumbnail, err := thumbnailer.CreateBase64(thumbnailer.ApplyOrientation(decoded, meta.Orientation()), 200, 200)
+		if err != nil {
+			return false, err
+		}
+		if err := store.Images().UpdateThumbnail(ctx, img.ID, thumbnail, meta.Width, meta.Height); err != nil {
+			return false, err
+		}
+	}
+	// 缩略图更新后才记录 EXIF，中途失败时下次还会重新检查这张图片
+	return regenerated, store.Images().SetEXIF(ctx, img.ID, meta.EXIF.Model())
+}
@@ -0,0 +1,185 @@
+// Package quarantine 负责把有问题的文件移入隔离区，以及从隔离区恢复或清除它们。
+// 文件永远不会被流水线直接删除：每个被隔离的文件都会在 <隔离区>/<原因>/ 下保留一份，
+// 旁边附带一个描述错误、哈希和原始位置的 .json sidecar，并在 "quarantine" 集合中留有记录。
+// 清除和丢弃的文件同样不会被直接删除，而是移入回收站。
+package quarantine
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/sidecar"
+	"PICs_Manager/pkg/trash"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sidecarSuffix 是隔离文件旁边描述文件的后缀。
+const sidecarSuffix = ".json"
+
+// sourceSuffix 是随文件一起隔离的下载器元数据文件在隔离区中的后缀，与描述文件区分开。
+const sourceSuffix = ".source" + sidecar.Ext
+
+var (
+	// ErrNotFound 表示隔离记录不存在。
+	ErrNotFound = errors.New("隔离记录不存在")
+	// ErrRestoreConflict 表示原始位置已被其他文件占用，无法恢复。
+	ErrRestoreConflict = fsutil.ErrExist
+)
+
+// Manager 定义了隔离区的操作。
+type Manager interface {
+	// Get 返回一条隔离记录，不存在时返回 ErrNotFound。
+	Get(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error)
+	// Isolate 把 item.OriginalPath 指向的文件移入隔离区，写入 sidecar 并保存记录。
+	// 调用方只需填写 Kind、OriginalPath、Error 以及已知的哈希、系列等信息。
+	Isolate(ctx context.Context, item *models.QuarantineItem) error
+	// Restore 把文件移回原始位置并删除记录。
+	Restore(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error)
+	// Purge 把隔离文件移入回收站，并删除 sidecar 和记录。
+	Purge(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error)
+	// Compare 对比冲突文件夹与其冲突目标的文件列表和哈希，仅适用于 collision 类型。
+	Compare(ctx context.Context, id primitive.ObjectID) (*Comparison, error)
+	// Resolve 按指定方式解决冲突，返回需要重新入库的库内文件夹。
+	Resolve(ctx context.Context, id primitive.ObjectID, req ResolveRequest) (*Resolution, error)
+}
+
+type fsManager struct {
+	fs    fsutil.FS
+	root  string
+	bin   trash.Manager
+	store database.QuarantineStore
+}
+
+// NewManager 创建一个以 fsys 中的 root 为隔离区根目录的 Manager，被隔离的文件同样通过 fsys 访问，
+// 清除和丢弃的文件移入 bin。bin 为 nil 时清除和丢弃返回 trash.ErrNotConfigured，其余操作不受影响。
+func NewManager(fsys fsutil.FS, root string, bin trash.Manager, store database.QuarantineStore) (Manager, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取隔离区路径的绝对路径 '%s': %w", root, err)
+	}
+	return &fsManager{fs: fsys, root: absRoot, bin: bin, store: store}, nil
+}
+
+func (m *fsManager) Isolate(ctx context.Context, item *models.QuarantineItem) error {
+	info, err := m.fs.Stat(item.OriginalPath)
+	if err != nil {
+		return fmt.Errorf("无法读取待隔离文件: %w", err)
+	}
+
+	item.ID = primitive.NewObjectID()
+	item.CreatedAt = time.Now()
+	item.FileName = filepath.Base(item.OriginalPath)
+	item.IsDir = info.IsDir()
+	item.FileSize = info.Size()
+	if item.IsDir {
+		item.FileSize = fsutil.DirSize(m.fs, item.OriginalPath)
+	}
+
+	dir := filepath.Join(m.root, string(item.Kind))
+	if err := m.fs.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("无法创建隔离目录 %s: %w", dir, err)
+	}
+	// 以记录 ID 为前缀，避免不同系列中的同名文件互相覆盖
+	item.QuarantinedPath = filepath.Join(dir, item.ID.Hex()+"_"+item.FileName)
+
+	// 下载器的元数据文件随文件一起隔离，恢复后入库时仍能读到来源信息
+	var sc string
+	if !item.IsDir {
+		sc = sidecar.PathFS(m.fs, item.OriginalPath)
+	}
+	if err := m.fs.Rename(item.OriginalPath, item.QuarantinedPath); err != nil {
+		return fmt.Errorf("移动文件到隔离区失败: %w", err)
+	}
+	if sc != "" {
+		if err := m.fs.Rename(sc, item.QuarantinedPath+sourceSuffix); err != nil {
+			log.Printf("警告: 移动元数据文件 %s 到隔离区失败: %v", sc, err)
+		} else {
+			item.SourceSidecar = sc
+		}
+	}
+	if err := m.writeSidecar(item); err != nil {
+		// sidecar 只是冗余信息，写入失败不影响隔离本身
+		log.Printf("警告: 写入隔离 sidecar 失败: %v", err)
+	}
+	if err := m.store.Create(ctx, item); err != nil {
+		return fmt.Errorf("文件已移入隔离区 (%s)，但保存记录失败: %w", item.QuarantinedPath, err)
+	}
+	return nil
+}
+
+func (m *fsManager) Restore(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	item, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsutil.MoveBack(m.fs, item.QuarantinedPath, item.OriginalPath); err != nil {
+		return nil, err
+	}
+	if item.SourceSidecar != "" {
+		if err := fsutil.MoveBack(m.fs, item.QuarantinedPath+sourceSuffix, item.SourceSidecar); err != nil {
+			log.Printf("警告: 元数据文件 %s 保留在隔离区中: %v", item.SourceSidecar, err)
+		}
+	}
+	if err := m.remove(ctx, item); err != nil {
+		return nil, fmt.Errorf("文件已恢复，但删除隔离记录失败: %w", err)
+	}
+	return item, nil
+}
+
+func (m *fsManager) Purge(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	item, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.discard(ctx, item.QuarantinedPath, "清除隔离文件: "+item.Error); err != nil {
+		return nil, fmt.Errorf("清除隔离文件失败: %w", err)
+	}
+	if item.SourceSidecar != "" {
+		if err := m.discard(ctx, item.QuarantinedPath+sourceSuffix, "清除隔离文件的元数据: "+item.Error); err != nil {
+			log.Printf("警告: 清除隔离文件的元数据失败: %v", err)
+		}
+	}
+	if err := m.remove(ctx, item); err != nil {
+		return nil, fmt.Errorf("删除隔离记录失败: %w", err)
+	}
+	return item, nil
+}
+
+// discard 把隔离区中的 path 移入回收站。
+func (m *fsManager) discard(ctx context.Context, path, reason string) error {
+	if m.bin == nil {
+		return trash.ErrNotConfigured
+	}
+	_, err := m.bin.Discard(ctx, path, trash.SourceQuarantine, reason)
+	return err
+}
+
+func (m *fsManager) Get(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	return m.load(ctx, id)
+}
+
+// remove 删除隔离记录及其 sidecar (隔离文件本身由调用方处理)。
+func (m *fsManager) remove(ctx context.Context, item *models.QuarantineItem) error {
+	m.fs.Remove(item.QuarantinedPath + sidecarSuffix)
+	return m.store.Delete(ctx, item.ID)
+}
+
+func (m *fsManager) load(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	return database.GetExisting(ctx, m.store.GetByID, id, ErrNotFound)
+}
+
+func (m *fsManager) writeSidecar(item *models.QuarantineItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(m.fs, item.QuarantinedPath+sidecarSuffix, data)
+}
@@ -0,0 +1,288 @@
+package quarantine
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/hasher"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 冲突的解决方式，用于 ResolveRequest.Action。
+const (
+	// ActionMerge 把隔离文件夹中目标没有的文件并入目标，内容重复的文件被丢弃。
+	ActionMerge = "merge"
+	// ActionReplace 用隔离文件夹替换目标，原目标文件夹反过来被隔离，而不是被删除。
+	ActionReplace = "replace"
+	// ActionRename 以新名字把隔离文件夹放到目标旁边，成为一个独立的系列。
+	ActionRename = "rename"
+	// ActionDiscard 永久删除隔离文件夹。
+	ActionDiscard = "discard"
+)
+
+var (
+	// ErrNotCollision 表示该隔离记录不是文件夹冲突，不能进行对比或解决。
+	ErrNotCollision = errors.New("该隔离记录不是文件夹冲突")
+	// ErrInvalidResolution 表示解决方式或其参数无效。
+	ErrInvalidResolution = errors.New("无效的冲突解决方式")
+)
+
+// FileEntry 是对比结果中的一个文件。
+type FileEntry struct {
+	Path string `json:"path"` // 相对于系列文件夹的路径
+	Size int64  `json:"size"`
+	Hash string `json:"hash"`
+}
+
+// Comparison 是隔离文件夹与其冲突目标的对比结果。
+type Comparison struct {
+	Item         *models.QuarantineItem `json:"item"`
+	TargetExists bool                   `json:"targetExists"`
+	Quarantined  []FileEntry            `json:"quarantined"`
+	Target       []FileEntry            `json:"target"`
+
+	// Identical 是两边路径相同且内容相同的文件。
+	Identical []string `json:"identical"`
+	// Conflicting 是两边路径相同但内容不同的文件。
+	Conflicting []string `json:"conflicting"`
+	// Duplicates 是只在隔离文件夹中出现、但内容已以其他名字存在于目标中的文件。
+	Duplicates []string `json:"duplicates"`
+	// OnlyInQuarantined 是隔离文件夹独有的新文件。
+	OnlyInQuarantined []string `json:"onlyInQuarantined"`
+	// OnlyInTarget 是目标文件夹独有的文件。
+	OnlyInTarget []string `json:"onlyInTarget"`
+}
+
+// ResolveRequest 描述如何解决一次冲突。
+type ResolveRequest struct {
+	Action  string `json:"action"`
+	NewName string `json:"newName,omitempty"` // 仅 rename 需要
+}
+
+// Resolution 是冲突解决的结果。
+type Resolution struct {
+	Action string `json:"action"`
+	// AffectedPaths 是内容发生变化、需要重新入库的库内系列文件夹。
+	AffectedPaths []string `json:"affectedPaths"`
+	// Merged / Skipped 仅 merge 时有值，分别是被并入和因重复而被丢弃的文件。
+	Merged  []string `json:"merged,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+	// Displaced 仅 replace 时有值，是被替换下来、重新进入隔离区的原目标文件夹。
+	Displaced *models.QuarantineItem `json:"displaced,omitempty"`
+}
+
+func (m *fsManager) Compare(ctx context.Context, id primitive.ObjectID) (*Comparison, error) {
+	item, err := m.loadCollision(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	quarantined, err := m.listFiles(item.QuarantinedPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取隔离文件夹失败: %w", err)
+	}
+	cmp := &Comparison{
+		Item:              item,
+		Quarantined:       quarantined,
+		Target:            []FileEntry{},
+		Identical:         []string{},
+		Conflicting:       []string{},
+		Duplicates:        []string{},
+		OnlyInQuarantined: []string{},
+		OnlyInTarget:      []string{},
+	}
+	if fsutil.Exists(m.fs, item.TargetPath) {
+		cmp.TargetExists = true
+		if cmp.Target, err = m.listFiles(item.TargetPath); err != nil {
+			return nil, fmt.Errorf("读取目标文件夹失败: %w", err)
+		}
+	}
+
+	targetByPath := make(map[string]FileEntry, len(cmp.Target))
+	targetHashes := make(map[string]struct{}, len(cmp.Target))
+	for _, f := range cmp.Target {
+		targetByPath[f.Path] = f
+		targetHashes[f.Hash] = struct{}{}
+	}
+	seen := make(map[string]struct{}, len(quarantined))
+	for _, f := range quarantined {
+		seen[f.Path] = struct{}{}
+		t, samePath := targetByPath[f.Path]
+		_, sameContent := targetHashes[f.Hash]
+		switch {
+		case samePath && t.Hash == f.Hash:
+			cmp.Identical = append(cmp.Identical, f.Path)
+		case samePath:
+			cmp.Conflicting = append(cmp.Conflicting, f.Path)
+		case sameContent:
+			cmp.Duplicates = append(cmp.Duplicates, f.Path)
+		default:
+			cmp.OnlyInQuarantined = append(cmp.OnlyInQuarantined, f.Path)
+		}
+	}
+	for _, f := range cmp.Target {
+		if _, ok := seen[f.Path]; !ok {
+			cmp.OnlyInTarget = append(cmp.OnlyInTarget, f.Path)
+		}
+	}
+	return cmp, nil
+}
+
+func (m *fsManager) Resolve(ctx context.Context, id primitive.ObjectID, req ResolveRequest) (*Resolution, error) {
+	item, err := m.loadCollision(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := &Resolution{Action: req.Action, AffectedPaths: []string{}}
+
+	switch req.Action {
+	case ActionMerge:
+		if err := m.merge(ctx, item, res); err != nil {
+			return nil, err
+		}
+	case ActionReplace:
+		if err := m.replace(ctx, item, res); err != nil {
+			return nil, err
+		}
+	case ActionRename:
+		if err := m.rename(item, req.NewName, res); err != nil {
+			return nil, err
+		}
+	case ActionDiscard:
+		if err := m.discard(ctx, item.QuarantinedPath, "丢弃与 "+item.TargetPath+" 冲突的文件夹"); err != nil {
+			return nil, fmt.Errorf("丢弃隔离文件夹失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: '%s'，可选 merge, replace, rename, discard", ErrInvalidResolution, req.Action)
+	}
+
+	if err := m.remove(ctx, item); err != nil {
+		return nil, fmt.Errorf("冲突已解决，但删除隔离记录失败: %w", err)
+	}
+	return res, nil
+}
+
+// merge 逐个移动隔离文件夹中的文件：内容已存在于目标中的文件被丢弃，
+// 路径相同但内容不同的文件以 "name (n).ext" 的形式并存，最后把剩下的隔离文件夹 (只含重复的文件) 移入回收站。
+func (m *fsManager) merge(ctx context.Context, item *models.QuarantineItem, res *Resolution) error {
+	if err := m.fs.MkdirAll(item.TargetPath, 0755); err != nil {
+		return fmt.Errorf("无法创建目标文件夹: %w", err)
+	}
+	target, err := m.listFiles(item.TargetPath)
+	if err != nil {
+		return fmt.Errorf("读取目标文件夹失败: %w", err)
+	}
+	quarantined, err := m.listFiles(item.QuarantinedPath)
+	if err != nil {
+		return fmt.Errorf("读取隔离文件夹失败: %w", err)
+	}
+
+	hashes := make(map[string]struct{}, len(target)+len(quarantined))
+	for _, f := range target {
+		hashes[f.Hash] = struct{}{}
+	}
+	for _, f := range quarantined {
+		if _, dup := hashes[f.Hash]; dup {
+			res.Skipped = append(res.Skipped, f.Path)
+			continue
+		}
+		dest := fsutil.AvailablePath(m.fs, filepath.Join(item.TargetPath, f.Path))
+		if err := m.fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("无法创建目录: %w", err)
+		}
+		if err := m.fs.Rename(filepath.Join(item.QuarantinedPath, f.Path), dest); err != nil {
+			return fmt.Errorf("合并文件 %s 失败: %w", f.Path, err)
+		}
+		hashes[f.Hash] = struct{}{}
+		rel, _ := filepath.Rel(item.TargetPath, dest)
+		res.Merged = append(res.Merged, filepath.ToSlash(rel))
+	}
+	if err := m.discard(ctx, item.QuarantinedPath, "合并到 "+item.TargetPath+" 后剩下的重复文件"); err != nil {
+		return fmt.Errorf("清理隔离文件夹失败: %w", err)
+	}
+	res.AffectedPaths = append(res.AffectedPaths, item.TargetPath)
+	return nil
+}
+
+// replace 先把原目标隔离起来，再把隔离文件夹移到目标位置，因此任何一方都不会被直接删除。
+func (m *fsManager) replace(ctx context.Context, item *models.QuarantineItem, res *Resolution) error {
+	if fsutil.Exists(m.fs, item.TargetPath) {
+		displaced := &models.QuarantineItem{
+			Kind:         models.QuarantineCollision,
+			OriginalPath: item.TargetPath,
+			TargetPath:   item.TargetPath,
+			Error:        fmt.Sprintf("被隔离记录 %s 替换", item.ID.Hex()),
+		}
+		if err := m.Isolate(ctx, displaced); err != nil {
+			return fmt.Errorf("隔离原目标文件夹失败: %w", err)
+		}
+		res.Displaced = displaced
+	}
+	if err := m.fs.MkdirAll(filepath.Dir(item.TargetPath), 0755); err != nil {
+		return fmt.Errorf("无法创建目录: %w", err)
+	}
+	if err := m.fs.Rename(item.QuarantinedPath, item.TargetPath); err != nil {
+		return fmt.Errorf("移动隔离文件夹到目标位置失败: %w", err)
+	}
+	res.AffectedPaths = append(res.AffectedPaths, item.TargetPath)
+	return nil
+}
+
+func (m *fsManager) rename(item *models.QuarantineItem, newName string, res *Resolution) error {
+	newName = strings.TrimSpace(newName)
+	if newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, `/\`) {
+		return fmt.Errorf("%w: rename 需要一个不含路径分隔符的 newName", ErrInvalidResolution)
+	}
+	dest := filepath.Join(filepath.Dir(item.TargetPath), newName)
+	if fsutil.Exists(m.fs, dest) {
+		return fmt.Errorf("%w: %s", ErrRestoreConflict, dest)
+	}
+	if err := m.fs.Rename(item.QuarantinedPath, dest); err != nil {
+		return fmt.Errorf("重命名隔离文件夹失败: %w", err)
+	}
+	res.AffectedPaths = append(res.AffectedPaths, dest)
+	return nil
+}
+
+func (m *fsManager) loadCollision(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	item, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Kind != models.QuarantineCollision || !item.IsDir || item.TargetPath == "" {
+		return nil, ErrNotCollision
+	}
+	return item, nil
+}
+
+// listFiles 递归列出文件夹中的所有文件及其 SHA256，按相对路径排序。
+func (m *fsManager) listFiles(root string) ([]FileEntry, error) {
+	files := []FileEntry{}
+	err := fsutil.WalkDir(m.fs, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := hasher.CalculateSHA256FS(m.fs, path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, FileEntry{Path: filepath.ToSlash(rel), Size: info.Size(), Hash: hash})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
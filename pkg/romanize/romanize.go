@@ -0,0 +1,167 @@
+// Package romanize 把系列名称转换为拉丁字母，用于归档目录和排序。
+// 汉字使用拼音 (不带声调)，平假名和片假名使用平文式罗马字 (Hepburn)，其余字符交给 unidecode。
+// 日文汉字与中文汉字无法区分，同样按拼音处理；读音不准确的系列可以在数据库中设置 sortNameOverride。
+package romanize
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"github.com/mozillazg/go-unidecode"
+)
+
+var pinyinArgs = pinyin.NewArgs()
+
+// Romanize 返回 s 的拉丁字母形式。相邻的汉字或假名之间以空格分隔，其余字符保持原有间隔。
+func Romanize(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		afterWord := i > 0 && (unicode.Is(unicode.Han, runes[i-1]) || isKana(runes[i-1]) || runes[i-1] == 'ー')
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				writeWord(&b, py[0])
+			} else {
+				writeWord(&b, strings.TrimSpace(unidecode.Unidecode(string(r))))
+			}
+			i++
+		case isKana(r):
+			j := i
+			for j < len(runes) && (isKana(runes[j]) || runes[j] == 'ー') {
+				j++
+			}
+			writeWord(&b, kanaToHepburn(runes[i:j]))
+			i = j
+		default:
+			if afterWord && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+				b.WriteByte(' ')
+			}
+			b.WriteString(unidecode.Unidecode(string(r)))
+			i++
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// SortName 返回系列的排序名：设置了 override 时为它的小写形式，否则为名称罗马字化后的小写形式。
+// 两者都转为小写，手动覆盖的系列才能与其他系列按同一规则排序；原始写法保留在 SortNameOverride 中。
+func SortName(name, override string) string {
+	if override = strings.TrimSpace(override); override != "" {
+		return strings.ToLower(override)
+	}
+	return strings.ToLower(Romanize(name))
+}
+
+// writeWord 写入一个罗马字单词，紧跟在字母或数字后面时用空格隔开。
+func writeWord(b *strings.Builder, word string) {
+	if word == "" {
+		return
+	}
+	if s := b.String(); s != "" {
+		if last := rune(s[len(s)-1]); unicode.IsLetter(last) || unicode.IsNumber(last) {
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(word)
+}
+
+func isKana(r rune) bool {
+	return unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
+
+// kanaToHepburn 把一段连续的假名转换为平文式罗马字，处理拗音、促音 (っ) 和长音符 (ー)。
+func kanaToHepburn(kana []rune) string {
+	var b strings.Builder
+	geminate := false
+	for i := 0; i < len(kana); i++ {
+		r := toHiragana(kana[i])
+		switch r {
+		case 'っ':
+			geminate = true
+			continue
+		case 'ー':
+			// 长音符重复前一个元音
+			if s := b.String(); s != "" {
+				b.WriteByte(s[len(s)-1])
+			}
+			continue
+		}
+
+		syllable, ok := "", false
+		if i+1 < len(kana) {
+			syllable, ok = yoon[string([]rune{r, toHiragana(kana[i+1])})]
+			if ok {
+				i++
+			}
+		}
+		if !ok {
+			if syllable, ok = gojuon[r]; !ok {
+				syllable = strings.TrimSpace(unidecode.Unidecode(string(kana[i])))
+			}
+		}
+		if geminate && syllable != "" {
+			if strings.HasPrefix(syllable, "ch") {
+				b.WriteByte('t')
+			} else {
+				b.WriteByte(syllable[0])
+			}
+			geminate = false
+		}
+		// ん 统一写作 n，不区分 b/m/p 前的 m 和元音前的撇号，排序时更稳定
+		b.WriteString(syllable)
+	}
+	return b.String()
+}
+
+// toHiragana 把片假名转换为对应的平假名，其余字符原样返回。
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - 0x60
+	}
+	return r
+}
+
+var gojuon = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa", 'ゔ': "vu",
+	'ゕ': "ka", 'ゖ': "ke",
+}
+
+// yoon 是拗音以及片假名外来语中常见的组合。
+var yoon = map[string]string{
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+	"つぁ": "tsa", "つぃ": "tsi", "つぇ": "tse", "つぉ": "tso",
+}
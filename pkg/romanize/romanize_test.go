@@ -0,0 +1,45 @@
+package romanize
+
+import "testing"
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"ひらがな", "hiragana"},
+		{"カタカナ", "katakana"},
+		{"がっこう", "gakkou"},    // 促音重复下一个辅音
+		{"きゃりー", "kyarii"},    // 拗音和长音符
+		{"ラーメン", "raamen"},    // 片假名长音符
+		{"ヴァイオリン", "vaiorin"}, // 外来语组合
+		{"チョコ2", "choko 2"},   // 假名后的数字另起一词
+		{"東方 Project", "dong fang Project"},
+		{"[Artist] 作品", "[Artist] zuo pin"},
+		{"Zoë", "Zoe"},
+		{"  Foo  Bar ", "Foo Bar"},
+	}
+	for _, tt := range tests {
+		if got := Romanize(tt.in); got != tt.want {
+			t.Errorf("Romanize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSortName(t *testing.T) {
+	tests := []struct {
+		name, override string
+		want           string
+	}{
+		{"東方 Project", "", "dong fang project"},
+		{"Zoë", "", "zoe"},
+		{"東方", "  Touhou ", "touhou"},
+		{"東方", "   ", "dong fang"},
+	}
+	for _, tt := range tests {
+		if got := SortName(tt.name, tt.override); got != tt.want {
+			t.Errorf("SortName(%q, %q) = %q, want %q", tt.name, tt.override, got, tt.want)
+		}
+	}
+}
@@ -2,24 +2,27 @@ package scanner
 
 import (
 	"PICs_Manager/config"
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/layout"
+	"PICs_Manager/pkg/quarantine"
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
-	"unicode"
-
-	"github.com/mozillazg/go-unidecode"
 )
 
 const (
 	aggregatorLogFileName = "aggregator.log"
 	aggSuffix             = "_agg"
-	archiveChars          = "ABCDEFGHIJKLMNOPQRSTUVWXYZ#"
 )
 
 type compiledRule struct {
@@ -28,16 +31,26 @@ type compiledRule struct {
 }
 type LibraryAggregator interface {
 	AggregateAndArchive(stagingPath, finalLibraryPath string) (map[string]string, error)
+	// LocateSeries 在最终库中查找名为 seriesNames 的系列文件夹，以 changelog 的形式 (路径 -> 自身) 返回。
+	// 用于聚合归档中途中断后继续：已经移出中转站的系列不会出现在新的 changelog 中。
+	LocateSeries(finalLibraryPath string, seriesNames []string) map[string]string
 	Close()
 }
 type configBasedAggregator struct {
+	fs               fsutil.FS
 	seriesGroupRules []compiledRule
+	layout           layout.Strategy
+	quarantine       quarantine.Manager
+	series           database.SeriesStore // 用于查找同名的已入库系列
 	numWorkers       int
 	logger           *log.Logger
 	logFile          *os.File
 }
 
-func NewAggregator(logDir string, rules []config.SeriesGroupRule, workerCount int) (LibraryAggregator, error) {
+// NewAggregator 创建聚合器。系列文件夹按 libraryLayout 归档到最终库中；
+// 归档或聚合时与库中已有文件夹冲突的系列文件夹会通过 quarantineManager 隔离，之后可以在隔离区中对比并解决冲突。
+// 中转站和最终库都通过 fsys 访问。seriesStore 用于让与已入库系列同名的文件夹沿用该系列的归档位置。
+func NewAggregator(logDir string, fsys fsutil.FS, rules []config.SeriesGroupRule, libraryLayout layout.Strategy, quarantineManager quarantine.Manager, seriesStore database.SeriesStore, workerCount int) (LibraryAggregator, error) {
 	logFilePath := filepath.Join(logDir, aggregatorLogFileName)
 	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -47,17 +60,13 @@ func NewAggregator(logDir string, rules []config.SeriesGroupRule, workerCount in
 	if workerCount <= 0 {
 		workerCount = runtime.NumCPU()
 	}
-	compiledRules := make([]compiledRule, 0, len(rules))
-	for _, rule := range rules {
-		re, err := regexp.Compile(rule.Pattern)
-		if err != nil {
-			file.Close()
-			return nil, fmt.Errorf("无效的系列分组模式 '%s': %w", rule.Name, err)
-		}
-		compiledRules = append(compiledRules, compiledRule{Name: rule.Name, Re: re})
+	compiledRules, err := compileGroupRules(rules)
+	if err != nil {
+		file.Close()
+		return nil, err
 	}
 	return &configBasedAggregator{
-		seriesGroupRules: compiledRules, numWorkers: workerCount, logger: logger, logFile: file,
+		fs: fsys, seriesGroupRules: compiledRules, layout: libraryLayout, quarantine: quarantineManager, series: seriesStore, numWorkers: workerCount, logger: logger, logFile: file,
 	}, nil
 }
 
@@ -81,7 +90,7 @@ func (a *configBasedAggregator) AggregateAndArchive(stagingPath, finalLibraryPat
 		return nil, err
 	}
 
-	groupMoved, groupUnMoved, err := a.phase3_aggregateWithinArchiveFolders(finalLibraryPath, config.C.Scanner.QuarantinePath)
+	groupMoved, groupUnMoved, err := a.phase3_aggregateWithinArchiveFolders(finalLibraryPath)
 	if err != nil {
 		return nil, err
 	}
@@ -117,19 +126,19 @@ func (a *configBasedAggregator) AggregateAndArchive(stagingPath, finalLibraryPat
 func (a *configBasedAggregator) phase1_checkAndPrepareStructure(finalLibraryPath string) error {
 	a.logger.Println("--- 阶段 1/4: 检查并准备最终库结构 ---")
 	// 确保最终库的根目录存在
-	if err := os.MkdirAll(finalLibraryPath, 0755); err != nil {
+	if err := a.fs.MkdirAll(finalLibraryPath, 0755); err != nil {
 		return err
 	}
-	expectedDirs := make(map[string]bool)
-	for _, r := range archiveChars {
-		expectedDirs[string(r)] = false
+	buckets := a.layout.FixedBuckets()
+	if buckets == nil {
+		// 没有固定归档目录的布局 (按作者、日期或平铺) 不限制顶层目录
+		return nil
 	}
-	if _, err := os.Stat(finalLibraryPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(finalLibraryPath, 0755); err != nil {
-			return err
-		}
+	expectedDirs := make(map[string]bool)
+	for _, b := range buckets {
+		expectedDirs[b] = false
 	}
-	entries, err := os.ReadDir(finalLibraryPath)
+	entries, err := a.fs.ReadDir(finalLibraryPath)
 	if err != nil {
 		return fmt.Errorf("无法读取最终库目录: %w", err)
 	}
@@ -141,14 +150,13 @@ func (a *configBasedAggregator) phase1_checkAndPrepareStructure(finalLibraryPath
 			}
 			expectedDirs[name] = true
 		} else if !strings.HasPrefix(name, ".") && !strings.EqualFold(name, "Thumbs.db") {
-			return fmt.Errorf("库结构不健康：顶层目录包含了非法的文件夹 '%s'", name)
+			return fmt.Errorf("库结构不健康：顶层目录包含了 %s 布局不允许的文件夹 '%s'，如果刚切换了布局请先执行迁移", a.layout.Name(), name)
 		}
 	}
 	// 预先创建所有归档分类目录
-	for _, char := range archiveChars {
-		// 【核心修复】使用标准的 if err != nil 错误处理
-		if err := os.MkdirAll(filepath.Join(finalLibraryPath, string(char)), 0755); err != nil {
-			a.logger.Printf("警告：无法创建归档目录 %s: %v", string(char), err)
+	for _, b := range buckets {
+		if err := a.fs.MkdirAll(filepath.Join(finalLibraryPath, b), 0755); err != nil {
+			a.logger.Printf("警告：无法创建归档目录 %s: %v", b, err)
 			return err // 如果无法创建基础目录，则中止
 		}
 	}
@@ -158,7 +166,7 @@ func (a *configBasedAggregator) phase1_checkAndPrepareStructure(finalLibraryPath
 // --- 阶段二：归档中转站文件夹 ---
 func (a *configBasedAggregator) phase2_archiveStagingFolders(stagingPath, finalLibraryPath string) (map[string]string, map[string]bool, error) {
 	a.logger.Println("--- 阶段 1/3: 归档中转站内容 ---")
-	entries, err := os.ReadDir(stagingPath)
+	entries, err := a.fs.ReadDir(stagingPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil, nil
@@ -166,42 +174,61 @@ func (a *configBasedAggregator) phase2_archiveStagingFolders(stagingPath, finalL
 		return nil, nil, err
 	}
 
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	// 库中已有的同名文件夹，按名称索引；同名文件夹不止一个时取路径最小的
+	existing := make(map[string]string)
+	located := make([]string, 0)
+	for path := range a.locate(finalLibraryPath, names) {
+		located = append(located, path)
+	}
+	sort.Strings(located)
+	for _, path := range located {
+		if _, ok := existing[filepath.Base(path)]; !ok {
+			existing[filepath.Base(path)] = path
+		}
+	}
+
 	var wg sync.WaitGroup
-	tasks := make(chan string, len(entries))
+	tasks := make(chan string, len(names))
 	movedSet := make(map[string]string)
 	unMovedSet := make(map[string]bool)
 	var mu sync.Mutex
 
 	for i := 0; i < a.numWorkers; i++ {
 		wg.Add(1)
-		go a.archiveWorker(&wg, stagingPath, finalLibraryPath, tasks, movedSet, unMovedSet, &mu)
+		go a.archiveWorker(&wg, stagingPath, finalLibraryPath, existing, tasks, movedSet, unMovedSet, &mu)
 	}
-	for _, entry := range entries {
-		if entry.IsDir() {
-			tasks <- entry.Name()
-		}
+	for _, name := range names {
+		tasks <- name
 	}
 	close(tasks)
 	wg.Wait()
 	return movedSet, unMovedSet, nil
 }
-func (a *configBasedAggregator) archiveWorker(wg *sync.WaitGroup, stagingPath, finalLibraryPath string, tasks <-chan string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
+func (a *configBasedAggregator) archiveWorker(wg *sync.WaitGroup, stagingPath, finalLibraryPath string, existing map[string]string, tasks <-chan string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
 	defer wg.Done()
 	for folderName := range tasks {
 		oldPath, _ := filepath.Abs(filepath.Join(stagingPath, folderName))
-		firstChar := findFirstAlphaNum(unidecode.Unidecode(folderName))
-		archiveDirName := "#"
-		if firstChar >= 'A' && firstChar <= 'Z' {
-			archiveDirName = string(firstChar)
+		newPath, ok := existing[folderName]
+		if !ok {
+			newPath = a.seriesTarget(finalLibraryPath, folderName)
 		}
-		newPath := filepath.Join(finalLibraryPath, archiveDirName, folderName)
 
 		mu.Lock()
-		if _, err := os.Stat(newPath); err == nil {
-			a.logger.Printf("归档冲突: 目标 '%s' 已存在，跳过移动。", newPath)
+		if fsutil.Exists(a.fs, newPath) {
+			a.logger.Printf("归档冲突: 目标 '%s' 已存在，隔离中转站文件夹。", newPath)
+			unMovedSet[oldPath] = true
+			a.isolateCollision(oldPath, newPath)
+		} else if err := a.fs.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			a.logger.Printf("错误: 无法创建归档目录 %s: %v", filepath.Dir(newPath), err)
 			unMovedSet[oldPath] = true
 		} else {
-			if err := os.Rename(oldPath, newPath); err != nil {
+			if err := fsutil.RenameWithProgress(a.fs, oldPath, newPath, fsutil.LogProgress(a.logger.Printf, folderName)); err != nil {
 				a.logger.Printf("错误: 归档移动 %s 失败: %v", oldPath, err)
 				unMovedSet[oldPath] = true
 			} else {
@@ -214,31 +241,29 @@ func (a *configBasedAggregator) archiveWorker(wg *sync.WaitGroup, stagingPath, f
 }
 
 // --- 阶段三：在最终库内进行聚合 ---
-func (a *configBasedAggregator) phase3_aggregateWithinArchiveFolders(finalLibraryPath, quarantinePath string) (map[string]string, map[string]bool, error) {
+func (a *configBasedAggregator) phase3_aggregateWithinArchiveFolders(finalLibraryPath string) (map[string]string, map[string]bool, error) {
 	a.logger.Println("--- 阶段 3/3: 在最终库内执行聚合 ---")
 	var wg sync.WaitGroup
-	archiveDirs, _ := os.ReadDir(finalLibraryPath)
+	archiveDirs := bucketDirs(a.fs, finalLibraryPath, a.layout.Depth())
 	tasks := make(chan string, len(archiveDirs))
 	movedSet := make(map[string]string)
 	unMovedSet := make(map[string]bool)
 	var mu sync.Mutex
 	for i := 0; i < a.numWorkers; i++ {
 		wg.Add(1)
-		go a.aggregationWorker(&wg, tasks, quarantinePath, movedSet, unMovedSet, &mu)
+		go a.aggregationWorker(&wg, tasks, movedSet, unMovedSet, &mu)
 	}
 	for _, dir := range archiveDirs {
-		if dir.IsDir() && len(dir.Name()) == 1 {
-			tasks <- filepath.Join(finalLibraryPath, dir.Name())
-		}
+		tasks <- dir
 	}
 	close(tasks)
 	wg.Wait()
 	return movedSet, unMovedSet, nil
 }
-func (a *configBasedAggregator) aggregationWorker(wg *sync.WaitGroup, tasks <-chan string, quarantinePath string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
+func (a *configBasedAggregator) aggregationWorker(wg *sync.WaitGroup, tasks <-chan string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
 	defer wg.Done()
 	for archivePath := range tasks {
-		seriesEntries, err := os.ReadDir(archivePath)
+		seriesEntries, err := a.fs.ReadDir(archivePath)
 		if err != nil || len(seriesEntries) < 2 {
 			continue
 		}
@@ -270,59 +295,105 @@ func (a *configBasedAggregator) aggregationWorker(wg *sync.WaitGroup, tasks <-ch
 			if targetAggDir == "" {
 				targetAggDir = filepath.Join(archivePath, sanitizeName(groupName)+aggSuffix)
 			}
-			if err := os.MkdirAll(targetAggDir, 0755); err != nil {
+			if err := a.fs.MkdirAll(targetAggDir, 0755); err != nil {
 				a.logger.Printf("错误：无法创建聚合目录 %s: %v", targetAggDir, err)
 				continue // 如果无法创建，则中止对这个组的处理
 			}
 			for _, memberPath := range nonAggMembers {
 				newPath := filepath.Join(targetAggDir, filepath.Base(memberPath))
-				a.groupMove(memberPath, newPath, quarantinePath, movedSet, unMovedSet, mu)
+				a.groupMove(memberPath, newPath, movedSet, unMovedSet, mu)
 			}
 		}
 	}
 }
 
+// seriesTarget 计算库中还没有同名文件夹的系列 name 的归档路径。数据库中已有同名系列时
+// (例如文件夹被手动移走后又下载了新内容) 沿用它记录的文件夹，或按它的入库信息计算归档目录，
+// 否则按新系列 (当前时间) 计算。
+func (a *configBasedAggregator) seriesTarget(finalLibraryPath, name string) string {
+	info := layout.SeriesInfo{Name: name, CreatedAt: time.Now()}
+	series, err := a.series.GetByName(context.Background(), name)
+	switch {
+	case err != nil:
+		a.logger.Printf("警告：查询系列 '%s' 失败，按新系列归档: %v", name, err)
+	case series != nil && series.Path != "" && isWithinDir(series.Path, finalLibraryPath):
+		return series.Path
+	case series != nil:
+		info.SortName, info.CreatedAt = series.SortName, series.CreatedAt
+		if series.Source != nil {
+			info.Author = series.Source.Author
+		}
+	}
+	return layout.SeriesDir(a.layout, finalLibraryPath, info)
+}
+
+func (a *configBasedAggregator) LocateSeries(finalLibraryPath string, seriesNames []string) map[string]string {
+	found := a.locate(finalLibraryPath, seriesNames)
+	a.logger.Printf("在最终库中找到 %d 个本次运行的系列文件夹。", len(found))
+	return found
+}
+
+// locate 在最终库的归档目录和聚合目录中查找名为 seriesNames 的系列文件夹，返回 路径 -> 自身。
+func (a *configBasedAggregator) locate(finalLibraryPath string, seriesNames []string) map[string]string {
+	wanted := make(map[string]bool, len(seriesNames))
+	for _, name := range seriesNames {
+		wanted[name] = true
+	}
+	found := make(map[string]string)
+	for _, dir := range bucketDirs(a.fs, finalLibraryPath, a.layout.Depth()) {
+		entries, err := a.fs.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if wanted[e.Name()] {
+				found[path] = path
+			}
+			if !strings.HasSuffix(e.Name(), aggSuffix) {
+				continue
+			}
+			// 聚合目录中的成员保持原来的系列名
+			members, err := a.fs.ReadDir(path)
+			if err != nil {
+				continue
+			}
+			for _, member := range members {
+				if member.IsDir() && wanted[member.Name()] {
+					memberPath := filepath.Join(path, member.Name())
+					found[memberPath] = memberPath
+				}
+			}
+		}
+	}
+	return found
+}
+
 // --- 辅助函数 ---
 func (a *configBasedAggregator) groupSeries(seriesPaths []string) map[string][]string {
 	groups := make(map[string][]string)
 	for _, seriesPath := range seriesPaths {
 		folderName := filepath.Base(seriesPath)
-		var groupName string
 		baseName := strings.TrimSuffix(folderName, aggSuffix)
-		for _, rule := range a.seriesGroupRules {
-			matches := rule.Re.FindStringSubmatch(baseName)
-			if len(matches) > 1 {
-				for i, n := range rule.Re.SubexpNames() {
-					if n == "group" && i < len(matches) {
-						groupName = matches[i]
-						break
-					}
-				}
-			}
-			if groupName != "" {
-				break
-			}
-		}
-		if groupName != "" {
+		if _, groupName := matchGroupRule(a.seriesGroupRules, baseName); groupName != "" {
 			groups[groupName] = append(groups[groupName], seriesPath)
 		}
 	}
 	return groups
 }
 
-func (a *configBasedAggregator) groupMove(src, dest string, quarantinePath string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
+func (a *configBasedAggregator) groupMove(src, dest string, movedSet map[string]string, unMovedSet map[string]bool, mu *sync.Mutex) {
 	mu.Lock()
 	defer mu.Unlock()
-	if _, err := os.Stat(dest); err == nil {
+	if fsutil.Exists(a.fs, dest) {
 		a.logger.Printf("聚合冲突: 目标 '%s' 已存在，隔离源文件夹。", dest)
 		unMovedSet[src] = true
-		// 移动到隔离区
-		quarantineDest := filepath.Join(quarantinePath, fmt.Sprintf("%s_%d", filepath.Base(src), time.Now().UnixNano()))
-		if err := os.Rename(src, quarantineDest); err != nil {
-			a.logger.Printf("错误: 隔离文件夹 '%s' 失败: %v", src, err)
-		}
+		a.isolateCollision(src, dest)
 	} else {
-		if err := os.Rename(src, dest); err != nil {
+		if err := fsutil.RenameWithProgress(a.fs, src, dest, fsutil.LogProgress(a.logger.Printf, filepath.Base(src))); err != nil {
 			a.logger.Printf("错误: 聚合移动 %s 失败: %v", src, err)
 			unMovedSet[src] = true
 		} else {
@@ -332,11 +403,46 @@ func (a *configBasedAggregator) groupMove(src, dest string, quarantinePath strin
 	}
 }
 
-func findFirstAlphaNum(s string) rune {
-	for _, r := range s {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) {
-			return unicode.ToUpper(r)
+// isolateCollision 把与 target 冲突的文件夹 src 移入隔离区并记录冲突目标，
+// 之后可以通过隔离区 API 对比两者并选择合并、替换、重命名或丢弃。
+func (a *configBasedAggregator) isolateCollision(src, target string) {
+	item := &models.QuarantineItem{
+		Kind:         models.QuarantineCollision,
+		OriginalPath: src,
+		TargetPath:   target,
+		Error:        fmt.Sprintf("目标文件夹 '%s' 已存在", target),
+	}
+	if err := a.quarantine.Isolate(context.Background(), item); err != nil {
+		a.logger.Printf("错误: 隔离文件夹 '%s' 失败: %v", src, err)
+		return
+	}
+	a.logger.Printf("冲突文件夹已隔离: %s -> %s (记录 %s)", src, item.QuarantinedPath, item.ID.Hex())
+}
+
+// isWithinDir 判断 path 是否位于 root 之下 (不含 root 本身)。
+func isWithinDir(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// bucketDirs 返回 fsys 中 root 下第 depth 层的所有归档目录 (depth 为 0 时返回 root 本身)。
+// 聚合目录 (_agg) 和隐藏目录不会被当作归档目录。
+func bucketDirs(fsys fsutil.FS, root string, depth int) []string {
+	dirs := []string{root}
+	for i := 0; i < depth; i++ {
+		var next []string
+		for _, dir := range dirs {
+			entries, err := fsys.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && !strings.HasSuffix(e.Name(), aggSuffix) {
+					next = append(next, filepath.Join(dir, e.Name()))
+				}
+			}
 		}
+		dirs = next
 	}
-	return '#'
+	return dirs
 }
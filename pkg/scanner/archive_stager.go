@@ -0,0 +1,122 @@
+package scanner
+
+import (
+	"PICs_Manager/pkg/archive"
+	"PICs_Manager/pkg/fsutil"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const archiveStagerLogFileName = "archive_stager.log"
+
+// ArchiveStager 负责把扫描目录中的 ZIP/CBZ 压缩包作为整体放入中转站。
+// 每个压缩包就是一个系列：它被原样移动到 staging/<系列名>/ 下，之后随系列目录一起归档，
+// 包内图片由入库器以 "<压缩包>!/<条目>" 的路径原地索引，不会被解压到磁盘。
+type ArchiveStager interface {
+	StageArchives(scanPath string) (seriesNames []string, err error)
+	Close()
+}
+
+type defaultArchiveStager struct {
+	fs       fsutil.FS
+	destPath string
+	logger   *log.Logger
+	logFile  *os.File
+}
+
+func NewArchiveStager(logDir string, fsys fsutil.FS, destPath string) (ArchiveStager, error) {
+	logFilePath := filepath.Join(logDir, archiveStagerLogFileName)
+	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("无法初始化压缩包处理日志: %w", err)
+	}
+	logger := log.New(file, "ARCHIVE: ", log.LstdFlags|log.Lshortfile)
+	logger.Println("================== 新的压缩包处理任务开始 ==================")
+	return &defaultArchiveStager{
+		fs:       fsys,
+		destPath: destPath,
+		logger:   logger,
+		logFile:  file,
+	}, nil
+}
+
+func (s *defaultArchiveStager) Close() {
+	if s.logFile != nil {
+		s.logger.Println("================== 压缩包处理任务结束，关闭日志文件 ==================")
+		s.logFile.Close()
+	}
+}
+
+// StageArchives 遍历扫描目录，把每个可读且含有图片的压缩包移动到中转站对应的系列目录。
+// 损坏或不含图片的压缩包留在原处，只记录日志。
+func (s *defaultArchiveStager) StageArchives(scanPath string) ([]string, error) {
+	var archives []string
+	err := fsutil.WalkDir(s.fs, scanPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && archive.IsArchiveExtension(path) {
+			archives = append(archives, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历扫描目录失败: %w", err)
+	}
+	s.logger.Printf("在 %s 中找到 %d 个压缩包。", scanPath, len(archives))
+
+	uniqueSeriesNames := make(map[string]struct{})
+	for _, archivePath := range archives {
+		seriesName, ok := s.stage(archivePath)
+		if ok {
+			uniqueSeriesNames[seriesName] = struct{}{}
+		}
+	}
+
+	seriesNames := make([]string, 0, len(uniqueSeriesNames))
+	for name := range uniqueSeriesNames {
+		seriesNames = append(seriesNames, name)
+	}
+	return seriesNames, nil
+}
+
+func (s *defaultArchiveStager) stage(archivePath string) (string, bool) {
+	r, err := archive.OpenReaderFS(s.fs, archivePath)
+	if err != nil {
+		s.logger.Printf("错误: 压缩包 %s 无法打开，保留在原处 (错误: %v)", archivePath, err)
+		return "", false
+	}
+	images := r.Images()
+	seriesName := sanitizeName(r.SeriesName(archivePath))
+	r.Close()
+
+	if len(images) == 0 {
+		s.logger.Printf("压缩包 %s 中没有可识别的图片，跳过。", archivePath)
+		return "", false
+	}
+	if seriesName == "" {
+		s.logger.Printf("无法为压缩包 %s 确定系列名，跳过。", archivePath)
+		return "", false
+	}
+
+	targetDir := filepath.Join(s.destPath, seriesName)
+	targetFile := filepath.Join(targetDir, filepath.Base(archivePath))
+	if err := s.fs.MkdirAll(targetDir, 0755); err != nil {
+		s.logger.Printf("错误：无法创建系列目录 %s: %v", targetDir, err)
+		return "", false
+	}
+	if fsutil.Exists(s.fs, targetFile) {
+		s.logger.Printf("错误：中转站中已存在同名压缩包 %s，跳过 %s", targetFile, archivePath)
+		return "", false
+	}
+	if err := s.fs.Rename(archivePath, targetFile); err != nil {
+		s.logger.Printf("错误：无法移动压缩包 %s -> %s: %v", archivePath, targetFile, err)
+		return "", false
+	}
+
+	s.logger.Printf("压缩包已移动: %s -> %s (%d 张图片)", filepath.Base(archivePath), targetDir, len(images))
+	return seriesName, true
+}
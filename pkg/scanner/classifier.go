@@ -1,12 +1,14 @@
 package scanner
 
 import (
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/sidecar"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -16,41 +18,46 @@ const (
 )
 
 // 将用于并发结果传递的结构体定义在函数外部，使其成为一个明确的类型。
+// seriesName 为空表示文件未被分类。
 type classificationResult struct {
 	seriesName string
 	fileName   string
+	filePath   string
+	classifier string
+}
+
+// ClassificationResult 是分类阶段的结果。
+type ClassificationResult struct {
+	SeriesNames []string
+	FileNames   []string
+	// Unclassified 是所有分类器都无法处理、仍留在扫描目录中的文件。
+	Unclassified []string
+	// ByClassifier 记录每种分类器处理的文件数量。
+	ByClassifier map[string]int
 }
 
 type SeriesClassifier interface {
-	ClassifyAndMove(healthyFiles []string) (seriesNames []string, fileNames []string, err error)
+	ClassifyAndMove(scanRoot string, healthyFiles []string) (*ClassificationResult, error)
 	Close()
 }
 
-// regexClassifier
-type regexClassifier struct {
-	destPath    string
-	fileRegexps []*regexp.Regexp
-	numWorkers  int
-	logger      *log.Logger
-	logFile     *os.File
+// chainClassifier 按顺序尝试分类链中的每个分类器，第一个给出系列名的分类器决定文件去向。
+type chainClassifier struct {
+	fs         fsutil.FS
+	destPath   string
+	chain      []ClassifyStrategy
+	numWorkers int
+	logger     *log.Logger
+	logFile    *os.File
 }
 
-func NewClassifier(logDir string, destPath string, patterns []string, workerCount int) (SeriesClassifier, error) {
+func NewClassifier(logDir string, fsys fsutil.FS, destPath string, chain []ClassifyStrategy, workerCount int) (SeriesClassifier, error) {
 	logFilePath := filepath.Join(logDir, classifierLogFileName)
 	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		return nil, fmt.Errorf("无法初始化分类器日志: %w", err)
 	}
 	logger := log.New(file, "CLASSIFY: ", log.LstdFlags|log.Lshortfile)
-	compiledRegexps := make([]*regexp.Regexp, 0, len(patterns))
-	for _, p := range patterns {
-		re, err := regexp.Compile(p)
-		if err != nil {
-			file.Close()
-			return nil, fmt.Errorf("无效的文件匹配模式 '%s': %w", p, err)
-		}
-		compiledRegexps = append(compiledRegexps, re)
-	}
 	effectiveWorkerCount := workerCount
 	if effectiveWorkerCount <= 0 {
 		effectiveWorkerCount = runtime.NumCPU()
@@ -58,17 +65,23 @@ func NewClassifier(logDir string, destPath string, patterns []string, workerCoun
 	} else {
 		logger.Printf("使用配置中的 workerCount: %d", effectiveWorkerCount)
 	}
+	names := make([]string, 0, len(chain))
+	for _, s := range chain {
+		names = append(names, s.Name())
+	}
+	logger.Printf("分类链: %s", strings.Join(names, " -> "))
 	logger.Println("================== 新的分类任务开始 ==================")
-	return &regexClassifier{
-		destPath:    destPath,
-		fileRegexps: compiledRegexps,
-		numWorkers:  effectiveWorkerCount,
-		logger:      logger,
-		logFile:     file,
+	return &chainClassifier{
+		fs:         fsys,
+		destPath:   destPath,
+		chain:      chain,
+		numWorkers: effectiveWorkerCount,
+		logger:     logger,
+		logFile:    file,
 	}, nil
 }
 
-func (c *regexClassifier) Close() {
+func (c *chainClassifier) Close() {
 	if c.logFile != nil {
 		c.logger.Println("================== 分类任务结束，关闭日志文件 ==================")
 		c.logFile.Close()
@@ -77,14 +90,14 @@ func (c *regexClassifier) Close() {
 
 // ClassifyAndMove
 // 创建通道时使用classificationResult 类型
-func (c *regexClassifier) ClassifyAndMove(healthyFiles []string) ([]string, []string, error) {
+func (c *chainClassifier) ClassifyAndMove(scanRoot string, healthyFiles []string) (*ClassificationResult, error) {
 	var wg sync.WaitGroup
 	tasks := make(chan string, c.numWorkers)
 	results := make(chan classificationResult, len(healthyFiles))
 
 	for i := 0; i < c.numWorkers; i++ {
 		wg.Add(1)
-		go c.worker(&wg, tasks, results)
+		go c.worker(&wg, scanRoot, tasks, results)
 	}
 
 	for _, path := range healthyFiles {
@@ -96,60 +109,81 @@ func (c *regexClassifier) ClassifyAndMove(healthyFiles []string) ([]string, []st
 	close(results)
 
 	uniqueSeriesNames := make(map[string]struct{})
-	processedFileNames := make([]string, 0, len(healthyFiles))
+	result := &ClassificationResult{
+		FileNames:    make([]string, 0, len(healthyFiles)),
+		Unclassified: []string{},
+		ByClassifier: make(map[string]int),
+	}
 	for res := range results {
+		if res.seriesName == "" {
+			result.Unclassified = append(result.Unclassified, res.filePath)
+			continue
+		}
 		uniqueSeriesNames[res.seriesName] = struct{}{}
-		processedFileNames = append(processedFileNames, res.fileName)
+		result.FileNames = append(result.FileNames, res.fileName)
+		result.ByClassifier[res.classifier]++
 	}
+	sort.Strings(result.Unclassified)
 
-	finalSeriesNames := make([]string, 0, len(uniqueSeriesNames))
+	result.SeriesNames = make([]string, 0, len(uniqueSeriesNames))
 	for name := range uniqueSeriesNames {
-		finalSeriesNames = append(finalSeriesNames, name)
+		result.SeriesNames = append(result.SeriesNames, name)
+	}
+	if len(result.Unclassified) > 0 {
+		c.logger.Printf("共有 %d 个文件无法分类，仍留在扫描目录中", len(result.Unclassified))
 	}
 
-	return finalSeriesNames, processedFileNames, nil
+	return result, nil
 }
 
 // worker
 // 函数参数中明确使用 chan<- classificationResult 类型
-func (c *regexClassifier) worker(wg *sync.WaitGroup, tasks <-chan string, results chan<- classificationResult) {
+func (c *chainClassifier) worker(wg *sync.WaitGroup, scanRoot string, tasks <-chan string, results chan<- classificationResult) {
 	defer wg.Done()
 	for filePath := range tasks {
 		fileName := filepath.Base(filePath)
-		seriesName := c.extractSeriesName(fileName)
+		seriesName, classifier := c.classify(scanRoot, filePath)
 
 		if seriesName == "" {
-			c.logger.Printf("文件无法分类，跳过: %s", fileName)
+			c.logger.Printf("文件无法分类，跳过: %s", filePath)
+			results <- classificationResult{fileName: fileName, filePath: filePath}
 			continue
 		}
 
 		targetDir := filepath.Join(c.destPath, seriesName)
 		targetFile := filepath.Join(targetDir, fileName)
 
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
+		if err := c.fs.MkdirAll(targetDir, 0755); err != nil {
 			c.logger.Printf("错误：无法创建系列目录 %s: %v", targetDir, err)
 			continue
 		}
 
-		if err := os.Rename(filePath, targetFile); err != nil {
+		if err := c.fs.Rename(filePath, targetFile); err != nil {
 			c.logger.Printf("错误：无法移动文件 %s -> %s: %v", filePath, targetFile, err)
 			continue
 		}
 
-		c.logger.Printf("文件已移动: %s -> %s", fileName, targetDir)
+		c.logger.Printf("文件已移动 [%s]: %s -> %s", classifier, fileName, targetDir)
 
-		results <- classificationResult{seriesName: seriesName, fileName: fileName}
+		// 下载器的元数据文件随图片一起移动，入库时从中读取来源信息
+		if sc := sidecar.PathFS(c.fs, filePath); sc != "" {
+			if err := c.fs.Rename(sc, sidecar.MovedPath(sc, filePath, targetFile)); err != nil {
+				c.logger.Printf("警告：无法移动元数据文件 %s: %v", sc, err)
+			}
+		}
+
+		results <- classificationResult{seriesName: seriesName, fileName: fileName, filePath: filePath, classifier: classifier}
 	}
 }
 
-func (c *regexClassifier) extractSeriesName(fileName string) string {
-	for _, re := range c.fileRegexps {
-		matches := re.FindStringSubmatch(fileName)
-		if len(matches) > 1 {
-			return sanitizeName(matches[1])
+// classify 依次尝试分类链，返回清理后的系列名和给出它的分类器。
+func (c *chainClassifier) classify(scanRoot, filePath string) (string, string) {
+	for _, s := range c.chain {
+		if name := sanitizeName(s.SeriesName(scanRoot, filePath)); name != "" {
+			return name, s.Name()
 		}
 	}
-	return ""
+	return "", ""
 }
 
 func sanitizeName(name string) string {
@@ -159,3 +193,22 @@ func sanitizeName(name string) string {
 	sanitized = strings.TrimRight(sanitized, ". ")
 	return strings.TrimSpace(sanitized)
 }
+
+// withoutSidecars 去掉 files 中属于其他媒体文件的下载器元数据文件。
+// 这些文件由 worker 随媒体文件一起移动，如果也交给分类器，可能在媒体文件之前被单独归入
+// _unsorted 等系列，或者在已经随媒体文件移走之后仍被报告为无法分类。
+func withoutSidecars(fsys fsutil.FS, files []string) []string {
+	owned := make(map[string]struct{})
+	for _, path := range files {
+		if sc := sidecar.PathFS(fsys, path); sc != "" {
+			owned[sc] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(files))
+	for _, path := range files {
+		if _, ok := owned[path]; !ok {
+			out = append(out, path)
+		}
+	}
+	return out
+}
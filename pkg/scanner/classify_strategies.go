@@ -0,0 +1,196 @@
+package scanner
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/imagemeta"
+	"PICs_Manager/pkg/sidecar"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// 分类器类型，对应 scanner.classifiers[].type 配置项。
+const (
+	ClassifyRegex        = "regex"
+	ClassifyParentFolder = "parent-folder"
+	ClassifyExifDate     = "exif-date"
+	ClassifySidecar      = "sidecar"
+	ClassifyUnsorted     = "unsorted"
+)
+
+const (
+	defaultExifDateFormat = "2006-01"
+	defaultUnsortedSeries = "_unsorted"
+)
+
+// defaultSidecarFields 是 gallery-dl / pixiv 下载器元数据中常见的标题和作者字段。
+var defaultSidecarFields = []string{"title", "user.name", "artist", "author"}
+
+// ClassifyStrategy 是分类链中的一种分类方式。
+type ClassifyStrategy interface {
+	// Name 返回分类器类型，用于日志和任务结果中的统计。
+	Name() string
+	// SeriesName 返回文件所属的系列名 (未清理非法字符)，无法判断时返回空字符串。
+	// scanRoot 是本次扫描的根目录。
+	SeriesName(scanRoot, filePath string) string
+}
+
+// NewClassifyChain 按配置创建分类链。rules 为空时只使用基于 filePatterns 的 regex 分类器，与旧版本行为一致。
+// 需要读取文件内容的分类器 (exif-date、sidecar) 通过 fsys 读取。
+func NewClassifyChain(fsys fsutil.FS, rules []config.ClassifierRule, filePatterns []string) ([]ClassifyStrategy, error) {
+	if len(rules) == 0 {
+		rules = []config.ClassifierRule{{Type: ClassifyRegex}}
+	}
+	chain := make([]ClassifyStrategy, 0, len(rules))
+	for i, rule := range rules {
+		switch rule.Type {
+		case ClassifyRegex:
+			patterns := rule.Patterns
+			if len(patterns) == 0 {
+				patterns = filePatterns
+			}
+			s, err := newRegexStrategy(patterns)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, s)
+		case ClassifyParentFolder:
+			chain = append(chain, parentFolderStrategy{})
+		case ClassifyExifDate:
+			format := rule.Format
+			if format == "" {
+				format = defaultExifDateFormat
+			}
+			chain = append(chain, exifDateStrategy{fs: fsys, format: format})
+		case ClassifySidecar:
+			fields := rule.Fields
+			if len(fields) == 0 {
+				fields = defaultSidecarFields
+			}
+			chain = append(chain, sidecarStrategy{fs: fsys, fields: fields})
+		case ClassifyUnsorted:
+			name := rule.Name
+			if name == "" {
+				name = defaultUnsortedSeries
+			}
+			chain = append(chain, unsortedStrategy{name: name})
+		default:
+			return nil, fmt.Errorf("第 %d 个分类器的类型 '%s' 无效，可选 %s, %s, %s, %s, %s", i+1, rule.Type,
+				ClassifyRegex, ClassifyParentFolder, ClassifyExifDate, ClassifySidecar, ClassifyUnsorted)
+		}
+	}
+	return chain, nil
+}
+
+// --- regex: 按 filePatterns 从文件名提取系列名 ---
+
+type regexStrategy struct {
+	fileRegexps []*regexp.Regexp
+}
+
+func newRegexStrategy(patterns []string) (*regexStrategy, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的文件匹配模式 '%s': %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return &regexStrategy{fileRegexps: compiled}, nil
+}
+
+func (s *regexStrategy) Name() string { return ClassifyRegex }
+
+func (s *regexStrategy) SeriesName(_, filePath string) string {
+	return s.extractSeriesName(filepath.Base(filePath))
+}
+
+func (s *regexStrategy) extractSeriesName(fileName string) string {
+	_, name := s.match(fileName)
+	return name
+}
+
+// match 返回第一个命中的模式序号和第一个捕获组，没有命中时返回 -1。
+func (s *regexStrategy) match(fileName string) (int, string) {
+	for idx, re := range s.fileRegexps {
+		matches := re.FindStringSubmatch(fileName)
+		if len(matches) > 1 {
+			return idx, matches[1]
+		}
+	}
+	return -1, ""
+}
+
+// --- parent-folder: 使用文件所在的文件夹名 ---
+
+type parentFolderStrategy struct{}
+
+func (parentFolderStrategy) Name() string { return ClassifyParentFolder }
+
+// SeriesName 对直接位于扫描根目录下的文件不做判断，否则所有散落的文件都会归入以扫描目录命名的系列。
+func (parentFolderStrategy) SeriesName(scanRoot, filePath string) string {
+	dir := filepath.Dir(filePath)
+	if filepath.Clean(dir) == filepath.Clean(scanRoot) {
+		return ""
+	}
+	return filepath.Base(dir)
+}
+
+// --- exif-date: 按 EXIF 拍摄时间分组 ---
+
+type exifDateStrategy struct {
+	fs     fsutil.FS
+	format string
+}
+
+func (exifDateStrategy) Name() string { return ClassifyExifDate }
+
+// SeriesName 只读取图片；视频没有 EXIF，而且可能很大，不值得整个读入内存。
+func (s exifDateStrategy) SeriesName(_, filePath string) string {
+	if !formats.IsImageExtension(filePath) {
+		return ""
+	}
+	data, err := fsutil.ReadFile(s.fs, filePath)
+	if err != nil {
+		return ""
+	}
+	exif, err := imagemeta.ParseEXIF(data)
+	if err != nil || exif.DateTaken == nil {
+		return ""
+	}
+	return exif.DateTaken.Format(s.format)
+}
+
+// --- sidecar: 读取下载器写在旁边的 .json 元数据 ---
+
+type sidecarStrategy struct {
+	fs     fsutil.FS
+	fields []string
+}
+
+func (sidecarStrategy) Name() string { return ClassifySidecar }
+
+func (s sidecarStrategy) SeriesName(_, filePath string) string {
+	fields, err := sidecar.LoadFS(s.fs, filePath)
+	if err != nil || fields == nil {
+		return ""
+	}
+	for _, key := range s.fields {
+		if v := sidecar.String(fields, key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// --- unsorted: 兜底系列 ---
+
+type unsortedStrategy struct {
+	name string
+}
+
+func (unsortedStrategy) Name() string                    { return ClassifyUnsorted }
+func (s unsortedStrategy) SeriesName(_, _ string) string { return s.name }
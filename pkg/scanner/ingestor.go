@@ -2,9 +2,17 @@ package scanner
 
 import (
 	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/archive"
 	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
 	"PICs_Manager/pkg/hasher"
+	"PICs_Manager/pkg/imagemeta"
+	"PICs_Manager/pkg/quarantine"
+	"PICs_Manager/pkg/romanize"
+	"PICs_Manager/pkg/sidecar"
 	"PICs_Manager/pkg/thumbnailer"
+	"PICs_Manager/pkg/videometa"
 	"bytes"
 	"context"
 	"fmt"
@@ -13,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,13 +32,27 @@ import (
 )
 
 // MetadataIngestor 定义了数据入库器的行为接口
+// checkpoint 为 nil 时一次性处理所有系列，不记录进度。
 type MetadataIngestor interface {
-	Sync(ctx context.Context, finalLibraryPath string, createdSeries, processedFileNames []string, changelog map[string]string) (overwrittenFiles []string, err error)
+	Sync(ctx context.Context, finalLibraryPath string, createdSeries, processedFileNames []string, changelog map[string]string, checkpoint IngestCheckpoint) (overwrittenFiles []string, err error)
 	Close()
 }
 
+// IngestCheckpoint 记录入库进度，使中断的入库可以从上次完成的批次继续。
+type IngestCheckpoint interface {
+	// Done 判断系列路径是否已在之前的批次中完成入库。
+	Done(seriesPath string) bool
+	// Commit 在一批系列的图片、失效记录和元数据都处理完毕后调用。
+	Commit(ctx context.Context, seriesPaths []string) error
+}
+
+// seriesPerCheckpoint 是记录一次入库检查点的系列数。
+const seriesPerCheckpoint = 50
+
 type mongoIngestor struct {
+	fs         fsutil.FS
 	dbStore    database.Store
+	quarantine quarantine.Manager
 	logger     *log.Logger
 	logFile    *os.File
 	numWorkers int
@@ -38,8 +61,8 @@ type mongoIngestor struct {
 
 const ingestorLogFileName = "ingestor.log"
 
-// NewIngestor 创建一个新的入库器实例
-func NewIngestor(logDir string, dbStore database.Store, workerCount, batchSize int) (MetadataIngestor, error) {
+// NewIngestor 创建一个新的入库器实例，最终库通过 fsys 读取，无法解码的文件会通过 quarantineManager 移入隔离区
+func NewIngestor(logDir string, fsys fsutil.FS, dbStore database.Store, quarantineManager quarantine.Manager, workerCount, batchSize int) (MetadataIngestor, error) {
 	logFilePath := filepath.Join(logDir, ingestorLogFileName)
 	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -55,7 +78,9 @@ func NewIngestor(logDir string, dbStore database.Store, workerCount, batchSize i
 	}
 
 	return &mongoIngestor{
+		fs:         fsys,
 		dbStore:    dbStore,
+		quarantine: quarantineManager,
 		logger:     logger,
 		logFile:    file,
 		numWorkers: workerCount,
@@ -71,15 +96,25 @@ func (m *mongoIngestor) Close() {
 }
 
 // Sync 实现了将文件系统变更同步到数据库的核心逻辑
-func (m *mongoIngestor) Sync(ctx context.Context, finalLibraryPath string, createdSeries, processedFileNames []string, changelog map[string]string) ([]string, error) {
+func (m *mongoIngestor) Sync(ctx context.Context, finalLibraryPath string, createdSeries, processedFileNames []string, changelog map[string]string, checkpoint IngestCheckpoint) ([]string, error) {
 	m.logger.Println("================== 新的入库任务开始 ==================")
-	if m.dbStore == nil {
-		m.logger.Println("警告：数据库存储未初始化，跳过。")
-		return nil, nil
-	}
-
-	// 1. 解析并收集所有需要处理的系列路径
+	// 1. 解析并收集所有需要处理的系列路径，跳过之前的批次中已完成的系列
 	seriesPathsToProcess := m.collectFinalSeriesPaths(finalLibraryPath, changelog)
+	batchSize := len(seriesPathsToProcess)
+	if checkpoint != nil {
+		remaining := seriesPathsToProcess[:0]
+		for _, path := range seriesPathsToProcess {
+			if !checkpoint.Done(path) {
+				remaining = append(remaining, path)
+			}
+		}
+		if skipped := len(seriesPathsToProcess) - len(remaining); skipped > 0 {
+			m.logger.Printf("从检查点继续：跳过 %d 个已完成入库的系列。", skipped)
+		}
+		seriesPathsToProcess = remaining
+		batchSize = seriesPerCheckpoint
+	}
+	sort.Strings(seriesPathsToProcess)
 
 	// 2. 阶段一：批量处理系列，并缓存结果
 	m.logger.Printf("--- 阶段 1/4: 处理 %d 个系列 ---", len(seriesPathsToProcess))
@@ -88,21 +123,42 @@ func (m *mongoIngestor) Sync(ctx context.Context, finalLibraryPath string, creat
 		return nil, fmt.Errorf("处理系列时失败: %w", err)
 	}
 
-	// 3. 阶段二：批量处理图片，并检测覆盖
-	m.logger.Printf("--- 阶段 2/4: 处理图片并检测覆盖 ---")
-	overwrittenFiles, err := m.processAllImages(ctx, seriesPathsToProcess, seriesCache)
-	if err != nil {
-		return nil, fmt.Errorf("处理图片时失败: %w", err)
-	}
+	// 3. 阶段二、三：按批处理图片并检测覆盖，然后更新 Series 的元数据 (ImageCount, Thumbnail)。
+	// 每批完成后记录检查点，写入失败的批次不记录，下次继续时重新处理 (图片写入都是 Upsert，可以重复执行)。
+	var overwrittenFiles []string
+	for start := 0; start < len(seriesPathsToProcess); start += batchSize {
+		batch := seriesPathsToProcess[start:min(start+batchSize, len(seriesPathsToProcess))]
+		batchCache := make(map[string]*models.Series, len(batch))
+		for _, path := range batch {
+			if series, ok := seriesCache[path]; ok {
+				batchCache[path] = series
+			}
+		}
+
+		m.logger.Printf("--- 阶段 2/4: 处理图片并检测覆盖 (系列 %d-%d / %d) ---", start+1, start+len(batch), len(seriesPathsToProcess))
+		overwritten, writeErr := m.processAllImages(ctx, batch, batchCache)
+		overwrittenFiles = append(overwrittenFiles, overwritten...)
+		m.pruneMissingImages(ctx, batchCache)
 
-	// 4. 阶段三： 更新 Series 的元数据
-	m.logger.Println("--- 阶段 3/4: 更新系列元数据 (ImageCount, Thumbnail) ---")
-	if err := m.updateAllSeriesMetadata(ctx, seriesCache); err != nil {
-		m.logger.Printf("警告: 更新系列元数据失败: %v", err)
-		// 通常这是一个非致命错误，只记录日志即可
+		m.logger.Println("--- 阶段 3/4: 更新系列元数据 (ImageCount, Thumbnail) ---")
+		if err := m.updateAllSeriesMetadata(ctx, batchCache); err != nil {
+			m.logger.Printf("警告: 更新系列元数据失败: %v", err)
+			// 通常这是一个非致命错误，只记录日志即可
+		}
+
+		if checkpoint == nil {
+			continue
+		}
+		if writeErr != nil {
+			m.logger.Printf("警告: 本批有图片写入失败，不记录检查点，下次继续时会重新处理: %v", writeErr)
+			continue
+		}
+		if err := checkpoint.Commit(ctx, batch); err != nil {
+			m.logger.Printf("警告: 记录入库检查点失败: %v", err)
+		}
 	}
 
-	// 5. 阶段四：最终验证
+	// 4. 阶段四：最终验证
 	m.logger.Println("--- 阶段 4/4: 执行最终验证查询 ---")
 	m.logger.Printf("接收到 %d 个系列名，%d 个文件名。", len(createdSeries), len(processedFileNames))
 	m.logger.Println("--- 数据库同步完成 ---")
@@ -117,7 +173,7 @@ func (m *mongoIngestor) collectFinalSeriesPaths(finalLibraryPath string, changel
 	// 我们只关心 changelog 中的最终目标路径
 	for _, newPath := range changelog {
 		// 检查路径是否存在
-		info, err := os.Stat(newPath)
+		info, err := m.fs.Stat(newPath)
 		if err != nil {
 			// 如果路径不存在，可能是因为它是一个被合并后删除的空目录，或者是一个文件。跳过。
 			continue
@@ -132,7 +188,7 @@ func (m *mongoIngestor) collectFinalSeriesPaths(finalLibraryPath string, changel
 		// 判断这个路径本身是聚合父目录，还是一个独立的系列目录
 		if strings.HasSuffix(folderName, aggSuffix) {
 			// 场景A: 这是一个聚合父目录，我们需要处理它内部的所有子目录
-			subEntries, err := os.ReadDir(newPath)
+			subEntries, err := m.fs.ReadDir(newPath)
 			if err != nil {
 				m.logger.Printf("错误: 无法读取聚合目录 %s: %v", newPath, err)
 				continue
@@ -179,7 +235,7 @@ func (m *mongoIngestor) processAllSeries(ctx context.Context, seriesPaths []stri
 		filter := bson.M{"name": seriesName}
 		update := bson.M{
 			"$set":         bson.M{"path": path, "updatedAt": time.Now()},
-			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "name": seriesName, "imageCount": 0, "createdAt": time.Now()},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "name": seriesName, "sortName": romanize.SortName(seriesName, ""), "imageCount": 0, "createdAt": time.Now()},
 		}
 		model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
 		seriesWrites = append(seriesWrites, model)
@@ -237,6 +293,7 @@ type imageResult struct {
 }
 
 // processAllImages 启动一个工作池来并发地处理所有系列下的所有图片
+// 批量写入失败时继续写入其余批次，并返回最后一个写入错误
 func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []string, seriesCache map[string]*models.Series) ([]string, error) {
 	var wg sync.WaitGroup
 	jobs := make(chan imageJob, m.batchSize*m.numWorkers)
@@ -253,11 +310,19 @@ func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []stri
 			if !ok {
 				continue
 			}
-			files, _ := os.ReadDir(seriesPath)
+			files, _ := m.fs.ReadDir(seriesPath)
 			for _, file := range files {
-				if !file.IsDir() {
-					jobs <- imageJob{filePath: filepath.Join(seriesPath, file.Name()), series: series}
+				if file.IsDir() {
+					continue
 				}
+				if strings.HasSuffix(file.Name(), sidecar.Ext) {
+					continue // 元数据文件随图片一起入库，不单独处理
+				}
+				if !formats.IsMediaExtension(file.Name()) && !archive.IsArchiveExtension(file.Name()) {
+					m.logger.Printf("跳过不在媒体扩展名允许列表中的文件: %s", filepath.Join(seriesPath, file.Name()))
+					continue
+				}
+				jobs <- imageJob{filePath: filepath.Join(seriesPath, file.Name()), series: series}
 			}
 		}
 		close(jobs)
@@ -265,6 +330,7 @@ func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []stri
 
 	var allOverwritten []string
 	var writesBatch []mongo.WriteModel
+	var writeErr error
 	done := make(chan struct{})
 
 	go func() {
@@ -278,6 +344,7 @@ func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []stri
 			if len(writesBatch) >= m.batchSize {
 				if err := m.dbStore.Images().BulkWrite(ctx, writesBatch); err != nil {
 					m.logger.Printf("错误: 批量写入图片失败: %v", err)
+					writeErr = err
 				}
 				writesBatch = []mongo.WriteModel{}
 			}
@@ -285,6 +352,7 @@ func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []stri
 		if len(writesBatch) > 0 {
 			if err := m.dbStore.Images().BulkWrite(ctx, writesBatch); err != nil {
 				m.logger.Printf("错误: 批量写入图片失败: %v", err)
+				writeErr = err
 			}
 		}
 		done <- struct{}{}
@@ -294,90 +362,308 @@ func (m *mongoIngestor) processAllImages(ctx context.Context, seriesPaths []stri
 	close(results)
 	<-done
 
-	return allOverwritten, nil
+	return allOverwritten, writeErr
 }
 
-// imageWorker 是处理单张图片的工人
+// imageWorker 是处理单个媒体文件的工人，视频交给 buildVideoModel、压缩包交给 ingestArchive 处理
 func (m *mongoIngestor) imageWorker(wg *sync.WaitGroup, ctx context.Context, jobs <-chan imageJob, results chan<- imageResult) {
 	defer wg.Done()
 	for job := range jobs {
+		if archive.IsArchiveExtension(job.filePath) {
+			m.ingestArchive(job, results)
+			continue
+		}
+		if formats.IsVideoExtension(job.filePath) {
+			if model := m.buildVideoModel(job); model != nil {
+				results <- imageResult{writeModel: model}
+			}
+			continue
+		}
+
 		filePath := job.filePath
-		fileName := filepath.Base(job.filePath)
 
 		// 1. 高效地打开文件一次
-		fileBytes, err := os.ReadFile(filePath)
+		fileBytes, err := fsutil.ReadFile(m.fs, filePath)
 		if err != nil {
 			m.logger.Printf("错误: 无法读取文件 %s: %v", filePath, err)
 			continue
 		}
 
-		// 2. 直接从内存数据计算 SHA256
-		// 我们先计算SHA256，因为即使图片损坏，这个哈希也是有意义的，可以用于日志记录
-		fileHash := hasher.CalculateSHA256FromBytes(fileBytes)
-
-		// 3. 解码图片，这既是计算需要，也是最核心的损坏检查
-		img, _, decodeErr := image.Decode(bytes.NewReader(fileBytes))
-
+		model, decodeErr := m.buildImageModel(job.series, filePath, fileBytes)
 		if decodeErr != nil {
-			// 如果解码失败，说明文件已损坏
-			m.logger.Printf("严重错误: 文件 %s 确认已损坏，无法解码 (错误: %v)。将执行删除操作。", filePath, decodeErr)
-
-			// 尝试删除这个损坏的物理文件
-			deleteErr := os.Remove(filePath)
-			if deleteErr != nil {
-				m.logger.Printf("错误: 删除损坏的文件 %s 失败: %v", filePath, deleteErr)
-			} else {
-				m.logger.Printf("成功删除损坏的文件: %s", filePath)
-			}
+			// 解码失败可能是文件损坏，也可能只是格式尚未注册或挂载点不稳定，
+			// 因此不删除文件，而是移入隔离区等待人工处理
+			m.logger.Printf("严重错误: 文件 %s 无法解码 (错误: %v)。将移入隔离区。", filePath, decodeErr)
+			m.isolate(ctx, job, hasher.CalculateSHA256FromBytes(fileBytes), decodeErr)
 
 			// 终止对这个文件的处理，不将它送入结果通道，从而实现“不入库”
 			continue
 		}
-
-		// 只有在解码成功后，才继续计算 pHash 和 thumbnail
-		var pHash, thumbnail string
-		if img != nil {
-			pHash = hasher.CalculatePerceptualHashFromImage(img)
-			thumbnail, _ = thumbnailer.CreateBase64(img, 200, 200)
+		if model != nil {
+			results <- imageResult{writeModel: model}
 		}
+	}
+}
+
+// isolate 把无法解码的文件移入隔离区；隔离失败时文件保持原样，只记录日志。
+func (m *mongoIngestor) isolate(ctx context.Context, job imageJob, fileHash string, cause error) {
+	item := &models.QuarantineItem{
+		Kind:         models.QuarantineUndecodable,
+		OriginalPath: job.filePath,
+		FileHash:     fileHash,
+		Error:        cause.Error(),
+		SeriesName:   job.series.Name,
+	}
+	if err := m.quarantine.Isolate(ctx, item); err != nil {
+		m.logger.Printf("错误: 隔离文件 %s 失败，文件保留在原处: %v", job.filePath, err)
+		return
+	}
+	m.logger.Printf("文件已隔离: %s -> %s", job.filePath, item.QuarantinedPath)
+}
+
+// ingestArchive 打开压缩包一次，为其中的每张图片生成 Upsert 指令。
+// 包内损坏的条目只记录日志，不会改动压缩包本身。
+func (m *mongoIngestor) ingestArchive(job imageJob, results chan<- imageResult) {
+	r, err := archive.OpenReaderFS(m.fs, job.filePath)
+	if err != nil {
+		m.logger.Printf("错误: 无法打开压缩包 %s: %v", job.filePath, err)
+		return
+	}
+	defer r.Close()
 
-		if fileHash == "" {
-			m.logger.Printf("错误: 计算SHA256失败，跳过文件 %s", filePath)
+	entries := r.Images()
+	m.logger.Printf("索引压缩包 %s，共 %d 张图片。", job.filePath, len(entries))
+	for _, entry := range entries {
+		entryPath := archive.JoinPath(job.filePath, entry.Name)
+		data, err := entry.Read()
+		if err != nil {
+			m.logger.Printf("错误: 无法读取压缩包条目 %s: %v", entryPath, err)
+			continue
+		}
+		model, decodeErr := m.buildImageModel(job.series, entryPath, data)
+		if decodeErr != nil {
+			m.logger.Printf("警告: 压缩包条目 %s 无法解码，跳过 (错误: %v)", entryPath, decodeErr)
 			continue
 		}
+		if model != nil {
+			results <- imageResult{writeModel: model}
+		}
+	}
+}
 
-		// 4. 准备 Upsert 操作
-		series, err := m.dbStore.Series().FindOrCreateByName(ctx, filepath.Base(filepath.Dir(job.filePath)), job.filePath)
+// buildImageModel 为一张图片 (普通文件或压缩包条目) 生成 Upsert 指令。
+// 只有解码失败时才返回 error，其余无法入库的情况记录日志并返回 nil。
+func (m *mongoIngestor) buildImageModel(series *models.Series, filePath string, fileBytes []byte) (mongo.WriteModel, error) {
+	fileName := archive.FileName(filePath)
 
+	// 直接从内存数据计算 SHA256
+	// 我们先计算SHA256，因为即使图片损坏，这个哈希也是有意义的，可以用于日志记录
+	fileHash := hasher.CalculateSHA256FromBytes(fileBytes)
+
+	// 解码图片，这既是计算需要，也是最核心的损坏检查
+	img, format, decodeErr := image.Decode(bytes.NewReader(fileBytes))
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	// 只有在解码成功后，才继续计算 pHash 和 thumbnail
+	// pHash 基于原始像素计算，与以图搜图时对上传文件的计算方式保持一致；
+	// 缩略图则按 EXIF 方向校正，避免手机照片横躺显示。
+	meta := imagemeta.Extract(fileBytes, img, format)
+	var pHash, thumbnail string
+	if img != nil {
+		pHash = hasher.CalculatePerceptualHashFromImage(img)
+		thumbnail, _ = thumbnailer.CreateBase64(thumbnailer.ApplyOrientation(img, meta.Orientation()), 200, 200)
+	}
+
+	if fileHash == "" {
+		m.logger.Printf("错误: 计算SHA256失败，跳过文件 %s", filePath)
+		return nil, nil
+	}
+
+	mediaType := models.MediaTypeImage
+	if meta.FrameCount > 1 {
+		mediaType = models.MediaTypeAnimation
+	}
+
+	// 准备 Upsert 操作
+	filter := bson.M{
+		"seriesId": series.ID,
+		"fileName": fileName,
+	}
+	update := bson.M{
+		// $set: 无论找到与否，都应该更新这些可能会变动的信息
+		"$set": bson.M{
+			"filePath":       filePath,
+			"fileHash":       fileHash,
+			"perceptualHash": pHash,
+			"thumbnail":      thumbnail,
+			"width":          meta.Width,
+			"height":         meta.Height,
+			"format":         meta.Format,
+			"fileSize":       meta.FileSize,
+			"colorModel":     meta.ColorModel,
+			"frameCount":     meta.FrameCount,
+			"mediaType":      mediaType,
+			"duration":       meta.Duration,
+			"updatedAt":      time.Now(),
+		},
+		// $setOnInsert: 只有在首次插入时，才设置这些“出生”信息
+		"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"seriesId":  series.ID,
+			"fileName":  fileName,
+			"createdAt": time.Now(),
+		},
+	}
+	setOptional(update, "exif", toModelEXIF(meta.EXIF))
+	setOptional(update, "source", m.loadSource(filePath))
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpsert(true).SetUpdate(update), nil
+}
+
+// buildVideoModel 为单个视频文件生成 Upsert 指令。
+// 时长和尺寸取自容器头部；能纯 Go 解码出封面帧时，用它生成缩略图和 pHash。
+// 容器无法解析时不删除文件 (可能只是不支持的变体)，仍按已知信息入库，以便在库中可见。
+func (m *mongoIngestor) buildVideoModel(job imageJob) mongo.WriteModel {
+	filePath := job.filePath
+	fileName := filepath.Base(filePath)
+
+	stat, err := m.fs.Stat(filePath)
+	if err != nil {
+		m.logger.Printf("错误: 无法读取文件 %s: %v", filePath, err)
+		return nil
+	}
+	// 视频可能很大，流式计算哈希而不是整体读入内存
+	fileHash, err := hasher.CalculateSHA256FS(m.fs, filePath)
+	if err != nil {
+		m.logger.Printf("错误: 计算SHA256失败，跳过文件 %s: %v", filePath, err)
+		return nil
+	}
+
+	set := bson.M{
+		"filePath":  filePath,
+		"fileHash":  fileHash,
+		"fileSize":  stat.Size(),
+		"mediaType": models.MediaTypeVideo,
+		"updatedAt": time.Now(),
+	}
+
+	file, err := m.fs.Open(filePath)
+	if err != nil {
+		m.logger.Printf("错误: 无法打开文件 %s: %v", filePath, err)
+		return nil
+	}
+	info, probeErr := videometa.Probe(file)
+	file.Close()
+
+	if probeErr != nil {
+		m.logger.Printf("警告: 无法解析视频容器 %s (错误: %v)，仅记录文件信息。", filePath, probeErr)
+	} else {
+		set["format"] = info.Container
+		set["codec"] = info.Codec
+		set["width"] = info.Width
+		set["height"] = info.Height
+		set["duration"] = info.Duration
+		if info.Poster != nil {
+			set["perceptualHash"] = hasher.CalculatePerceptualHashFromImage(info.Poster)
+			set["thumbnail"], _ = thumbnailer.CreateBase64(info.Poster, 200, 200)
+		} else {
+			m.logger.Printf("提示: 视频 %s 没有可纯 Go 解码的封面帧，跳过缩略图。", filePath)
+		}
+	}
+
+	filter := bson.M{
+		"seriesId": job.series.ID,
+		"fileName": fileName,
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"seriesId":  job.series.ID,
+			"fileName":  fileName,
+			"createdAt": time.Now(),
+		},
+	}
+	setOptional(update, "source", m.loadSource(filePath))
+	return mongo.NewUpdateOneModel().SetFilter(filter).SetUpsert(true).SetUpdate(update)
+}
+
+// toModelEXIF 将解析出的 EXIF 转换为数据库模型，没有 EXIF 时返回 nil。
+func toModelEXIF(exif *imagemeta.EXIF) *models.ImageEXIF {
+	if exif == nil {
+		return nil
+	}
+	return exif.Model()
+}
+
+// setOptional 在 value 不为 nil 时把它加入 update 的 $set，否则加入 $unset。
+// 与模型的 omitempty 一致，没有该信息的文档不带这个字段，而不是保存为 null：
+// FindWithoutEXIF 按 exif 字段是否存在查找，null 会被当作已经读取过 EXIF。
+// 参数是 *T 而不是 any，nil 指针才不会被包装成非 nil 的接口值。
+func setOptional[T any](update bson.M, key string, value *T) {
+	if value != nil {
+		update["$set"].(bson.M)[key] = value
+		return
+	}
+	unset, _ := update["$unset"].(bson.M)
+	if unset == nil {
+		unset = bson.M{}
+		update["$unset"] = unset
+	}
+	unset[key] = ""
+}
+
+// loadSource 读取文件旁的下载器元数据，没有元数据文件或文件是压缩包条目时返回 nil。
+func (m *mongoIngestor) loadSource(filePath string) *models.SourceInfo {
+	if _, _, ok := archive.SplitPath(filePath); ok {
+		return nil
+	}
+	md, err := sidecar.LoadMetadataFS(m.fs, filePath)
+	if err != nil {
+		m.logger.Printf("警告: 无法读取 %s 的元数据文件: %v", filePath, err)
+		return nil
+	}
+	return toModelSource(md)
+}
+
+// toModelSource 将元数据文件中的来源信息转换为数据库模型。
+func toModelSource(md *sidecar.Metadata) *models.SourceInfo {
+	if md == nil {
+		return nil
+	}
+	return &models.SourceInfo{
+		URL:      md.SourceURL,
+		Title:    md.Title,
+		Author:   md.Author,
+		Tags:     md.Tags,
+		PostedAt: md.PostedAt,
+	}
+}
+
+// pruneMissingImages 删除文件已不在磁盘上的图片记录，例如冲突解决时被替换走、或入库时被隔离的文件。
+// 压缩包内的条目只检查压缩包本身是否存在。
+func (m *mongoIngestor) pruneMissingImages(ctx context.Context, seriesCache map[string]*models.Series) {
+	for _, series := range seriesCache {
+		images, err := m.dbStore.Images().GetAllBySeriesID(ctx, series.ID)
 		if err != nil {
-			m.logger.Printf("错误: 无法为 %s 找到或创建系列: %v", filePath, err)
+			m.logger.Printf("错误: 无法获取系列 '%s' 的图片记录: %v", series.Name, err)
 			continue
 		}
-
-		filter := bson.M{
-			"seriesId": series.ID,
-			"fileName": fileName,
+		for _, img := range images {
+			path := img.FilePath
+			if archivePath, _, ok := archive.SplitPath(path); ok {
+				path = archivePath
+			}
+			if _, err := m.fs.Stat(path); !os.IsNotExist(err) {
+				continue
+			}
+			if err := m.dbStore.Images().Delete(ctx, img.ID); err != nil {
+				m.logger.Printf("错误: 删除失效的图片记录 %s 失败: %v", img.FilePath, err)
+				continue
+			}
+			m.logger.Printf("已删除失效的图片记录: %s", img.FilePath)
 		}
-		update := bson.M{
-			// $set: 无论找到与否，都应该更新这些可能会变动的信息
-			"$set": bson.M{
-				"filePath":       filePath,
-				"fileHash":       fileHash,
-				"perceptualHash": pHash,
-				"thumbnail":      thumbnail,
-				"updatedAt":      time.Now(),
-			},
-			// $setOnInsert: 只有在首次插入时，才设置这些“出生”信息
-			"$setOnInsert": bson.M{
-				"_id":       primitive.NewObjectID(),
-				"seriesId":  series.ID,
-				"fileName":  fileName,
-				"createdAt": time.Now(),
-			},
-		}
-		model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpsert(true).SetUpdate(update)
-
-		results <- imageResult{writeModel: model}
 	}
 }
 
@@ -449,15 +735,31 @@ func (m *mongoIngestor) metadataUpdateWorker(wg *sync.WaitGroup, ctx context.Con
 			thumbnail = firstImage.Thumbnail // 使用图片的缩略图
 		}
 
-		// 3. 只有在数据发生变化时才准备更新指令
-		if series.ImageCount != int(count) || series.Thumbnail != thumbnail {
+		// 3. 系列还没有来源信息时，取第一张带元数据的图片的来源
+		var source *models.SourceInfo
+		if series.Source == nil {
+			withSource, err := m.dbStore.Images().GetFirstWithSource(ctx, series.ID)
+			if err != nil {
+				m.logger.Printf("错误: 无法获取系列 '%s' 的来源信息: %v", series.Name, err)
+			}
+			if withSource != nil {
+				source = withSource.Source
+			}
+		}
+
+		// 4. 只有在数据发生变化时才准备更新指令
+		if series.ImageCount != int(count) || series.Thumbnail != thumbnail || source != nil {
 			m.logger.Printf("系列的元数据已变更: %s (图片数: %d -> %d)", series.Name, series.ImageCount, count)
 			filter := bson.M{"_id": series.ID}
-			update := bson.M{"$set": bson.M{
+			set := bson.M{
 				"imageCount": count,
 				"thumbnail":  thumbnail,
 				"updatedAt":  time.Now(),
-			}}
+			}
+			if source != nil {
+				set["source"] = source
+			}
+			update := bson.M{"$set": set}
 			model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update)
 			results <- model
 		}
@@ -0,0 +1,420 @@
+package scanner
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memStore 是只在内存中保存数据的 database.Store，实现了扫描流水线用到的方法。
+// 其余方法来自内嵌的 nil 接口，调用时会 panic，说明流水线用到了新的方法，需要在这里补上。
+type memStore struct {
+	database.Store
+	series     *memSeriesStore
+	images     *memImageStore
+	quarantine *memQuarantineStore
+	trash      *memTrashStore
+	runs       *memScanRunStore
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		series:     &memSeriesStore{coll: &memCollection{}},
+		images:     &memImageStore{coll: &memCollection{}},
+		quarantine: &memQuarantineStore{items: map[primitive.ObjectID]models.QuarantineItem{}},
+		trash:      &memTrashStore{items: map[primitive.ObjectID]models.TrashItem{}},
+		runs:       &memScanRunStore{},
+	}
+}
+
+func (s *memStore) Series() database.SeriesStore         { return s.series }
+func (s *memStore) Images() database.ImageStore          { return s.images }
+func (s *memStore) Quarantine() database.QuarantineStore { return s.quarantine }
+func (s *memStore) Trash() database.TrashStore           { return s.trash }
+func (s *memStore) ScanRuns() database.ScanRunStore      { return s.runs }
+
+// memCollection 以 BSON 文档保存一个集合，支持按字段相等过滤，以及带 $set/$setOnInsert/$unset 的 UpdateOne。
+// 文档经过一次 BSON 编解码，写入 null 的字段与 MongoDB 中一样以 nil 值存在。
+type memCollection struct {
+	mu   sync.Mutex
+	docs []bson.M
+}
+
+// toDoc 把 v 编解码为 bson.M，使过滤条件和文档中的值类型一致。
+func toDoc(v any) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+func matches(doc, filter bson.M) bool {
+	for key, want := range filter {
+		if got, ok := doc[key]; !ok || !reflect.DeepEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *memCollection) bulkWrite(writes []mongo.WriteModel) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range writes {
+		model, ok := w.(*mongo.UpdateOneModel)
+		if !ok {
+			return fmt.Errorf("memCollection 不支持 %T", w)
+		}
+		if err := c.updateOne(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memCollection) updateOne(model *mongo.UpdateOneModel) error {
+	filter, err := toDoc(model.Filter)
+	if err != nil {
+		return err
+	}
+	update, err := toDoc(model.Update)
+	if err != nil {
+		return err
+	}
+	for key := range update {
+		if key != "$set" && key != "$setOnInsert" && key != "$unset" {
+			return fmt.Errorf("memCollection 不支持更新操作符 %s", key)
+		}
+	}
+	set, _ := update["$set"].(bson.M)
+	unset, _ := update["$unset"].(bson.M)
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			for key, value := range set {
+				doc[key] = value
+			}
+			for key := range unset {
+				delete(doc, key)
+			}
+			return nil
+		}
+	}
+	if model.Upsert == nil || !*model.Upsert {
+		return nil
+	}
+	doc := bson.M{}
+	for key, value := range filter {
+		doc[key] = value
+	}
+	onInsert, _ := update["$setOnInsert"].(bson.M)
+	for _, fields := range []bson.M{onInsert, set} {
+		for key, value := range fields {
+			doc[key] = value
+		}
+	}
+	c.docs = append(c.docs, doc)
+	return nil
+}
+
+// find 返回满足 filter 的所有文档，解码到 out 指向的切片中。
+func (c *memCollection) find(filter bson.M, out any) error {
+	normalized, err := toDoc(filter)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	var found bson.A
+	for _, doc := range c.docs {
+		if matches(doc, normalized) {
+			found = append(found, doc)
+		}
+	}
+	c.mu.Unlock()
+	data, err := bson.Marshal(bson.M{"docs": found})
+	if err != nil {
+		return err
+	}
+	var wrapper struct {
+		Docs bson.RawValue `bson:"docs"`
+	}
+	if err := bson.Unmarshal(data, &wrapper); err != nil {
+		return err
+	}
+	if wrapper.Docs.Type == bson.TypeNull {
+		return nil
+	}
+	return wrapper.Docs.Unmarshal(out)
+}
+
+func (c *memCollection) delete(id primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, doc := range c.docs {
+		if doc["_id"] == id {
+			c.docs = append(c.docs[:i], c.docs[i+1:]...)
+			return
+		}
+	}
+}
+
+// rawDocs 返回集合中的原始文档，用于检查字段是否存在。
+func (c *memCollection) rawDocs() []bson.M {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]bson.M(nil), c.docs...)
+}
+
+type memSeriesStore struct {
+	database.SeriesStore
+	coll *memCollection
+}
+
+func (s *memSeriesStore) BulkWrite(ctx context.Context, writes []mongo.WriteModel) error {
+	return s.coll.bulkWrite(writes)
+}
+
+func (s *memSeriesStore) FindManyByNames(ctx context.Context, names []string) ([]models.Series, []string, error) {
+	var found []models.Series
+	var notFound []string
+	for _, name := range names {
+		series, err := s.GetByName(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if series == nil {
+			notFound = append(notFound, name)
+			continue
+		}
+		found = append(found, *series)
+	}
+	return found, notFound, nil
+}
+
+func (s *memSeriesStore) GetByName(ctx context.Context, name string) (*models.Series, error) {
+	var series []models.Series
+	if err := s.coll.find(bson.M{"name": name}, &series); err != nil || len(series) == 0 {
+		return nil, err
+	}
+	return &series[0], nil
+}
+
+// all 返回所有系列，按名称排序。
+func (s *memSeriesStore) all() ([]models.Series, error) {
+	var series []models.Series
+	err := s.coll.find(bson.M{}, &series)
+	sort.Slice(series, func(i, j int) bool { return series[i].Name < series[j].Name })
+	return series, err
+}
+
+type memImageStore struct {
+	database.ImageStore
+	coll *memCollection
+}
+
+func (s *memImageStore) BulkWrite(ctx context.Context, writes []mongo.WriteModel) error {
+	return s.coll.bulkWrite(writes)
+}
+
+// GetAllBySeriesID 与 MongoDB 的实现不同，结果按文件名排序。
+func (s *memImageStore) GetAllBySeriesID(ctx context.Context, seriesID primitive.ObjectID) ([]models.Image, error) {
+	var images []models.Image
+	err := s.coll.find(bson.M{"seriesId": seriesID}, &images)
+	sort.Slice(images, func(i, j int) bool { return images[i].FileName < images[j].FileName })
+	return images, err
+}
+
+func (s *memImageStore) CountBySeriesID(ctx context.Context, seriesID primitive.ObjectID) (int64, error) {
+	images, err := s.GetAllBySeriesID(ctx, seriesID)
+	return int64(len(images)), err
+}
+
+func (s *memImageStore) GetFirstImage(ctx context.Context, seriesID primitive.ObjectID) (*models.Image, error) {
+	images, err := s.GetAllBySeriesID(ctx, seriesID)
+	if err != nil || len(images) == 0 {
+		return nil, err
+	}
+	return &images[0], nil
+}
+
+func (s *memImageStore) GetFirstWithSource(ctx context.Context, seriesID primitive.ObjectID) (*models.Image, error) {
+	images, err := s.GetAllBySeriesID(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		if images[i].Source != nil {
+			return &images[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memImageStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.coll.delete(id)
+	return nil
+}
+
+type memQuarantineStore struct {
+	database.QuarantineStore
+	mu    sync.Mutex
+	items map[primitive.ObjectID]models.QuarantineItem
+}
+
+func (s *memQuarantineStore) Create(ctx context.Context, item *models.QuarantineItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = *item
+	return nil
+}
+
+func (s *memQuarantineStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.QuarantineItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (s *memQuarantineStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+func (s *memQuarantineStore) all() []models.QuarantineItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.QuarantineItem
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].OriginalPath < items[j].OriginalPath })
+	return items
+}
+
+type memTrashStore struct {
+	database.TrashStore
+	mu    sync.Mutex
+	items map[primitive.ObjectID]models.TrashItem
+}
+
+func (s *memTrashStore) Create(ctx context.Context, item *models.TrashItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[item.ID] = *item
+	return nil
+}
+
+func (s *memTrashStore) GetByID(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+func (s *memTrashStore) ListExpired(ctx context.Context, before time.Time) ([]models.TrashItem, error) {
+	var expired []models.TrashItem
+	for _, item := range s.all() {
+		if !item.ExpiresAt.IsZero() && item.ExpiresAt.Before(before) {
+			expired = append(expired, item)
+		}
+	}
+	return expired, nil
+}
+
+func (s *memTrashStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+func (s *memTrashStore) all() []models.TrashItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []models.TrashItem
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].OriginalPath < items[j].OriginalPath })
+	return items
+}
+
+type memScanRunStore struct {
+	mu   sync.Mutex
+	runs []models.ScanRun
+}
+
+func (s *memScanRunStore) Create(ctx context.Context, run *models.ScanRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	now := time.Now()
+	run.CreatedAt, run.UpdatedAt = now, now
+	s.runs = append(s.runs, *run)
+	return nil
+}
+
+func (s *memScanRunStore) FindUnfinished(ctx context.Context) (*models.ScanRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if s.runs[i].Stage != models.ScanStageCompleted {
+			run := s.runs[i]
+			return &run, nil
+		}
+	}
+	return nil, nil
+}
+
+// Update 与 MongoDB 的实现一样保存 IngestedSeries 以外的字段。
+func (s *memScanRunStore) Update(ctx context.Context, run *models.ScanRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.runs {
+		if s.runs[i].ID == run.ID {
+			updated := *run
+			updated.IngestedSeries = s.runs[i].IngestedSeries
+			updated.UpdatedAt = time.Now()
+			s.runs[i] = updated
+		}
+	}
+	return nil
+}
+
+func (s *memScanRunStore) AddIngestedSeries(ctx context.Context, id primitive.ObjectID, seriesPaths []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.runs {
+		if s.runs[i].ID == id {
+			s.runs[i].IngestedSeries = append(s.runs[i].IngestedSeries, seriesPaths...)
+		}
+	}
+	return nil
+}
+
+func (s *memScanRunStore) all() []models.ScanRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.ScanRun(nil), s.runs...)
+}
@@ -2,7 +2,14 @@ package scanner
 
 import (
 	"PICs_Manager/config"
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/archive"
 	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/layout"
+	"PICs_Manager/pkg/quarantine"
+	"PICs_Manager/pkg/trash"
 	"context"
 	"fmt"
 	"log"
@@ -13,15 +20,28 @@ import (
 type Orchestrator struct {
 	Preprocessor ImagePreprocessor
 	Classifier   SeriesClassifier
+	Archives     ArchiveStager
 	Ingestor     MetadataIngestor
 	Aggregator   LibraryAggregator
+
+	fs   fsutil.FS
+	runs database.ScanRunStore // 扫描运行的检查点
 }
 
-func NewOrchestrator(cfg *config.Config, dbStore database.Store) (*Orchestrator, error) {
-	log.Println("初始化扫描协调器 (Orchestrator)...")
+func NewOrchestrator(cfg *config.Config, lib config.LibraryConfig, dbStore database.Store) (*Orchestrator, error) {
+	return NewOrchestratorFS(fsutil.OS, cfg, lib, dbStore)
+}
+
+// NewOrchestratorFS 与 NewOrchestrator 相同，只是扫描目录、中转站、最终库、回收站和隔离区都通过 fsys 访问，
+// 传入 fsutil.NewMemFS() 可以不接触磁盘地运行整条流水线。日志目录总是在磁盘上。
+func NewOrchestratorFS(fsys fsutil.FS, cfg *config.Config, lib config.LibraryConfig, dbStore database.Store) (*Orchestrator, error) {
+	log.Printf("初始化库 %s 的扫描协调器 (Orchestrator)...", lib.Name)
+	if dbStore == nil {
+		return nil, fmt.Errorf("创建 Orchestrator 失败: 没有数据库存储")
+	}
 
 	// 1. 创建统一的日志目录
-	logDir, err := filepath.Abs(cfg.Logger.Path)
+	logDir, err := filepath.Abs(cfg.LogDir(lib.Name))
 	if err != nil {
 		return nil, fmt.Errorf("无法获取日志目录绝对路径: %w", err)
 	}
@@ -30,27 +50,67 @@ func NewOrchestrator(cfg *config.Config, dbStore database.Store) (*Orchestrator,
 	}
 	log.Printf("所有模块日志将存放在: %s", logDir)
 
-	os.RemoveAll(cfg.Scanner.StagingPath)
-	os.RemoveAll(cfg.Scanner.QuarantinePath)
+	if err := formats.SetAllowedExtensions(lib.Scanner.ImageExtensions); err != nil {
+		return nil, fmt.Errorf("无效的 imageExtensions 配置: %w", err)
+	}
+	if err := formats.SetAllowedVideoExtensions(lib.Scanner.VideoExtensions); err != nil {
+		return nil, fmt.Errorf("无效的 videoExtensions 配置: %w", err)
+	}
+	log.Printf("媒体扩展名允许列表: 图片 %v, 视频 %v", formats.Allowed(), formats.AllowedVideo())
+
+	// 中转站和隔离区都不在启动时清空：中转站有遗留说明上次扫描中断了，其中的文件已经离开扫描目录，
+	// 清空会直接丢失它们。这里只报告，由用户选择从中转站继续或退回扫描目录。
+	if staged, err := inspectStaging(fsys, lib.Scanner.StagingPath); err != nil {
+		log.Printf("警告：无法检查中转站: %v", err)
+	} else if !staged.Empty() {
+		log.Printf("警告：中转站 %s 中遗留了上次中断的扫描留下的 %d 个系列、%d 个文件 (%d 字节)。"+
+			"可以执行 staging-resume 继续归档入库，或执行 staging-return 退回扫描目录；下次扫描也会把它们一起归档。",
+			staged.Path, len(staged.Series), staged.Files, staged.Bytes)
+	}
 
 	// 2. 依次创建所有模块，并传入 logDir
 
-	preprocessor, err := NewPreprocessor(logDir, cfg.Scanner.WorkerCount)
+	trashManager, err := trash.NewManager(fsys, lib.Scanner.TrashPath, lib.Scanner.TrashRetention, dbStore.Trash())
 	if err != nil {
 		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
 	}
 
-	classifier, err := NewClassifier(logDir, cfg.Scanner.StagingPath, cfg.Scanner.FilePatterns, cfg.Scanner.WorkerCount)
+	preprocessor, err := NewPreprocessor(logDir, fsys, trashManager, lib.Scanner.WorkerCount)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
 	}
 
-	aggregator, err := NewAggregator(logDir, cfg.Scanner.SeriesGroupRules, cfg.Scanner.WorkerCount)
+	classifyChain, err := NewClassifyChain(fsys, lib.Scanner.Classifiers, lib.Scanner.FilePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 classifiers 配置: %w", err)
+	}
+
+	classifier, err := NewClassifier(logDir, fsys, lib.Scanner.StagingPath, classifyChain, lib.Scanner.WorkerCount)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
+	}
+
+	archives, err := NewArchiveStager(logDir, fsys, lib.Scanner.StagingPath)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
 	}
 
-	ingestor, err := NewIngestor(logDir, dbStore, cfg.Scanner.WorkerCount, cfg.Scanner.BatchSize)
+	quarantineManager, err := quarantine.NewManager(fsys, lib.Scanner.QuarantinePath, trashManager, dbStore.Quarantine())
+	if err != nil {
+		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
+	}
+
+	libraryLayout, err := layout.New(lib.Scanner.LibraryLayout)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 libraryLayout 配置: %w", err)
+	}
+
+	aggregator, err := NewAggregator(logDir, fsys, lib.Scanner.SeriesGroupRules, libraryLayout, quarantineManager, dbStore.Series(), lib.Scanner.WorkerCount)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
+	}
+
+	ingestor, err := NewIngestor(logDir, fsys, dbStore, quarantineManager, lib.Scanner.WorkerCount, lib.Scanner.BatchSize)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Orchestrator 失败: %w", err)
 	}
@@ -58,80 +118,246 @@ func NewOrchestrator(cfg *config.Config, dbStore database.Store) (*Orchestrator,
 	orchestrator := &Orchestrator{
 		Preprocessor: preprocessor,
 		Classifier:   classifier,
+		Archives:     archives,
 		Aggregator:   aggregator,
 		Ingestor:     ingestor,
+		fs:           fsys,
+		runs:         dbStore.ScanRuns(),
 	}
 
 	log.Println("扫描协调器初始化成功。")
 	return orchestrator, nil
 }
 
-func (o *Orchestrator) RunFullScan(cfg config.ScannerConfig) {
+// NewOrchestrators 为 cfg 中的每个库创建扫描协调器，stores 是每个库的 Store，以库名为键。
+// 任意一个库创建失败时关闭已创建的协调器并返回错误。
+func NewOrchestrators(cfg *config.Config, stores map[string]database.Store) (map[string]*Orchestrator, error) {
+	orchestrators := make(map[string]*Orchestrator)
+	for _, lib := range cfg.LibraryConfigs() {
+		o, err := NewOrchestrator(cfg, lib, stores[lib.Name])
+		if err != nil {
+			for _, created := range orchestrators {
+				created.Close()
+			}
+			return nil, fmt.Errorf("库 %s: %w", lib.Name, err)
+		}
+		orchestrators[lib.Name] = o
+	}
+	return orchestrators, nil
+}
+
+// Close 关闭所有模块的日志文件，用于重新加载配置时丢弃旧的协调器。
+func (o *Orchestrator) Close() {
+	o.Preprocessor.Close()
+	o.Classifier.Close()
+	o.Archives.Close()
+	o.Aggregator.Close()
+	o.Ingestor.Close()
+}
+
+// ScanReport 是一次完整扫描的摘要，作为任务结果返回给调用方。
+type ScanReport struct {
+	// RunID 是扫描运行记录的 ID，记录创建失败时为空。
+	RunID          string         `json:"runId,omitempty"`
+	ProcessedFiles int            `json:"processedFiles"`
+	Series         int            `json:"series"`
+	Classifiers    map[string]int `json:"classifiers"`
+	// Unclassified 是所有分类器都无法处理、仍留在扫描目录中的文件。
+	Unclassified []string `json:"unclassified"`
+	// ResumedFrom 是继续中断的运行时，该运行上次完成的阶段。
+	ResumedFrom models.ScanStage `json:"resumedFrom,omitempty"`
+	// Resumed 是本次扫描开始前先继续完成的上一次中断的运行。
+	Resumed *ScanReport `json:"resumed,omitempty"`
+}
+
+// RunFullScan 执行完整的扫描流水线。各阶段完成后在数据库中记录检查点，
+// 如果上一次运行中途中断，先从它最后完成的阶段 (或入库批次) 继续完成它，再开始本次扫描。
+// 某个阶段失败时返回已经得到的报告和错误，运行停在最后记录的检查点，下次扫描从那里继续。
+func (o *Orchestrator) RunFullScan(cfg config.ScannerConfig) (*ScanReport, error) {
 	log.Println("--- 任务开始：准备路径并启动扫描 ---")
 
 	absScanPath, err := filepath.Abs(cfg.ScanPath)
-	absBackupPath, _ := filepath.Abs(cfg.BackupPath)
 	if err != nil {
-		log.Fatalf("错误：无法获取扫描路径的绝对路径 '%s': %v", cfg.ScanPath, err)
+		return nil, fmt.Errorf("无法获取扫描路径的绝对路径 '%s': %w", cfg.ScanPath, err)
+	}
+	absBackupPath, err := filepath.Abs(cfg.BackupPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取备份路径的绝对路径 '%s': %w", cfg.BackupPath, err)
 	}
 	absStagingPath, err := filepath.Abs(cfg.StagingPath)
 	if err != nil {
-		log.Fatalf("错误：无法获取中转站路径的绝对路径 '%s': %v", cfg.StagingPath, err)
+		return nil, fmt.Errorf("无法获取中转站路径的绝对路径 '%s': %w", cfg.StagingPath, err)
 	}
 	absFinalLibraryPath, err := filepath.Abs(cfg.FinalLibraryPath)
 	if err != nil {
-		log.Fatalf("错误：无法获取最终库路径的绝对路径 '%s': %v", cfg.FinalLibraryPath, err)
+		return nil, fmt.Errorf("无法获取最终库路径的绝对路径 '%s': %w", cfg.FinalLibraryPath, err)
 	}
-
 	absQuarantinePath, err := filepath.Abs(cfg.QuarantinePath)
 	if err != nil {
-		log.Fatalf("错误: 无法获取隔离区路径的绝对路径 '%s': %v", cfg.QuarantinePath, err)
+		return nil, fmt.Errorf("无法获取隔离区路径的绝对路径 '%s': %w", cfg.QuarantinePath, err)
 	}
 
 	for _, path := range []string{absStagingPath, absFinalLibraryPath, absBackupPath, absQuarantinePath} {
-		if err := os.MkdirAll(path, 0755); err != nil {
-			log.Fatalf("错误: 无法创建目录 %s: %v", path, err)
+		if err := o.fs.MkdirAll(path, 0755); err != nil {
+			return nil, fmt.Errorf("无法创建目录 %s: %w", path, err)
 		}
 	}
 
 	defer o.Preprocessor.Close()
 	defer o.Classifier.Close()
+	defer o.Archives.Close()
 	defer o.Aggregator.Close()
 	defer o.Ingestor.Close()
 
-	log.Printf("--- 阶段 1/4: 预处理 ---")
-	healthyFiles, err := o.Preprocessor.ProcessDirectory(absScanPath)
-	if err != nil {
-		log.Fatalf("预处理阶段发生致命错误: %v", err)
+	ctx := context.Background()
+	var resumed *ScanReport
+	if tracker := o.resumeRun(ctx); tracker != nil {
+		if tracker.run.Stage == models.ScanStageStarted {
+			// 分类中途中断时已移动的文件都在中转站中，会随本次扫描一起归档，不需要单独继续
+			log.Printf("上一次扫描 %s 在分类阶段中断，中转站中的内容将随本次扫描一起归档", tracker.run.ScanPath)
+			tracker.complete(ctx)
+		} else {
+			log.Printf("上一次扫描 %s 在阶段 %s 之后中断，先完成它", tracker.run.ScanPath, tracker.run.Stage)
+			resumed, err = o.runStages(ctx, tracker, absStagingPath, absFinalLibraryPath)
+			if err != nil {
+				// 仍未完成时不开始新的运行，否则下次只会继续新的运行，这一次的检查点就被遗忘了
+				log.Printf("上一次中断的扫描运行仍未完成，本次不开始新的扫描，请检查日志后重试")
+				return &ScanReport{Classifiers: map[string]int{}, Unclassified: []string{}, Resumed: resumed},
+					fmt.Errorf("继续上一次中断的扫描运行失败: %w", err)
+			}
+		}
 	}
-	if len(healthyFiles) == 0 {
-		log.Println("没有找到可处理的新文件，任务结束。")
-		return
+
+	report, err := o.runStages(ctx, o.startRun(ctx, absScanPath), absStagingPath, absFinalLibraryPath)
+	report.Resumed = resumed
+	return report, err
+}
+
+// runStages 从运行已完成的阶段之后开始执行剩余的阶段，每完成一个阶段记录一次检查点。
+// 出错时返回的报告包含出错前的结果，运行不标记为完成。
+func (o *Orchestrator) runStages(ctx context.Context, t *runTracker, absStagingPath, absFinalLibraryPath string) (*ScanReport, error) {
+	run := t.run
+	report := &ScanReport{RunID: t.id(), Classifiers: map[string]int{}, Unclassified: []string{}}
+	if run.Stage != models.ScanStageStarted {
+		report.ResumedFrom = run.Stage
+		log.Printf("--- 继续运行 %s：阶段 %s 已完成，从检查点恢复 ---", report.RunID, run.Stage)
 	}
 
-	log.Printf("--- 阶段 2/4: 分类到中转站 ---")
-	createdSeries, processedFileNames, err := o.Classifier.ClassifyAndMove(healthyFiles)
-	if err != nil {
-		log.Printf("分类和移动阶段出现错误: %v", err)
+	if run.Stage == models.ScanStageStarted {
+		leftover := 0
+		if staged, err := inspectStaging(o.fs, absStagingPath); err == nil && len(staged.Series) > 0 {
+			leftover = len(staged.Series)
+			log.Printf("中转站中有上次遗留的 %d 个系列，将与本次扫描的内容一起归档", leftover)
+		}
+
+		log.Printf("--- 阶段 1/4: 预处理 ---")
+		healthyFiles, err := o.Preprocessor.ProcessDirectory(run.ScanPath)
+		if err != nil {
+			return report, fmt.Errorf("预处理失败: %w", err)
+		}
+
+		log.Printf("--- 阶段 2/4: 分类到中转站 ---")
+		// 压缩包不经过预处理和正则分类，每个压缩包直接作为一个系列放入中转站
+		archiveSeries, err := o.Archives.StageArchives(run.ScanPath)
+		if err != nil {
+			log.Printf("处理压缩包时出现错误: %v", err)
+		}
+		// 压缩包只由 ArchiveStager 处理，无法打开的压缩包留在扫描目录中，也不交给分类器
+		mediaFiles := healthyFiles[:0]
+		for _, path := range healthyFiles {
+			if !archive.IsArchiveExtension(path) {
+				mediaFiles = append(mediaFiles, path)
+			}
+		}
+		healthyFiles = withoutSidecars(o.fs, mediaFiles)
+		if len(healthyFiles) == 0 && len(archiveSeries) == 0 && leftover == 0 {
+			log.Println("没有找到可处理的新文件，任务结束。")
+			t.complete(ctx)
+			return report, nil
+		}
+		var createdSeries, processedFileNames []string
+		classified, err := o.Classifier.ClassifyAndMove(run.ScanPath, healthyFiles)
+		if err != nil {
+			log.Printf("分类和移动阶段出现错误: %v", err)
+		}
+		if classified != nil {
+			createdSeries, processedFileNames = classified.SeriesNames, classified.FileNames
+			run.Classifiers = classified.ByClassifier
+			run.Unclassified = classified.Unclassified
+		}
+		run.SeriesNames = append(createdSeries, archiveSeries...)
+		run.ProcessedFiles = len(processedFileNames)
+		t.save(ctx, models.ScanStageClassified)
+	}
+	report.ProcessedFiles, report.Series = run.ProcessedFiles, len(run.SeriesNames)
+	if run.Classifiers != nil {
+		report.Classifiers = run.Classifiers
+	}
+	if run.Unclassified != nil {
+		report.Unclassified = run.Unclassified
+	}
+	log.Printf("--- 分类阶段完毕，处理了 %d 个文件，涉及 %d 个系列 ---", report.ProcessedFiles, report.Series)
+	if len(report.Unclassified) > 0 {
+		log.Printf("警告：%d 个文件无法分类，仍留在扫描目录中，详情请查看 classifier.log 或任务结果", len(report.Unclassified))
 	}
-	log.Printf("--- 分类阶段完毕，处理了 %d 个文件，涉及 %d 个系列 ---", len(processedFileNames), len(createdSeries))
 
-	log.Printf("--- 阶段 3/4: 聚合与归档 ---")
-	changelog, err := o.Aggregator.AggregateAndArchive(absStagingPath, absFinalLibraryPath)
-	if err != nil {
-		log.Printf("执行聚合归档步骤时出错: %v", err)
+	var changelog map[string]string
+	if run.Stage == models.ScanStageClassified {
+		log.Printf("--- 阶段 3/4: 聚合与归档 ---")
+		var err error
+		changelog, err = o.Aggregator.AggregateAndArchive(absStagingPath, absFinalLibraryPath)
+		if err != nil {
+			// 不记录检查点，下次扫描时重新执行聚合归档
+			return report, fmt.Errorf("聚合归档失败: %w", err)
+		}
+		if report.ResumedFrom == models.ScanStageClassified {
+			// 上次可能在聚合归档中途中断，已经移入最终库的系列不在这次的 changelog 中
+			for path, dest := range o.Aggregator.LocateSeries(absFinalLibraryPath, run.SeriesNames) {
+				if _, ok := changelog[path]; !ok {
+					changelog[path] = dest
+				}
+			}
+		}
+		run.Changelog = make([]models.PathChange, 0, len(changelog))
+		for from, to := range changelog {
+			run.Changelog = append(run.Changelog, models.PathChange{From: from, To: to})
+		}
+		t.save(ctx, models.ScanStageArchived)
+	} else {
+		changelog = make(map[string]string, len(run.Changelog))
+		for _, c := range run.Changelog {
+			changelog[c.From] = c.To
+		}
 	}
 	log.Printf("--- 归档阶段完毕，生成变更日志，共 %d 项变更 ---", len(changelog))
 
 	log.Println("--- 阶段 4/4: 数据库同步 ---")
-	overwritten, err := o.Ingestor.Sync(context.Background(), absFinalLibraryPath, createdSeries, processedFileNames, changelog)
+	overwritten, err := o.Ingestor.Sync(ctx, absFinalLibraryPath, run.SeriesNames, nil, changelog, t.checkpoint())
 	if err != nil {
-		log.Printf("数据库同步时出错: %v", err)
+		// 不标记完成，下次扫描时从已记录的入库批次继续
+		return report, fmt.Errorf("数据库同步失败: %w", err)
 	}
 	if len(overwritten) > 0 {
 		log.Printf("警告：在操作过程中，检测到 %d 个文件可能被覆盖，详情请查看 ingestor.log", len(overwritten))
 
 	}
 
+	t.complete(ctx)
 	log.Println("🎉 全库扫描任务完成。")
+	return report, nil
+}
+
+// SyncSeriesPaths 只对指定的库内系列文件夹重新入库，不经过预处理、分类和聚合。
+// 用于隔离区冲突解决等在扫描流程之外改动了库内容的操作。
+func (o *Orchestrator) SyncSeriesPaths(ctx context.Context, finalLibraryPath string, seriesPaths []string) error {
+	absFinalLibraryPath, err := filepath.Abs(finalLibraryPath)
+	if err != nil {
+		return fmt.Errorf("无法获取最终库路径的绝对路径 '%s': %w", finalLibraryPath, err)
+	}
+	changelog := make(map[string]string, len(seriesPaths))
+	for _, p := range seriesPaths {
+		changelog[p] = p
+	}
+	_, err = o.Ingestor.Sync(ctx, absFinalLibraryPath, nil, nil, changelog, nil)
+	return err
 }
@@ -0,0 +1,544 @@
+package scanner
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/fsutil"
+	"PICs_Manager/pkg/trash"
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 与 config.yaml 中默认的 filePatterns 相同
+var testFilePatterns = []string{
+	`^(.*?)_(\d+)_p(\d+)_(\d+)(\.[a-zA-Z0-9_]+)?$`,
+	`^(.*?)_(\d+)_p(\d+)(\.[a-zA-Z0-9_]+)?$`,
+	`^(.*?)_(\d+)(\.[a-zA-Z0-9_]+)?$`,
+}
+
+func TestRunFullScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		// classifiers 为空时只使用基于 testFilePatterns 的 regex 分类器
+		classifiers []config.ClassifierRule
+		groupRules  []config.SeriesGroupRule
+		// files 是扫描前文件系统中的文件，路径相对于文件系统根目录
+		files map[string][]byte
+		// want 是扫描后文件系统中回收站和隔离区以外的全部文件，为 nil 时由 check 检查
+		want             []string
+		wantProcessed    int
+		wantSeries       int
+		wantUnclassified []string
+		// wantTrash 和 wantQuarantine 是移入回收站和隔离区的条目的原始路径
+		wantTrash      []string
+		wantQuarantine []string
+		// check 对文件内容和记录做额外检查，可以为 nil
+		check func(t *testing.T, fsys fsutil.FS, store *memStore)
+	}{
+		{
+			name:   "regex series archived under first-letter buckets",
+			layout: "first-letter",
+			files: map[string][]byte{
+				"scan/artist_123_p0.png": testPNG(1),
+				"scan/artist_123_p1.png": testPNG(2),
+				"scan/sub/other_456.png": testPNG(3),
+			},
+			want: []string{
+				"library/A/artist/artist_123_p0.png",
+				"library/A/artist/artist_123_p1.png",
+				"library/O/other/other_456.png",
+			},
+			wantProcessed: 3,
+			wantSeries:    2,
+		},
+		{
+			name:   "identical numbered copy is discarded",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/dup_1.png":     testPNG(1),
+				"scan/dup_1 (1).png": testPNG(1),
+			},
+			want:          []string{"library/dup/dup_1.png"},
+			wantProcessed: 1,
+			wantSeries:    1,
+			wantTrash:     []string{"scan/dup_1 (1).png"},
+		},
+		{
+			name:   "damaged file is replaced by a healthy copy",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/broken_1.png":     []byte("not a png"),
+				"scan/broken_1 (1).png": testPNG(5),
+			},
+			want:          []string{"library/broken/broken_1.png"},
+			wantProcessed: 1,
+			wantSeries:    1,
+			wantTrash:     []string{"scan/broken_1.png"},
+			check: func(t *testing.T, fsys fsutil.FS, store *memStore) {
+				data, err := fsutil.ReadFile(fsys, testPath("library/broken/broken_1.png"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, testPNG(5)) {
+					t.Errorf("broken_1.png was not replaced by the healthy copy")
+				}
+			},
+		},
+		{
+			name:   "unmatched files stay in the scan directory",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/keep_1.png":     testPNG(1),
+				"scan/keep_1 (1).png": testPNG(2),
+				"scan/random.png":     testPNG(3),
+			},
+			want: []string{
+				"library/keep/keep_1.png",
+				"scan/keep_1 (1).png",
+				"scan/random.png",
+			},
+			wantProcessed:    1,
+			wantSeries:       1,
+			wantUnclassified: []string{"scan/keep_1 (1).png", "scan/random.png"},
+		},
+		{
+			name:        "classifier chain falls through to unsorted",
+			layout:      "flat",
+			classifiers: []config.ClassifierRule{{Type: ClassifyParentFolder}, {Type: ClassifyUnsorted}},
+			files: map[string][]byte{
+				"scan/Trip/beach.png": testPNG(1),
+				"scan/loose.png":      testPNG(2),
+			},
+			want: []string{
+				"library/Trip/beach.png",
+				"library/_unsorted/loose.png",
+			},
+			wantProcessed: 2,
+			wantSeries:    2,
+		},
+		{
+			name:        "sidecars move with their media instead of being classified",
+			layout:      "flat",
+			classifiers: []config.ClassifierRule{{Type: ClassifyRegex}, {Type: ClassifyUnsorted}},
+			files: map[string][]byte{
+				"scan/art_1.png":        testPNG(1),
+				"scan/art_1.json":       []byte(`{"user": {"name": "Art"}}`),
+				"scan/loose.png":        testPNG(2),
+				"scan/loose.png.json":   []byte(`{"title": "Loose"}`),
+				"scan/Trip/beach.png":   testPNG(3),
+				"scan/Trip/beach.json":  []byte(`{}`),
+				"scan/Trip/orphan.json": []byte(`{}`),
+			},
+			want: []string{
+				"library/_unsorted/beach.json",
+				"library/_unsorted/beach.png",
+				"library/_unsorted/loose.png",
+				"library/_unsorted/loose.png.json",
+				"library/_unsorted/orphan.json",
+				"library/art/art_1.json",
+				"library/art/art_1.png",
+			},
+			wantProcessed: 4,
+			wantSeries:    2,
+		},
+		{
+			name:   "sidecars moved with their media are not reported as unclassified",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/art_1.png":      testPNG(1),
+				"scan/art_1.png.json": []byte(`{}`),
+				"scan/random.png":     testPNG(2),
+				"scan/random.json":    []byte(`{}`),
+			},
+			want: []string{
+				"library/art/art_1.png",
+				"library/art/art_1.png.json",
+				"scan/random.json",
+				"scan/random.png",
+			},
+			wantProcessed:    1,
+			wantSeries:       1,
+			wantUnclassified: []string{"scan/random.png"},
+		},
+		{
+			name:        "matching series are aggregated",
+			layout:      "flat",
+			classifiers: []config.ClassifierRule{{Type: ClassifyParentFolder}},
+			groupRules:  []config.SeriesGroupRule{{Name: "vol", Pattern: `^(?P<group>.*?) \d+$`}},
+			files: map[string][]byte{
+				"scan/Saga 1/a.png": testPNG(1),
+				"scan/Saga 2/b.png": testPNG(2),
+				"scan/Solo/c.png":   testPNG(3),
+			},
+			want: []string{
+				"library/Saga_agg/Saga 1/a.png",
+				"library/Saga_agg/Saga 2/b.png",
+				"library/Solo/c.png",
+			},
+			wantProcessed: 3,
+			wantSeries:    3,
+		},
+		{
+			name:   "archives are staged as their own series",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/Comic.cbz": testZip(t, map[string][]byte{"001.png": testPNG(1), "002.png": testPNG(2)}),
+				"scan/empty.zip": testZip(t, map[string][]byte{"readme.txt": []byte("no images")}),
+				"scan/pic_1.png": testPNG(3),
+			},
+			want: []string{
+				"library/Comic/Comic.cbz",
+				"library/pic/pic_1.png",
+				"scan/empty.zip",
+			},
+			wantProcessed: 1,
+			wantSeries:    2,
+		},
+		{
+			name:   "series colliding with the library is quarantined",
+			layout: "first-letter",
+			files: map[string][]byte{
+				"library/D/dup/dup_1.png": testPNG(1),
+				"scan/dup_2.png":          testPNG(2),
+			},
+			want:           []string{"library/D/dup/dup_1.png"},
+			wantProcessed:  1,
+			wantSeries:     1,
+			wantQuarantine: []string{"staging/dup"},
+			check: func(t *testing.T, fsys fsutil.FS, store *memStore) {
+				item := store.quarantine.all()[0]
+				if item.Kind != models.QuarantineCollision || item.TargetPath != testPath("library/D/dup") {
+					t.Errorf("quarantine item = %+v, want a collision with library/D/dup", item)
+				}
+				if !fsutil.Exists(fsys, filepath.Join(item.QuarantinedPath, "dup_2.png")) {
+					t.Errorf("colliding series is not in the quarantine directory")
+				}
+			},
+		},
+		{
+			name:   "undecodable file is quarantined with its sidecar",
+			layout: "flat",
+			files: map[string][]byte{
+				"scan/bad_1.png":  []byte("not a png"),
+				"scan/bad_1.json": []byte(`{"title": "Bad"}`),
+				"scan/bad_2.png":  testPNG(1),
+			},
+			want:           []string{"library/bad/bad_2.png"},
+			wantProcessed:  2,
+			wantSeries:     1,
+			wantQuarantine: []string{"library/bad/bad_1.png"},
+			check: func(t *testing.T, fsys fsutil.FS, store *memStore) {
+				item := store.quarantine.all()[0]
+				if item.Kind != models.QuarantineUndecodable || item.SourceSidecar != testPath("library/bad/bad_1.json") {
+					t.Errorf("quarantine item = %+v, want an undecodable file with its sidecar", item)
+				}
+				if !fsutil.Exists(fsys, item.QuarantinedPath+".source.json") {
+					t.Errorf("sidecar was not moved into the quarantine directory")
+				}
+			},
+		},
+		{
+			name:   "leftover staging content is archived without new files",
+			layout: "flat",
+			files: map[string][]byte{
+				"staging/Old/old.png": testPNG(1),
+			},
+			want: []string{"library/Old/old.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fsutil.NewMemFS()
+			for name, data := range tt.files {
+				path := testPath(name)
+				if err := fsys.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := fsutil.WriteFile(fsys, path, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := fsys.MkdirAll(testPath("scan"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &config.Config{}
+			cfg.Logger.Path = t.TempDir()
+			lib := config.LibraryConfig{Name: "test", Scanner: config.ScannerConfig{
+				ScanPath:         testPath("scan"),
+				StagingPath:      testPath("staging"),
+				FinalLibraryPath: testPath("library"),
+				BackupPath:       testPath("backup"),
+				QuarantinePath:   testPath("quarantine"),
+				TrashPath:        testPath("trash"),
+				LibraryLayout:    tt.layout,
+				WorkerCount:      2,
+				FilePatterns:     testFilePatterns,
+				Classifiers:      tt.classifiers,
+				SeriesGroupRules: tt.groupRules,
+			}}
+			store := newMemStore()
+			o, err := NewOrchestratorFS(fsys, cfg, lib, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := o.RunFullScan(lib.Scanner)
+			if err != nil {
+				t.Fatalf("RunFullScan: %v", err)
+			}
+
+			var got []string
+			for _, name := range testFiles(t, fsys) {
+				if !strings.HasPrefix(name, "trash/") && !strings.HasPrefix(name, "quarantine/") {
+					got = append(got, name)
+				}
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files after scan:\n got  %q\n want %q", got, tt.want)
+			}
+			var trashed, isolated []string
+			for _, item := range store.trash.all() {
+				trashed = append(trashed, testRel(item.OriginalPath))
+				if !fsutil.Exists(fsys, item.TrashedPath) || !strings.HasPrefix(item.TrashedPath, testPath("trash")) {
+					t.Errorf("trash item %s is not in the trash directory: %s", item.OriginalPath, item.TrashedPath)
+				}
+			}
+			for _, item := range store.quarantine.all() {
+				isolated = append(isolated, testRel(item.OriginalPath))
+				if !fsutil.Exists(fsys, item.QuarantinedPath) || !strings.HasPrefix(item.QuarantinedPath, testPath("quarantine")) {
+					t.Errorf("quarantine item %s is not in the quarantine directory: %s", item.OriginalPath, item.QuarantinedPath)
+				}
+			}
+			if !reflect.DeepEqual(trashed, tt.wantTrash) {
+				t.Errorf("trashed = %q, want %q", trashed, tt.wantTrash)
+			}
+			if !reflect.DeepEqual(isolated, tt.wantQuarantine) {
+				t.Errorf("quarantined = %q, want %q", isolated, tt.wantQuarantine)
+			}
+			if report.ProcessedFiles != tt.wantProcessed {
+				t.Errorf("ProcessedFiles = %d, want %d", report.ProcessedFiles, tt.wantProcessed)
+			}
+			if report.Series != tt.wantSeries {
+				t.Errorf("Series = %d, want %d", report.Series, tt.wantSeries)
+			}
+			wantUnclassified := make([]string, 0, len(tt.wantUnclassified))
+			for _, name := range tt.wantUnclassified {
+				wantUnclassified = append(wantUnclassified, testPath(name))
+			}
+			if !reflect.DeepEqual(report.Unclassified, wantUnclassified) {
+				t.Errorf("Unclassified = %q, want %q", report.Unclassified, wantUnclassified)
+			}
+			if tt.check != nil {
+				tt.check(t, fsys, store)
+			}
+		})
+	}
+}
+
+// TestRunFullScanWithStore 在 MemFS 上运行整条流水线并写入 memStore：
+// 检查入库的系列和图片、冗余副本移入回收站、冲突文件夹的隔离记录以及扫描运行的检查点。
+func TestRunFullScanWithStore(t *testing.T) {
+	fsys := fsutil.NewMemFS()
+	path := testPath
+	for name, data := range map[string][]byte{
+		"scan/artist_123_p0.png":      testPNG(1),
+		"scan/artist_123_p1.png":      testPNG(2),
+		"scan/dup_1.png":              testPNG(3),
+		"scan/dup_1 (1).png":          testPNG(3),
+		"scan/other_2.png":            testPNG(4),
+		"library/O/other/other_1.png": testPNG(5),
+	} {
+		if err := fsys.MkdirAll(filepath.Dir(path(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsutil.WriteFile(fsys, path(name), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{}
+	cfg.Logger.Path = t.TempDir()
+	lib := config.LibraryConfig{Name: "test", Scanner: config.ScannerConfig{
+		ScanPath:         path("scan"),
+		StagingPath:      path("staging"),
+		FinalLibraryPath: path("library"),
+		BackupPath:       path("backup"),
+		QuarantinePath:   path("quarantine"),
+		TrashPath:        path("trash"),
+		LibraryLayout:    "first-letter",
+		WorkerCount:      2,
+		BatchSize:        1,
+		FilePatterns:     testFilePatterns,
+	}}
+	store := newMemStore()
+	o, err := NewOrchestratorFS(fsys, cfg, lib, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := o.RunFullScan(lib.Scanner)
+	if err != nil {
+		t.Fatalf("RunFullScan: %v", err)
+	}
+	if report.ProcessedFiles != 4 || report.RunID == "" {
+		t.Errorf("report = %+v, want 4 processed files and a run ID", report)
+	}
+
+	// 系列和图片
+	series, err := store.series.all()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantImages := map[string][]string{
+		"artist": {"artist_123_p0.png", "artist_123_p1.png"},
+		"dup":    {"dup_1.png"},
+	}
+	if len(series) != len(wantImages) {
+		t.Fatalf("ingested %d series, want %d: %+v", len(series), len(wantImages), series)
+	}
+	var seriesPaths []string
+	for _, s := range series {
+		want := wantImages[s.Name]
+		wantPath := path("library/" + strings.ToUpper(s.Name[:1]) + "/" + s.Name)
+		if s.Path != wantPath || s.SortName != s.Name || s.ImageCount != len(want) || s.Thumbnail == "" {
+			t.Errorf("series %q = {Path: %q, SortName: %q, ImageCount: %d, thumbnail: %t}, want path %q and %d images",
+				s.Name, s.Path, s.SortName, s.ImageCount, s.Thumbnail != "", wantPath, len(want))
+		}
+		seriesPaths = append(seriesPaths, s.Path)
+
+		images, err := store.images.GetAllBySeriesID(context.Background(), s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, img := range images {
+			names = append(names, img.FileName)
+			if img.FilePath != filepath.Join(s.Path, img.FileName) || img.FileHash == "" || img.PerceptualHash == "" ||
+				img.Width != 2 || img.Height != 2 || img.MediaType != models.MediaTypeImage {
+				t.Errorf("image %s was not ingested with its metadata: %+v", img.FileName, img)
+			}
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("images of %q = %q, want %q", s.Name, names, want)
+		}
+	}
+
+	// 测试图片没有 EXIF 和下载器元数据，这些字段不应以 null 写入
+	for _, doc := range store.images.coll.rawDocs() {
+		for _, key := range []string{"exif", "source"} {
+			if value, ok := doc[key]; ok {
+				t.Errorf("image %v has %s = %v, want the field absent", doc["fileName"], key, value)
+			}
+		}
+	}
+
+	// 冗余副本移入回收站
+	trashed := store.trash.all()
+	if len(trashed) != 1 || trashed[0].OriginalPath != path("scan/dup_1 (1).png") || trashed[0].Source != trash.SourcePreprocess {
+		t.Fatalf("trash items = %+v, want the numbered copy discarded by the preprocessor", trashed)
+	}
+	if data, err := fsutil.ReadFile(fsys, trashed[0].TrashedPath); err != nil || !bytes.Equal(data, testPNG(3)) {
+		t.Errorf("trashed copy is not in the trash directory: %v", err)
+	}
+
+	// 与库中已有文件夹冲突的系列被隔离并记录
+	isolated := store.quarantine.all()
+	if len(isolated) != 1 || isolated[0].Kind != models.QuarantineCollision || isolated[0].TargetPath != path("library/O/other") {
+		t.Fatalf("quarantine items = %+v, want one collision with library/O/other", isolated)
+	}
+	if !fsutil.Exists(fsys, filepath.Join(isolated[0].QuarantinedPath, "other_2.png")) {
+		t.Errorf("colliding series is not in the quarantine directory")
+	}
+	if !fsutil.Exists(fsys, path("library/O/other/other_1.png")) {
+		t.Errorf("existing library folder was modified")
+	}
+
+	// 扫描运行的检查点
+	runs := store.runs.all()
+	if len(runs) != 1 || runs[0].ID.Hex() != report.RunID || runs[0].Stage != models.ScanStageCompleted || runs[0].CompletedAt == nil {
+		t.Fatalf("scan runs = %+v, want one completed run", runs)
+	}
+	ingested := append([]string(nil), runs[0].IngestedSeries...)
+	sort.Strings(ingested)
+	if !reflect.DeepEqual(ingested, seriesPaths) {
+		t.Errorf("IngestedSeries = %q, want %q", ingested, seriesPaths)
+	}
+}
+
+// testPath 把相对于文件系统根目录的 slash 路径转换为 RunFullScan 使用的绝对路径。
+func testPath(name string) string {
+	root, _ := filepath.Abs(string(filepath.Separator))
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// testRel 是 testPath 的逆操作。
+func testRel(path string) string {
+	rel, _ := filepath.Rel(testPath(""), path)
+	return filepath.ToSlash(rel)
+}
+
+// testFiles 返回 fsys 中所有文件相对于根目录的 slash 路径，按字典序排列。
+func testFiles(t *testing.T, fsys fsutil.FS) []string {
+	t.Helper()
+	root := testPath("")
+	var files []string
+	err := fsutil.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// testPNG 返回一张可以解码的小图片，seed 不同时内容不同。
+func testPNG(seed uint8) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: seed, A: 255})
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func testZip(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(entries[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
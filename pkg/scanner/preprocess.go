@@ -1,12 +1,15 @@
 package scanner
 
 import (
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
 	"PICs_Manager/pkg/hasher"
+	"PICs_Manager/pkg/trash"
+	"PICs_Manager/pkg/videometa"
+	"context"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
 	"log"
 	"os"
 	"path/filepath"
@@ -36,12 +39,15 @@ type ImagePreprocessor interface {
 
 type defaultPreprocessor struct {
 	numWorkers int
+	fs         fsutil.FS
+	trash      trash.Manager
 	logger     *log.Logger
 	logFile    *os.File
 }
 
 // NewPreprocessor 构造函数不变
-func NewPreprocessor(logDir string, workerCount int) (ImagePreprocessor, error) {
+// 扫描目录通过 fsys 访问，冗余副本和被替换的损坏文件移入 trashManager。
+func NewPreprocessor(logDir string, fsys fsutil.FS, trashManager trash.Manager, workerCount int) (ImagePreprocessor, error) {
 	logFilePath := filepath.Join(logDir, preprocessLogFileName)
 	file, err := os.OpenFile(logFilePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
@@ -52,7 +58,7 @@ func NewPreprocessor(logDir string, workerCount int) (ImagePreprocessor, error)
 		workerCount = runtime.NumCPU()
 	}
 	logger.Printf("预处理器初始化成功，并发数: %d", workerCount)
-	return &defaultPreprocessor{numWorkers: workerCount, logger: logger, logFile: file}, nil
+	return &defaultPreprocessor{numWorkers: workerCount, fs: fsys, trash: trashManager, logger: logger, logFile: file}, nil
 }
 
 // Close 方法不变
@@ -89,7 +95,7 @@ func (p *defaultPreprocessor) ProcessDirectory(rootDir string) ([]string, error)
 	}
 
 	var finalFiles []string
-	err = filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
+	err = fsutil.WalkDir(p.fs, rootDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -110,11 +116,11 @@ func (p *defaultPreprocessor) ProcessDirectory(rootDir string) ([]string, error)
 func (p *defaultPreprocessor) scanAndGroupFiles(rootDir string) (map[string]*fileGroup, error) {
 	groups := make(map[string]*fileGroup)
 	re := regexp.MustCompile(`^(.*?)(?: \((\d+)\))?(\.\w+)$`)
-	err := filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
+	err := fsutil.WalkDir(p.fs, rootDir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if d.IsDir() || !isImageExtension(path) {
+		if d.IsDir() || !formats.IsMediaExtension(path) {
 			return nil
 		}
 		fileName := d.Name()
@@ -152,26 +158,28 @@ func (p *defaultPreprocessor) reconciliationWorker(wg *sync.WaitGroup, tasks <-c
 			continue
 		}
 
-		if isImageFileDamaged(group.basePath) {
+		if isMediaFileDamaged(p.fs, group.basePath) {
 			// 场景A：基础文件损坏，调用专门的修复函数
 			p.findAndExecuteRepair(group)
 		} else {
 			// 场景B：基础文件健康，执行去重逻辑
 			p.logger.Printf("去重模式: 基础文件 '%s' 健康。", filepath.Base(group.basePath))
-			baseHash, err := hasher.CalculateSHA256(group.basePath)
+			baseHash, err := hasher.CalculateSHA256FS(p.fs, group.basePath)
 			if err != nil {
 				p.logger.Printf("错误: 计算基础文件哈希失败: %v", err)
 				continue
 			}
 			for _, numberedPath := range group.numberedFiles {
-				numberedHash, err := hasher.CalculateSHA256(numberedPath)
+				numberedHash, err := hasher.CalculateSHA256FS(p.fs, numberedPath)
 				if err != nil {
 					p.logger.Printf("警告: 计算副本 '%s' 哈希失败: %v", filepath.Base(numberedPath), err)
 					continue
 				}
 				if baseHash == numberedHash {
 					p.logger.Printf("  -> 内容哈希相同，删除冗余副本 '%s'", filepath.Base(numberedPath))
-					os.Remove(numberedPath)
+					if err := p.discard(numberedPath, "与 "+filepath.Base(group.basePath)+" 内容相同的冗余副本"); err != nil {
+						p.logger.Printf("错误: 删除冗余副本失败: %v", err)
+					}
 				} else {
 					p.logger.Printf("  -> 内容哈希不同，保留独立文件 '%s'", filepath.Base(numberedPath))
 				}
@@ -209,13 +217,13 @@ func (p *defaultPreprocessor) findAndExecuteRepair(group *fileGroup) {
 		}
 
 		// 检查候选文件是否健康
-		if !isImageFileDamaged(candidatePath) {
+		if !isMediaFileDamaged(p.fs, candidatePath) {
 			p.logger.Printf("  -> 找到健康副本 '%s'，执行修复...", candidateName)
-			if err := os.Remove(group.basePath); err != nil && !os.IsNotExist(err) {
+			if err := p.discard(group.basePath, "已被健康副本 "+candidateName+" 替换的损坏文件"); err != nil && !errors.Is(err, os.ErrNotExist) {
 				p.logger.Printf("错误: 删除损坏的基础文件失败: %v", err)
 				return
 			}
-			if err := os.Rename(candidatePath, group.basePath); err != nil {
+			if err := p.fs.Rename(candidatePath, group.basePath); err != nil {
 				p.logger.Printf("错误: 重命名修复文件失败: %v", err)
 				return
 			}
@@ -228,24 +236,24 @@ func (p *defaultPreprocessor) findAndExecuteRepair(group *fileGroup) {
 	p.logger.Printf("  -> 未能为 '%s' 找到任何健康的修复副本。", filepath.Base(group.basePath))
 }
 
-// isImageFileDamaged 是一个不带 receiver 的辅助函数版本
-func isImageFileDamaged(path string) bool {
-	file, err := os.Open(path)
+// discard 把文件移入回收站。
+func (p *defaultPreprocessor) discard(path, reason string) error {
+	_, err := p.trash.Discard(context.Background(), path, trash.SourcePreprocess, reason)
+	return err
+}
+
+// isMediaFileDamaged 是一个不带 receiver 的辅助函数版本。
+// 图片以能否完整解码为准，视频以能否解析出容器头部为准。
+func isMediaFileDamaged(fsys fsutil.FS, path string) bool {
+	file, err := fsys.Open(path)
 	if err != nil {
 		return true
 	}
 	defer file.Close()
+	if formats.IsVideoExtension(path) {
+		_, err = videometa.Probe(file)
+		return err != nil
+	}
 	_, _, err = image.Decode(file)
 	return err != nil
 }
-
-// isImageExtension 是一个包内可用的辅助函数
-func isImageExtension(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif":
-		return true
-	default:
-		return false
-	}
-}
@@ -0,0 +1,153 @@
+package scanner
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/pkg/formats"
+	"PICs_Manager/pkg/fsutil"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FileRuleResult 是一个文件的分类规则测试结果。
+type FileRuleResult struct {
+	Input string `json:"input"`
+	// RuleIndex 是命中的 filePatterns 序号 (从 0 开始)，-1 表示没有命中。
+	RuleIndex int    `json:"ruleIndex"`
+	Pattern   string `json:"pattern,omitempty"`
+	// SeriesName 是按 filePatterns 提取并清理后的系列名。
+	SeriesName string `json:"seriesName,omitempty"`
+	// Classifier / ClassifiedAs 是完整分类链的结果，只输入文件名时依赖路径或文件内容的分类器不会生效。
+	Classifier   string `json:"classifier,omitempty"`
+	ClassifiedAs string `json:"classifiedAs,omitempty"`
+}
+
+// FolderRuleResult 是一个系列文件夹的聚合规则测试结果。
+type FolderRuleResult struct {
+	Input string `json:"input"`
+	// RuleIndex 是命中的 seriesGroupPatterns 序号 (从 0 开始)，-1 表示没有命中。
+	RuleIndex int    `json:"ruleIndex"`
+	Rule      string `json:"rule,omitempty"`
+	Group     string `json:"group,omitempty"`
+	AggFolder string `json:"aggFolder,omitempty"`
+	// Members 是本次输入中属于同一组的文件夹数量，少于 2 时扫描不会真正创建聚合目录。
+	Members int `json:"members,omitempty"`
+}
+
+// RuleTestReport 是规则测试的结果，测试不会移动任何文件。
+type RuleTestReport struct {
+	Files   []FileRuleResult   `json:"files"`
+	Folders []FolderRuleResult `json:"folders"`
+}
+
+// EvaluateRules 用 cfg 中的 filePatterns、classifiers 和 seriesGroupPatterns 测试文件名和文件夹名。
+// files 可以是文件名或完整路径，folders 是系列文件夹名；scanRoot 是 files 所在的扫描根目录，为空时取每个文件所在的目录。
+func EvaluateRules(cfg config.ScannerConfig, scanRoot string, files, folders []string) (*RuleTestReport, error) {
+	regex, err := newRegexStrategy(cfg.FilePatterns)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := NewClassifyChain(fsutil.OS, cfg.Classifiers, cfg.FilePatterns)
+	if err != nil {
+		return nil, err
+	}
+	groupRules, err := compileGroupRules(cfg.SeriesGroupRules)
+	if err != nil {
+		return nil, err
+	}
+	c := &chainClassifier{chain: chain}
+
+	report := &RuleTestReport{Files: []FileRuleResult{}, Folders: []FolderRuleResult{}}
+	for _, f := range files {
+		res := FileRuleResult{Input: f, RuleIndex: -1}
+		if idx, name := regex.match(filepath.Base(f)); idx >= 0 {
+			res.RuleIndex, res.Pattern, res.SeriesName = idx, cfg.FilePatterns[idx], sanitizeName(name)
+		}
+		// 没有扫描根目录时以文件所在目录代替，避免 parent-folder 把 "." 当作系列名
+		root := scanRoot
+		if root == "" {
+			root = filepath.Dir(f)
+		}
+		res.ClassifiedAs, res.Classifier = c.classify(root, f)
+		report.Files = append(report.Files, res)
+	}
+
+	members := make(map[string]int)
+	for _, folder := range folders {
+		res := FolderRuleResult{Input: folder, RuleIndex: -1}
+		if idx, group := matchGroupRule(groupRules, strings.TrimSuffix(folder, aggSuffix)); idx >= 0 {
+			res.RuleIndex, res.Rule, res.Group = idx, groupRules[idx].Name, group
+			res.AggFolder = sanitizeName(group) + aggSuffix
+			members[group]++
+		}
+		report.Folders = append(report.Folders, res)
+	}
+	for i := range report.Folders {
+		if report.Folders[i].RuleIndex >= 0 {
+			report.Folders[i].Members = members[report.Folders[i].Group]
+		}
+	}
+	return report, nil
+}
+
+// SampleDir 从 dir 中取样：递归查找最多 limit 个媒体文件 (完整路径)，以及最多 limit 个直接子目录名。
+func SampleDir(dir string, limit int) (files, folders []string, err error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无法读取目录 %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") && len(folders) < limit {
+			folders = append(folders, e.Name())
+		}
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if len(files) >= limit {
+			return filepath.SkipAll
+		}
+		if !d.IsDir() && formats.IsMediaExtension(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, folders, err
+}
+
+func compileGroupRules(rules []config.SeriesGroupRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的系列分组模式 '%s': %w", rule.Name, err)
+		}
+		compiled = append(compiled, compiledRule{Name: rule.Name, Re: re})
+	}
+	return compiled, nil
+}
+
+// matchGroupRule 返回第一个从 baseName 中提取出非空 group 命名分组的规则序号和分组名，没有命中时返回 -1。
+func matchGroupRule(rules []compiledRule, baseName string) (int, string) {
+	for idx, rule := range rules {
+		matches := rule.Re.FindStringSubmatch(baseName)
+		if len(matches) <= 1 {
+			continue
+		}
+		for i, n := range rule.Re.SubexpNames() {
+			if n == "group" && i < len(matches) && matches[i] != "" {
+				return idx, matches[i]
+			}
+		}
+	}
+	return -1, ""
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"context"
+	"log"
+	"time"
+)
+
+// runTracker 把一次扫描运行的检查点写入数据库。记录创建失败时只在内存中记录阶段，不能在重启后继续。
+// 它同时实现了 IngestCheckpoint，入库阶段按批记录已完成的系列。
+type runTracker struct {
+	store    database.ScanRunStore
+	run      *models.ScanRun
+	ingested map[string]bool
+}
+
+// resumeRun 返回上一次中断的运行，没有时返回 nil。
+func (o *Orchestrator) resumeRun(ctx context.Context) *runTracker {
+	run, err := o.runs.FindUnfinished(ctx)
+	if err != nil {
+		log.Printf("警告：无法读取扫描运行记录，本次不会继续中断的运行: %v", err)
+		return nil
+	}
+	if run == nil {
+		return nil
+	}
+	t := &runTracker{store: o.runs, run: run, ingested: make(map[string]bool, len(run.IngestedSeries))}
+	for _, path := range run.IngestedSeries {
+		t.ingested[path] = true
+	}
+	return t
+}
+
+// startRun 为扫描 scanPath 创建一个新的运行记录。记录创建失败时扫描照常进行，只是无法在中断后继续。
+func (o *Orchestrator) startRun(ctx context.Context, scanPath string) *runTracker {
+	t := &runTracker{run: &models.ScanRun{ScanPath: scanPath, Stage: models.ScanStageStarted}, ingested: map[string]bool{}}
+	if err := o.runs.Create(ctx, t.run); err != nil {
+		log.Printf("警告：无法创建扫描运行记录，本次扫描中断后将无法继续: %v", err)
+		return t
+	}
+	t.store = o.runs
+	log.Printf("扫描运行记录已创建: %s", t.run.ID.Hex())
+	return t
+}
+
+// id 返回运行记录的 ID，没有记录时返回空字符串。
+func (t *runTracker) id() string {
+	if t.store == nil {
+		return ""
+	}
+	return t.run.ID.Hex()
+}
+
+// save 把运行推进到 stage 并保存检查点。
+func (t *runTracker) save(ctx context.Context, stage models.ScanStage) {
+	t.run.Stage = stage
+	if t.store == nil {
+		return
+	}
+	if err := t.store.Update(ctx, t.run); err != nil {
+		log.Printf("警告：无法保存扫描运行 %s 的检查点 (阶段 %s): %v", t.id(), stage, err)
+	}
+}
+
+// complete 把运行标记为已完成，之后的扫描不会再继续它。
+func (t *runTracker) complete(ctx context.Context) {
+	now := time.Now()
+	t.run.CompletedAt = &now
+	t.save(ctx, models.ScanStageCompleted)
+}
+
+// checkpoint 返回入库阶段使用的检查点，没有运行记录时返回 nil (一次性入库)。
+func (t *runTracker) checkpoint() IngestCheckpoint {
+	if t.store == nil {
+		return nil
+	}
+	return t
+}
+
+func (t *runTracker) Done(seriesPath string) bool {
+	return t.ingested[seriesPath]
+}
+
+func (t *runTracker) Commit(ctx context.Context, seriesPaths []string) error {
+	if err := t.store.AddIngestedSeries(ctx, t.run.ID, seriesPaths); err != nil {
+		return err
+	}
+	for _, path := range seriesPaths {
+		t.ingested[path] = true
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package scanner
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/pkg/fsutil"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// StagingReport 描述中转站中遗留的内容。
+// 正常结束的扫描会把中转站清空，有遗留通常意味着上次扫描在分类之后、归档之前中断了。
+type StagingReport struct {
+	Path   string         `json:"path"`
+	Series []StagedSeries `json:"series"`
+	// LooseFiles 是直接位于中转站根目录下、不属于任何系列的文件，归档时不会处理它们。
+	LooseFiles []string `json:"looseFiles"`
+	Files      int      `json:"files"`
+	Bytes      int64    `json:"bytes"`
+}
+
+// StagedSeries 是中转站中的一个系列文件夹。
+type StagedSeries struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// Empty 判断中转站是否没有遗留内容。
+func (r *StagingReport) Empty() bool {
+	return len(r.Series) == 0 && len(r.LooseFiles) == 0
+}
+
+// StagingReturn 是把中转站内容退回扫描目录的结果。
+type StagingReturn struct {
+	Moved int `json:"moved"`
+	// Conflicts 是扫描目录中已有同名文件、因此仍留在中转站中的文件。
+	Conflicts []string `json:"conflicts"`
+	// Failed 是移动失败的文件及原因。
+	Failed map[string]string `json:"failed"`
+}
+
+// InspectStaging 统计中转站中遗留的系列和文件，中转站不存在时返回空报告。
+func InspectStaging(stagingPath string) (*StagingReport, error) {
+	return inspectStaging(fsutil.OS, stagingPath)
+}
+
+func inspectStaging(fsys fsutil.FS, stagingPath string) (*StagingReport, error) {
+	absStagingPath, err := filepath.Abs(stagingPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取中转站路径的绝对路径 '%s': %w", stagingPath, err)
+	}
+	report := &StagingReport{Path: absStagingPath, Series: []StagedSeries{}, LooseFiles: []string{}}
+	entries, err := fsys.ReadDir(absStagingPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, nil
+		}
+		return nil, fmt.Errorf("无法读取中转站目录: %w", err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(absStagingPath, entry.Name())
+		if !entry.IsDir() {
+			report.LooseFiles = append(report.LooseFiles, path)
+			report.Files++
+			if info, err := entry.Info(); err == nil {
+				report.Bytes += info.Size()
+			}
+			continue
+		}
+		series := StagedSeries{Name: entry.Name()}
+		err := fsutil.WalkDir(fsys, path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			series.Files++
+			if info, err := d.Info(); err == nil {
+				series.Bytes += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("无法读取中转站系列文件夹 %s: %w", path, err)
+		}
+		report.Series = append(report.Series, series)
+		report.Files += series.Files
+		report.Bytes += series.Bytes
+	}
+	return report, nil
+}
+
+// ResumeStaging 从中转站继续上次中断的扫描：把中转站中的系列归档到最终库并入库，不重新扫描 scanPath。
+func (o *Orchestrator) ResumeStaging(ctx context.Context, cfg config.ScannerConfig) (*ScanReport, error) {
+	absStagingPath, err := filepath.Abs(cfg.StagingPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取中转站路径的绝对路径 '%s': %w", cfg.StagingPath, err)
+	}
+	absFinalLibraryPath, err := filepath.Abs(cfg.FinalLibraryPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取最终库路径的绝对路径 '%s': %w", cfg.FinalLibraryPath, err)
+	}
+
+	staged, err := inspectStaging(o.fs, absStagingPath)
+	if err != nil {
+		return nil, err
+	}
+	report := &ScanReport{Classifiers: map[string]int{}, Unclassified: []string{}}
+	if len(staged.Series) == 0 {
+		log.Println("中转站中没有遗留的系列，无需继续。")
+		return report, nil
+	}
+	log.Printf("--- 从中转站继续：%d 个系列，%d 个文件 ---", len(staged.Series), staged.Files)
+
+	seriesNames := make([]string, 0, len(staged.Series))
+	for _, s := range staged.Series {
+		seriesNames = append(seriesNames, s.Name)
+		report.ProcessedFiles += s.Files
+	}
+	report.Series = len(seriesNames)
+
+	log.Printf("--- 阶段 1/2: 聚合与归档 ---")
+	changelog, err := o.Aggregator.AggregateAndArchive(absStagingPath, absFinalLibraryPath)
+	if err != nil {
+		return report, fmt.Errorf("聚合归档失败: %w", err)
+	}
+	log.Printf("--- 归档阶段完毕，生成变更日志，共 %d 项变更 ---", len(changelog))
+
+	log.Println("--- 阶段 2/2: 数据库同步 ---")
+	overwritten, err := o.Ingestor.Sync(ctx, absFinalLibraryPath, seriesNames, nil, changelog, nil)
+	if err != nil {
+		return report, fmt.Errorf("数据库同步失败: %w", err)
+	}
+	if len(overwritten) > 0 {
+		log.Printf("警告：在操作过程中，检测到 %d 个文件可能被覆盖，详情请查看 ingestor.log", len(overwritten))
+	}
+	if len(staged.LooseFiles) > 0 {
+		log.Printf("警告：中转站根目录下的 %d 个文件不属于任何系列，未被处理，可以退回扫描目录后重新扫描", len(staged.LooseFiles))
+	}
+	log.Println("从中转站继续的任务完成。")
+	return report, nil
+}
+
+// ReturnStaging 把中转站中的内容退回扫描目录，之后可以重新扫描。
+// 系列文件夹中的文件放回 scanPath 下同名的文件夹 (按文件夹分类的规则会得到相同的系列名)，根目录下的文件放回 scanPath 根目录。
+// 扫描目录中已有同名文件时不覆盖，文件留在中转站并在结果中列出。两个目录都通过 fsys 访问。
+func ReturnStaging(fsys fsutil.FS, stagingPath, scanPath string) (*StagingReturn, error) {
+	absStagingPath, err := filepath.Abs(stagingPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取中转站路径的绝对路径 '%s': %w", stagingPath, err)
+	}
+	absScanPath, err := filepath.Abs(scanPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取扫描路径的绝对路径 '%s': %w", scanPath, err)
+	}
+	result := &StagingReturn{Conflicts: []string{}, Failed: map[string]string{}}
+	if _, err := fsys.Stat(absStagingPath); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	var dirs []string
+	err = fsutil.WalkDir(fsys, absStagingPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == absStagingPath {
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		rel, err := filepath.Rel(absStagingPath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(absScanPath, rel)
+		if fsutil.Exists(fsys, target) {
+			log.Printf("退回冲突: 扫描目录中已存在 %s，文件保留在中转站", target)
+			result.Conflicts = append(result.Conflicts, path)
+			return nil
+		}
+		if err := fsys.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			result.Failed[path] = err.Error()
+			return nil
+		}
+		if err := fsys.Rename(path, target); err != nil {
+			log.Printf("错误: 退回 %s -> %s 失败: %v", path, target, err)
+			result.Failed[path] = err.Error()
+			return nil
+		}
+		result.Moved++
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("遍历中转站失败: %w", err)
+	}
+
+	// 由深到浅删除已经清空的系列文件夹，中转站根目录保留
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		fsys.Remove(d)
+	}
+	log.Printf("已把 %d 个文件从中转站退回扫描目录 %s，%d 个冲突，%d 个失败", result.Moved, absScanPath, len(result.Conflicts), len(result.Failed))
+	return result, nil
+}
@@ -0,0 +1,224 @@
+// Package sidecar 读取下载器 (gallery-dl、pixiv 下载器等) 写在媒体文件旁边的 .json 元数据文件。
+package sidecar
+
+import (
+	"PICs_Manager/pkg/fsutil"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ext 是元数据文件的扩展名。
+const Ext = ".json"
+
+// Metadata 是从元数据文件中提取的来源信息。
+type Metadata struct {
+	Title     string
+	Author    string
+	Tags      []string
+	SourceURL string
+	PostedAt  *time.Time
+}
+
+// 各字段依次尝试的键，覆盖 gallery-dl (pixiv、twitter、danbooru 等提取器) 和常见 pixiv 下载器的命名。
+var (
+	titleKeys  = []string{"title"}
+	authorKeys = []string{"user.name", "artist", "author.name", "author", "uploader", "tag_string_artist"}
+	urlKeys    = []string{"source_url", "page_url", "url", "source", "file_url"}
+	dateKeys   = []string{"date", "create_date", "created_at", "upload_date", "uploadDate"}
+	tagKeys    = []string{"tags", "tag_string_general"}
+)
+
+// dateLayouts 是下载器写入日期时常用的格式。
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"20060102",
+}
+
+// Path 返回 mediaPath 对应的元数据文件路径，不存在时返回空字符串。
+// 依次尝试 "<文件名>.json" (gallery-dl 默认) 和 "<去掉扩展名的文件名>.json"。
+// mediaPath 本身是 .json 文件时总是返回空字符串。
+func Path(mediaPath string) string {
+	return PathFS(fsutil.OS, mediaPath)
+}
+
+// PathFS 与 Path 相同，只是在 fsys 中查找。
+func PathFS(fsys fsutil.FS, mediaPath string) string {
+	if strings.EqualFold(filepath.Ext(mediaPath), Ext) {
+		return ""
+	}
+	candidates := []string{
+		mediaPath + Ext,
+		strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + Ext,
+	}
+	for _, c := range candidates {
+		if info, err := fsys.Stat(c); err == nil && !info.IsDir() {
+			return c
+		}
+	}
+	return ""
+}
+
+// Load 读取 mediaPath 旁的元数据文件并解析为字段表。没有元数据文件时返回 nil, nil。
+func Load(mediaPath string) (map[string]any, error) {
+	return LoadFS(fsutil.OS, mediaPath)
+}
+
+// LoadFS 与 Load 相同，只是从 fsys 中读取。
+func LoadFS(fsys fsutil.FS, mediaPath string) (map[string]any, error) {
+	path := PathFS(fsys, mediaPath)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := fsutil.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("解析元数据文件 %s 失败: %w", path, err)
+	}
+	return fields, nil
+}
+
+// Parse 从字段表中提取来源信息，所有字段都为空时返回 nil。
+func Parse(fields map[string]any) *Metadata {
+	if fields == nil {
+		return nil
+	}
+	md := &Metadata{
+		Title:     first(fields, titleKeys),
+		Author:    first(fields, authorKeys),
+		SourceURL: first(fields, urlKeys),
+		Tags:      tags(fields),
+	}
+	for _, key := range dateKeys {
+		if t := parseDate(lookup(fields, key)); t != nil {
+			md.PostedAt = t
+			break
+		}
+	}
+	if md.Title == "" && md.Author == "" && md.SourceURL == "" && len(md.Tags) == 0 && md.PostedAt == nil {
+		return nil
+	}
+	return md
+}
+
+// LoadMetadata 读取并解析 mediaPath 旁的元数据文件，没有元数据文件时返回 nil, nil。
+func LoadMetadata(mediaPath string) (*Metadata, error) {
+	return LoadMetadataFS(fsutil.OS, mediaPath)
+}
+
+// LoadMetadataFS 与 LoadMetadata 相同，只是从 fsys 中读取。
+func LoadMetadataFS(fsys fsutil.FS, mediaPath string) (*Metadata, error) {
+	fields, err := LoadFS(fsys, mediaPath)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return Parse(fields), nil
+}
+
+// MovedPath 返回媒体文件从 mediaPath 移动到 newMediaPath 后，其元数据文件 sidecarPath 应使用的新路径。
+// 新路径保持原有的命名方式 ("<文件名>.json" 或 "<去掉扩展名的文件名>.json")，以便之后仍能找到。
+func MovedPath(sidecarPath, mediaPath, newMediaPath string) string {
+	if sidecarPath == mediaPath+Ext {
+		return newMediaPath + Ext
+	}
+	return strings.TrimSuffix(newMediaPath, filepath.Ext(newMediaPath)) + Ext
+}
+
+// String 返回字段的字符串形式，key 可以用 "." 访问嵌套对象 (例如 "user.name")。
+// 字段不存在、为空或不是标量时返回空字符串。
+func String(fields map[string]any, key string) string {
+	v := lookup(fields, key)
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return ""
+}
+
+func lookup(fields map[string]any, key string) any {
+	var v any = fields
+	for _, part := range strings.Split(key, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if v, ok = m[part]; !ok {
+			return nil
+		}
+	}
+	return v
+}
+
+func first(fields map[string]any, keys []string) string {
+	for _, key := range keys {
+		if v := String(fields, key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// tags 支持字符串数组、{"name": ...} 对象数组 (pixiv) 和以空格分隔的字符串 (danbooru)。
+func tags(fields map[string]any) []string {
+	var out []string
+	seen := make(map[string]struct{})
+	add := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if _, ok := seen[tag]; tag != "" && !ok {
+			seen[tag] = struct{}{}
+			out = append(out, tag)
+		}
+	}
+	for _, key := range tagKeys {
+		switch v := lookup(fields, key).(type) {
+		case []any:
+			for _, item := range v {
+				switch t := item.(type) {
+				case string:
+					add(t)
+				case map[string]any:
+					add(String(t, "name"))
+				}
+			}
+		case string:
+			for _, t := range strings.Fields(v) {
+				add(t)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return out
+}
+
+// parseDate 解析字符串日期或 Unix 时间戳 (秒)。
+func parseDate(v any) *time.Time {
+	switch t := v.(type) {
+	case string:
+		for _, layout := range dateLayouts {
+			if parsed, err := time.ParseInLocation(layout, strings.TrimSpace(t), time.UTC); err == nil {
+				return &parsed
+			}
+		}
+	case float64:
+		if t > 0 {
+			parsed := time.Unix(int64(t), 0).UTC()
+			return &parsed
+		}
+	}
+	return nil
+}
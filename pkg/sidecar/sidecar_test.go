@@ -0,0 +1,84 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	date := func(s string) *time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &parsed
+	}
+
+	tests := []struct {
+		name string
+		json string
+		want *Metadata
+	}{
+		{
+			name: "gallery-dl pixiv",
+			json: `{"title": " Sunset ", "user": {"name": "Alice"}, "tags": [{"name": "sky"}, {"name": "sea"}, {"name": "sky"}],
+				"page_url": "https://www.pixiv.net/artworks/1", "date": "2024-03-04 05:06:07"}`,
+			want: &Metadata{Title: "Sunset", Author: "Alice", Tags: []string{"sky", "sea"},
+				SourceURL: "https://www.pixiv.net/artworks/1", PostedAt: date("2024-03-04T05:06:07Z")},
+		},
+		{
+			name: "danbooru tag strings",
+			json: `{"tag_string_artist": "bob", "tag_string_general": "1girl  solo", "file_url": "https://example.com/a.png",
+				"created_at": "2024-03-04T05:06:07+08:00"}`,
+			want: &Metadata{Author: "bob", Tags: []string{"1girl", "solo"}, SourceURL: "https://example.com/a.png",
+				PostedAt: date("2024-03-04T05:06:07+08:00")},
+		},
+		{
+			name: "earlier keys win and empty values are skipped",
+			json: `{"user": {"name": ""}, "artist": "carol", "author": "dave", "tags": [], "tag_string_general": "a b",
+				"source_url": "https://a", "url": "https://b"}`,
+			want: &Metadata{Author: "carol", Tags: []string{"a", "b"}, SourceURL: "https://a"},
+		},
+		{
+			name: "unix timestamp and compact date",
+			json: `{"title": "x", "date": 1700000000, "upload_date": "20240102"}`,
+			want: &Metadata{Title: "x", PostedAt: date("2023-11-14T22:13:20Z")},
+		},
+		{
+			name: "unparsable date falls through to the next key",
+			json: `{"date": "yesterday", "upload_date": "20240102"}`,
+			want: &Metadata{PostedAt: date("2024-01-02T00:00:00Z")},
+		},
+		{
+			name: "non-scalar values are ignored",
+			json: `{"title": {"en": "x"}, "author": ["a"], "tags": "lonely"}`,
+			want: &Metadata{Tags: []string{"lonely"}},
+		},
+		{
+			name: "nothing useful",
+			json: `{"width": 100, "extension": "png"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]any
+			if err := json.Unmarshal([]byte(tt.json), &fields); err != nil {
+				t.Fatal(err)
+			}
+			got := Parse(fields)
+			if got != nil && got.PostedAt != nil && tt.want != nil && tt.want.PostedAt != nil && got.PostedAt.Equal(*tt.want.PostedAt) {
+				got.PostedAt = tt.want.PostedAt
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Parse(nil); got != nil {
+		t.Errorf("Parse(nil) = %+v, want nil", got)
+	}
+}
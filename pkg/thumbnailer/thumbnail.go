@@ -1,14 +1,12 @@
 package thumbnailer
 
 import (
+	// 匿名导入格式注册表，确保所有支持的解码器都已注册
+	_ "PICs_Manager/pkg/formats"
 	"bytes"
 	"encoding/base64"
-	_ "golang.org/x/image/webp"
 	"image"
 	"image/jpeg"
-	// 匿名导入 image解码器
-	_ "image/gif"
-	_ "image/png"
 
 	"github.com/disintegration/imaging"
 )
@@ -28,3 +26,40 @@ func CreateBase64(srcImage image.Image, width, height int) (string, error) {
 
 	return "data:image/jpeg;base64," + encodedStr, nil
 }
+
+// RenderJPEG 将图片等比缩放到 maxWidth x maxHeight 以内 (不放大) 并编码为 JPEG，用于预览图。
+// 调用方应先用 ApplyOrientation 校正方向。
+func RenderJPEG(srcImage image.Image, maxWidth, maxHeight int) ([]byte, error) {
+	bounds := srcImage.Bounds()
+	if bounds.Dx() > maxWidth || bounds.Dy() > maxHeight {
+		srcImage = imaging.Fit(srcImage, maxWidth, maxHeight, imaging.Lanczos)
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, srcImage, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ApplyOrientation 按 EXIF 方向标签 (1-8) 旋转/翻转图片，使其以正确的朝向显示。
+// 1、0 或未知值原样返回。
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img) // 顺时针 90°
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img) // 逆时针 90°
+	default:
+		return img
+	}
+}
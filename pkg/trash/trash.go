@@ -0,0 +1,292 @@
+// Package trash 是应用内所有删除操作的统一出口。
+// 文件不会被直接删除，而是移动到 <回收站>/<删除日期>/ 下，并在同一目录的 manifest.jsonl
+// 和 "trash" 集合中各留一条记录；超过保留期的条目由后台任务永久删除。
+package trash
+
+import (
+	"PICs_Manager/config"
+	"PICs_Manager/internal/models"
+	"PICs_Manager/pkg/database"
+	"PICs_Manager/pkg/fsutil"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 删除的来源，用于 TrashItem.Source。
+const (
+	SourcePreprocess = "preprocess"
+	SourceQuarantine = "quarantine"
+	SourceAPI        = "api"
+)
+
+const (
+	manifestName = "manifest.jsonl"
+	dayLayout    = "2006-01-02"
+)
+
+var (
+	// ErrNotFound 表示回收站记录不存在。
+	ErrNotFound = errors.New("回收站记录不存在")
+	// ErrRestoreConflict 表示原始位置已被其他文件占用，无法恢复。
+	ErrRestoreConflict = fsutil.ErrExist
+	// ErrNotConfigured 表示没有配置回收站路径。
+	ErrNotConfigured = errors.New("未配置回收站路径 (scanner.trashPath)")
+)
+
+// manifestMu 串行化对 manifest.jsonl 的追加写入。
+var manifestMu sync.Mutex
+
+// Manager 定义了回收站的操作。
+type Manager interface {
+	// Get 返回一条回收站记录，不存在时返回 ErrNotFound。
+	Get(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error)
+	// Discard 把 path 指向的文件或文件夹移入回收站，并记录删除来源和原因。
+	Discard(ctx context.Context, path, source, reason string) (*models.TrashItem, error)
+	// Restore 把条目移回原始位置并删除记录。
+	Restore(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error)
+	// Purge 立即永久删除一个条目。
+	Purge(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error)
+	// PurgeExpired 永久删除所有超过保留期的条目，返回删除的数量。
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+type fsManager struct {
+	fs        fsutil.FS
+	root      string
+	retention time.Duration
+	store     database.TrashStore
+}
+
+// NewManager 创建一个以 fsys 中的 root 为回收站根目录的 Manager，被删除的文件同样通过 fsys 访问。
+// retention 为条目的保留期，小于等于 0 表示永不自动清除。
+func NewManager(fsys fsutil.FS, root string, retention time.Duration, store database.TrashStore) (Manager, error) {
+	if root == "" {
+		return nil, ErrNotConfigured
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取回收站路径的绝对路径 '%s': %w", root, err)
+	}
+	return &fsManager{fs: fsys, root: absRoot, retention: retention, store: store}, nil
+}
+
+func (m *fsManager) Discard(ctx context.Context, path, source, reason string) (*models.TrashItem, error) {
+	info, err := m.fs.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取待删除文件: %w", err)
+	}
+
+	now := time.Now()
+	item := &models.TrashItem{
+		ID:           primitive.NewObjectID(),
+		OriginalPath: path,
+		IsDir:        info.IsDir(),
+		FileName:     filepath.Base(path),
+		FileSize:     info.Size(),
+		Source:       source,
+		Reason:       reason,
+		CreatedAt:    now,
+	}
+	if item.IsDir {
+		item.FileSize = fsutil.DirSize(m.fs, path)
+	}
+	if m.retention > 0 {
+		item.ExpiresAt = now.Add(m.retention)
+	}
+
+	dayDir := filepath.Join(m.root, now.Format(dayLayout))
+	if err := m.fs.MkdirAll(dayDir, 0755); err != nil {
+		return nil, fmt.Errorf("无法创建回收站目录 %s: %w", dayDir, err)
+	}
+	// 以记录 ID 为前缀，避免同一天删除的同名文件互相覆盖
+	item.TrashedPath = fsutil.AvailablePath(m.fs, filepath.Join(dayDir, item.ID.Hex()+"_"+item.FileName))
+
+	if err := m.fs.Rename(path, item.TrashedPath); err != nil {
+		return nil, fmt.Errorf("移动文件到回收站失败: %w", err)
+	}
+	if err := m.appendManifest(dayDir, item); err != nil {
+		// manifest 只是冗余信息，写入失败不影响删除本身
+		log.Printf("警告: 写入回收站 manifest 失败: %v", err)
+	}
+	if err := m.store.Create(ctx, item); err != nil {
+		return nil, fmt.Errorf("文件已移入回收站 (%s)，但保存记录失败: %w", item.TrashedPath, err)
+	}
+	return item, nil
+}
+
+func (m *fsManager) Restore(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	item, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fsutil.MoveBack(m.fs, item.TrashedPath, item.OriginalPath); err != nil {
+		return nil, err
+	}
+	if err := m.store.Delete(ctx, item.ID); err != nil {
+		return nil, fmt.Errorf("文件已恢复，但删除回收站记录失败: %w", err)
+	}
+	m.removeEmptyDay(filepath.Dir(item.TrashedPath))
+	return item, nil
+}
+
+func (m *fsManager) Purge(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	item, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.purge(ctx, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (m *fsManager) PurgeExpired(ctx context.Context) (int, error) {
+	items, err := m.store.ListExpired(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for i := range items {
+		if err := m.purge(ctx, &items[i]); err != nil {
+			log.Printf("警告: 清除过期回收站条目 %s 失败: %v", items[i].TrashedPath, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *fsManager) Get(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	return m.load(ctx, id)
+}
+
+func (m *fsManager) purge(ctx context.Context, item *models.TrashItem) error {
+	if err := m.fs.RemoveAll(item.TrashedPath); err != nil {
+		return fmt.Errorf("删除回收站文件失败: %w", err)
+	}
+	if err := m.store.Delete(ctx, item.ID); err != nil {
+		return fmt.Errorf("删除回收站记录失败: %w", err)
+	}
+	m.removeEmptyDay(filepath.Dir(item.TrashedPath))
+	return nil
+}
+
+func (m *fsManager) load(ctx context.Context, id primitive.ObjectID) (*models.TrashItem, error) {
+	return database.GetExisting(ctx, m.store.GetByID, id, ErrNotFound)
+}
+
+// RunPurger 每隔 interval 清除一次过期条目，直到 ctx 被取消。
+// 启动时会先立即执行一次，以便处理服务停机期间过期的条目。
+func RunPurger(ctx context.Context, m Manager, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := m.PurgeExpired(ctx); err != nil {
+			log.Printf("错误: 清除过期回收站条目失败: %v", err)
+		} else if n > 0 {
+			log.Printf("已永久删除 %d 个过期的回收站条目", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purgers 管理每个库的后台清除任务。配置修改后调用 Restart，按新的保留期和清除间隔重新启动。
+type Purgers struct {
+	mu     sync.Mutex
+	stores map[string]database.Store // 按库名索引
+	cancel context.CancelFunc
+}
+
+// NewPurgers 创建后台清除任务的管理器，stores 是每个库的 Store，以库名为键。调用 Restart 后才开始清除。
+func NewPurgers(stores map[string]database.Store) *Purgers {
+	return &Purgers{stores: stores}
+}
+
+// Restart 停止正在运行的清除任务，并为 cfg 中每个设置了保留期的库启动新的清除任务。
+// 任意一个库的回收站无法创建时返回错误，原有的清除任务继续运行。
+func (p *Purgers) Restart(cfg *config.Config) error {
+	type purger struct {
+		library             string
+		manager             Manager
+		retention, interval time.Duration
+	}
+	var purgers []purger
+	for _, lib := range cfg.LibraryConfigs() {
+		store, ok := p.stores[lib.Name]
+		if !ok || lib.Scanner.TrashPath == "" || lib.Scanner.TrashRetention <= 0 {
+			continue
+		}
+		manager, err := NewManager(fsutil.OS, lib.Scanner.TrashPath, lib.Scanner.TrashRetention, store.Trash())
+		if err != nil {
+			return fmt.Errorf("库 %s: %w", lib.Name, err)
+		}
+		purgers = append(purgers, purger{lib.Name, manager, lib.Scanner.TrashRetention, lib.Scanner.TrashPurgeInterval})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for _, pg := range purgers {
+		go RunPurger(ctx, pg.manager, pg.interval)
+		log.Printf("回收站后台清除任务已启动: 库 %s, 保留期 %s, 间隔 %s", pg.library, pg.retention, pg.interval)
+	}
+	return nil
+}
+
+// Stop 停止所有清除任务。
+func (p *Purgers) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+// stop 取消正在运行的清除任务。调用方需持有锁。
+func (p *Purgers) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+func (m *fsManager) appendManifest(dayDir string, item *models.TrashItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	return fsutil.AppendFile(m.fs, filepath.Join(dayDir, manifestName), append(data, '\n'))
+}
+
+// removeEmptyDay 在日期目录中只剩下 manifest 时删除整个目录。
+func (m *fsManager) removeEmptyDay(dayDir string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	entries, err := m.fs.ReadDir(dayDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() != manifestName {
+			return
+		}
+	}
+	m.fs.RemoveAll(dayDir)
+}
@@ -0,0 +1,315 @@
+package videometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"golang.org/x/image/vp8"
+)
+
+// EBML/Matroska 元素 ID
+const (
+	idEBML          = 0x1A45DFA3
+	idDocType       = 0x4282
+	idSegment       = 0x18538067
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idDuration      = 0x4489
+	idTracks        = 0x1654AE6B
+	idTrackEntry    = 0xAE
+	idTrackNumber   = 0xD7
+	idTrackType     = 0x83
+	idCodecID       = 0x86
+	idVideo         = 0xE0
+	idPixelWidth    = 0xB0
+	idPixelHeight   = 0xBA
+	idCluster       = 0x1F43B675
+	idBlockGroup    = 0xA0
+	idBlock         = 0xA1
+	idSimpleBlock   = 0xA3
+
+	trackTypeVideo = 1
+	unknownSize    = -1
+
+	// maxElementSize 限制读入内存的单个元素大小 (Info、Tracks、Block)。
+	maxElementSize = 32 << 20
+	// maxScanBytes 是为寻找第一帧最多向后扫描的字节数，避免为了封面读完整个大文件。
+	maxScanBytes = 64 << 20
+)
+
+type ebmlReader struct {
+	r   io.ReadSeeker
+	pos int64
+}
+
+// readVint 读取一个 EBML 变长整数。keepMarker 为 true 时保留长度标记位 (用于元素 ID)。
+func (e *ebmlReader) readVint(keepMarker bool) (int64, int, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(e.r, first[:]); err != nil {
+		return 0, 0, err
+	}
+	e.pos++
+	length := 1
+	mask := byte(0x80)
+	for length <= 8 && first[0]&mask == 0 {
+		length++
+		mask >>= 1
+	}
+	if length > 8 {
+		return 0, 0, errors.New("无效的 EBML 变长整数")
+	}
+	value := int64(first[0])
+	if !keepMarker {
+		value &= int64(mask - 1)
+	}
+	allOnes := value == int64(mask-1)
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(e.r, rest); err != nil {
+		return 0, 0, err
+	}
+	e.pos += int64(length - 1)
+	for _, b := range rest {
+		value = value<<8 | int64(b)
+		if b != 0xFF {
+			allOnes = false
+		}
+	}
+	if !keepMarker && allOnes {
+		return unknownSize, length, nil
+	}
+	return value, length, nil
+}
+
+func (e *ebmlReader) readHeader() (id int64, size int64, err error) {
+	if id, _, err = e.readVint(true); err != nil {
+		return 0, 0, err
+	}
+	size, _, err = e.readVint(false)
+	return id, size, err
+}
+
+func (e *ebmlReader) readBody(size int64) ([]byte, error) {
+	if size < 0 || size > maxElementSize {
+		return nil, errors.New("EBML 元素过大")
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(e.r, buf); err != nil {
+		return nil, err
+	}
+	e.pos += size
+	return buf, nil
+}
+
+func (e *ebmlReader) skip(size int64) error {
+	if size < 0 {
+		return errors.New("无法跳过未知大小的 EBML 元素")
+	}
+	if _, err := e.r.Seek(size, io.SeekCurrent); err != nil {
+		return err
+	}
+	e.pos += size
+	return nil
+}
+
+type mkvTrack struct {
+	number int64
+	codec  string
+	width  int
+	height int
+}
+
+// probeMatroska 顺序读取 Segment 下的 Info、Tracks 和第一个 Cluster。
+// Segment 和 Cluster 可能是未知大小 (直播录制的 WebM)，因此按“进入”而不是“跳过”处理。
+func probeMatroska(r io.ReadSeeker) (*Info, error) {
+	e := &ebmlReader{r: r}
+	id, size, err := e.readHeader()
+	if err != nil || id != idEBML {
+		return nil, ErrUnsupported
+	}
+	header, err := e.readBody(size)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Container: "matroska"}
+	forEachChild(header, func(id int64, body []byte) {
+		if id == idDocType && string(body) == "webm" {
+			info.Container = "webm"
+		}
+	})
+
+	timecodeScale := int64(1000000) // 默认 1ms
+	var rawDuration float64
+	var video *mkvTrack
+
+	for e.pos < maxScanBytes {
+		id, size, err := e.readHeader()
+		if err != nil {
+			break
+		}
+		switch id {
+		case idSegment, idCluster, idBlockGroup:
+			// 容器元素：直接进入其子元素
+			continue
+		case idInfo:
+			body, err := e.readBody(size)
+			if err != nil {
+				return nil, err
+			}
+			forEachChild(body, func(id int64, b []byte) {
+				switch id {
+				case idTimecodeScale:
+					if v := readUint(b); v > 0 {
+						timecodeScale = v
+					}
+				case idDuration:
+					rawDuration = readFloat(b)
+				}
+			})
+		case idTracks:
+			body, err := e.readBody(size)
+			if err != nil {
+				return nil, err
+			}
+			video = parseVideoTrack(body)
+			if video != nil {
+				info.Codec, info.Width, info.Height = video.codec, video.width, video.height
+			}
+		case idSimpleBlock, idBlock:
+			body, err := e.readBody(size)
+			if err != nil {
+				return nil, err
+			}
+			if video != nil && video.codec == "V_VP8" && blockTrack(body) == video.number {
+				if img, ok := decodeVP8Block(body); ok {
+					info.Poster = img
+				}
+			}
+		default:
+			if size == unknownSize {
+				return nil, errors.New("遇到未知大小的非容器 EBML 元素")
+			}
+			if err := e.skip(size); err != nil {
+				return nil, err
+			}
+		}
+		// 拿到尺寸后，若不需要 (或已取得) 封面即可提前结束
+		if video != nil && (info.Poster != nil || video.codec != "V_VP8") && rawDuration > 0 {
+			break
+		}
+	}
+
+	info.Duration = rawDuration * float64(timecodeScale) / 1e9
+	if info.Width == 0 && info.Duration == 0 {
+		return nil, errors.New("Matroska 文件中没有可用的视频信息")
+	}
+	return info, nil
+}
+
+func parseVideoTrack(tracks []byte) *mkvTrack {
+	var found *mkvTrack
+	forEachChild(tracks, func(id int64, entry []byte) {
+		if id != idTrackEntry || found != nil {
+			return
+		}
+		t := &mkvTrack{}
+		var trackType int64
+		forEachChild(entry, func(id int64, b []byte) {
+			switch id {
+			case idTrackNumber:
+				t.number = readUint(b)
+			case idTrackType:
+				trackType = readUint(b)
+			case idCodecID:
+				t.codec = string(bytes.TrimRight(b, "\x00"))
+			case idVideo:
+				forEachChild(b, func(id int64, vb []byte) {
+					switch id {
+					case idPixelWidth:
+						t.width = int(readUint(vb))
+					case idPixelHeight:
+						t.height = int(readUint(vb))
+					}
+				})
+			}
+		})
+		if trackType == trackTypeVideo {
+			found = t
+		}
+	})
+	return found
+}
+
+// forEachChild 遍历一段内存中的 EBML 子元素。
+func forEachChild(data []byte, visit func(id int64, body []byte)) {
+	e := &ebmlReader{r: bytes.NewReader(data)}
+	for e.pos < int64(len(data)) {
+		id, size, err := e.readHeader()
+		if err != nil || size < 0 || e.pos+size > int64(len(data)) {
+			return
+		}
+		visit(id, data[e.pos:e.pos+size])
+		if err := e.skip(size); err != nil {
+			return
+		}
+	}
+}
+
+// blockTrack 读取 (Simple)Block 头部的轨道号。
+func blockTrack(block []byte) int64 {
+	e := &ebmlReader{r: bytes.NewReader(block)}
+	track, _, err := e.readVint(false)
+	if err != nil {
+		return -1
+	}
+	return track
+}
+
+// decodeVP8Block 解码 (Simple)Block 中未使用 lacing 的 VP8 关键帧。
+func decodeVP8Block(block []byte) (*image.YCbCr, bool) {
+	e := &ebmlReader{r: bytes.NewReader(block)}
+	if _, _, err := e.readVint(false); err != nil {
+		return nil, false
+	}
+	// timecode(2) + flags(1)
+	if int(e.pos)+3 > len(block) {
+		return nil, false
+	}
+	flags := block[e.pos+2]
+	if flags&0x06 != 0 {
+		return nil, false // 带 lacing 的块不处理
+	}
+	frame := block[e.pos+3:]
+	d := vp8.NewDecoder()
+	d.Init(bytes.NewReader(frame), len(frame))
+	fh, err := d.DecodeFrameHeader()
+	if err != nil || !fh.KeyFrame {
+		return nil, false
+	}
+	img, err := d.DecodeFrame()
+	if err != nil {
+		return nil, false
+	}
+	return img, true
+}
+
+func readUint(b []byte) int64 {
+	var v int64
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func readFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
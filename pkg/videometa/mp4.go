@@ -0,0 +1,232 @@
+package videometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// maxMoovSize 限制读入内存的 moov box 大小，防止损坏文件导致巨量内存分配。
+const maxMoovSize = 64 << 20
+
+type mp4Box struct {
+	typ     string
+	payload []byte
+}
+
+// probeMP4 在顶层 box 中定位 moov (它可能位于 mdat 之后)，并从中读取 mvhd、tkhd 和封面图。
+func probeMP4(r io.ReadSeeker) (*Info, error) {
+	var moov []byte
+	header := make([]byte, 16)
+	for moov == nil {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		size := uint64(binary.BigEndian.Uint32(header[0:4]))
+		typ := string(header[4:8])
+		headerLen := uint64(8)
+		if size == 1 {
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, err
+			}
+			size = binary.BigEndian.Uint64(header[8:16])
+			headerLen = 16
+		}
+		if size == 0 {
+			// size 为 0 表示该 box 一直延伸到文件末尾，moov 不可能在其后
+			if typ != "moov" {
+				break
+			}
+			cur, _ := r.Seek(0, io.SeekCurrent)
+			end, err := r.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, err
+			}
+			size = uint64(end-cur) + headerLen
+			if _, err := r.Seek(cur, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		if size < headerLen {
+			return nil, errors.New("无效的 MP4 box 大小")
+		}
+		bodyLen := size - headerLen
+		if typ == "moov" {
+			if bodyLen > maxMoovSize {
+				return nil, errors.New("MP4 moov box 过大")
+			}
+			moov = make([]byte, bodyLen)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if _, err := r.Seek(int64(bodyLen), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	if moov == nil {
+		return nil, errors.New("MP4 文件中没有 moov box")
+	}
+
+	info := &Info{Container: "mp4"}
+	for _, box := range readBoxes(moov) {
+		switch box.typ {
+		case "mvhd":
+			info.Duration = parseMvhd(box.payload)
+		case "trak":
+			if w, h, codec, ok := parseVideoTrak(box.payload); ok && info.Width == 0 {
+				info.Width, info.Height, info.Codec = w, h, codec
+			}
+		case "udta":
+			if info.Poster == nil {
+				info.Poster = findCoverArt(box.payload)
+			}
+		case "meta":
+			if info.Poster == nil && len(box.payload) > 4 {
+				info.Poster = findCoverArt(box.payload[4:])
+			}
+		}
+	}
+	return info, nil
+}
+
+// readBoxes 解析一段内存中的连续 box，遇到越界立即停止。
+func readBoxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		typ := string(data[4:8])
+		headerLen := uint64(8)
+		if size == 1 {
+			if len(data) < 16 {
+				break
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerLen = 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			break
+		}
+		boxes = append(boxes, mp4Box{typ: typ, payload: data[headerLen:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func parseMvhd(p []byte) float64 {
+	if len(p) < 1 {
+		return 0
+	}
+	var timescale, duration uint64
+	if p[0] == 1 {
+		if len(p) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(p[20:24]))
+		duration = binary.BigEndian.Uint64(p[24:32])
+	} else {
+		if len(p) < 20 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(p[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(p[16:20]))
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// parseVideoTrak 仅当轨道的 handler 为 "vide" 时返回 tkhd 中的显示宽高和 stsd 中的编码名。
+func parseVideoTrak(trak []byte) (width, height int, codec string, ok bool) {
+	var isVideo bool
+	for _, box := range readBoxes(trak) {
+		switch box.typ {
+		case "tkhd":
+			width, height = parseTkhd(box.payload)
+		case "mdia":
+			for _, mdiaChild := range readBoxes(box.payload) {
+				switch mdiaChild.typ {
+				case "hdlr":
+					// FullBox(4) + pre_defined(4) + handler_type(4)
+					if len(mdiaChild.payload) >= 12 && string(mdiaChild.payload[8:12]) == "vide" {
+						isVideo = true
+					}
+				case "minf":
+					codec = findSampleEntry(mdiaChild.payload)
+				}
+			}
+		}
+	}
+	return width, height, codec, isVideo && width > 0 && height > 0
+}
+
+func parseTkhd(p []byte) (int, int) {
+	if len(p) < 1 {
+		return 0, 0
+	}
+	// version 0: 4+4+4+4+4+4+8 ... version 1: 4+8+8+4+4+8+8；之后固定 52 字节到 width
+	offset := 76
+	if p[0] == 1 {
+		offset = 88
+	}
+	if len(p) < offset+8 {
+		return 0, 0
+	}
+	// 宽高为 16.16 定点数
+	w := binary.BigEndian.Uint32(p[offset:offset+4]) >> 16
+	h := binary.BigEndian.Uint32(p[offset+4:offset+8]) >> 16
+	return int(w), int(h)
+}
+
+// findSampleEntry 从 minf/stbl/stsd 中读取第一个 sample entry 的类型 (即编码，如 avc1、hvc1)。
+func findSampleEntry(minf []byte) string {
+	for _, box := range readBoxes(minf) {
+		if box.typ != "stbl" {
+			continue
+		}
+		for _, stblChild := range readBoxes(box.payload) {
+			// stsd: FullBox(4) + entry_count(4) + entries
+			if stblChild.typ == "stsd" && len(stblChild.payload) >= 16 {
+				return string(stblChild.payload[12:16])
+			}
+		}
+	}
+	return ""
+}
+
+// findCoverArt 在 udta/meta/ilst/covr/data 路径中查找内嵌封面并解码。
+func findCoverArt(data []byte) image.Image {
+	for _, box := range readBoxes(data) {
+		switch box.typ {
+		case "meta":
+			if len(box.payload) > 4 {
+				if img := findCoverArt(box.payload[4:]); img != nil {
+					return img
+				}
+			}
+		case "ilst":
+			if img := findCoverArt(box.payload); img != nil {
+				return img
+			}
+		case "covr":
+			for _, dataBox := range readBoxes(box.payload) {
+				// data box: type indicator(4) + locale(4) + 图片字节
+				if dataBox.typ == "data" && len(dataBox.payload) > 8 {
+					if img, _, err := image.Decode(bytes.NewReader(dataBox.payload[8:])); err == nil {
+						return img
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package videometa 以纯 Go 的方式从视频容器头部读取时长和画面尺寸，并在可能时提取封面帧。
+// 支持 MP4/MOV (ISO BMFF) 和 WebM/Matroska (EBML)，不依赖 ffmpeg。
+package videometa
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+// ErrUnsupported 表示文件不是可识别的视频容器。
+var ErrUnsupported = errors.New("无法识别的视频容器格式")
+
+// Info 是从容器头部解析出的视频元数据。
+type Info struct {
+	// Container 是容器格式: "mp4" 或 "webm"/"matroska"。
+	Container string
+	// Codec 是视频轨道的编码，例如 avc1、V_VP8、V_VP9；未知时为空。
+	Codec    string
+	Width    int
+	Height   int
+	Duration float64 // 秒
+
+	// Poster 是封面帧，只有在能纯 Go 解码时才存在：
+	// MP4 内嵌的封面图 (covr)，或 WebM 中 VP8 编码的第一个关键帧。
+	Poster image.Image
+}
+
+// Probe 根据文件头的魔数选择解析器并读取元数据。
+func Probe(r io.ReadSeeker) (*Info, error) {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, ErrUnsupported
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(head[4:8]) == "ftyp":
+		return probeMP4(r)
+	case head[0] == 0x1A && head[1] == 0x45 && head[2] == 0xDF && head[3] == 0xA3:
+		return probeMatroska(r)
+	}
+	return nil, ErrUnsupported
+}
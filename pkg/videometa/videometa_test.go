@@ -0,0 +1,223 @@
+package videometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	var cover bytes.Buffer
+	png.Encode(&cover, image.NewGray(image.Rect(0, 0, 3, 2)))
+
+	videoTrak := box("trak",
+		tkhd(1920, 1080),
+		box("mdia", hdlr("vide"), box("minf", box("stbl", stsd("avc1")))),
+	)
+	audioTrak := box("trak",
+		tkhd(0, 0),
+		box("mdia", hdlr("soun"), box("minf", box("stbl", stsd("mp4a")))),
+	)
+	moov := box("moov", mvhd(1000, 12500), audioTrak, videoTrak)
+
+	mkvVideo := ebml(idTracks, ebml(idTrackEntry,
+		ebml(idTrackNumber, []byte{1}),
+		ebml(idTrackType, []byte{trackTypeVideo}),
+		ebml(idCodecID, []byte("V_VP9")),
+		ebml(idVideo, ebml(idPixelWidth, []byte{0x02, 0x80}), ebml(idPixelHeight, []byte{0x01, 0x68})),
+	))
+	mkvAudio := ebml(idTracks, ebml(idTrackEntry,
+		ebml(idTrackNumber, []byte{1}),
+		ebml(idTrackType, []byte{2}),
+		ebml(idCodecID, []byte("A_OPUS")),
+	))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		want       Info
+		wantPoster image.Rectangle // 为空时要求没有封面
+		fail       bool            // 要求返回错误
+		wantErr    error           // 要求返回的错误是 wantErr，隐含 fail
+	}{
+		{
+			name: "mp4 with moov after mdat",
+			data: cat(ftyp(), box("mdat", make([]byte, 100)), moov),
+			want: Info{Container: "mp4", Codec: "avc1", Width: 1920, Height: 1080, Duration: 12.5},
+		},
+		{
+			name: "mp4 with 64-bit mdat size",
+			data: cat(ftyp(), largeBox("mdat", make([]byte, 10)), moov),
+			want: Info{Container: "mp4", Codec: "avc1", Width: 1920, Height: 1080, Duration: 12.5},
+		},
+		{
+			name: "mp4 moov extending to the end of the file",
+			data: cat(ftyp(), append([]byte{0, 0, 0, 0}, moov[4:]...)),
+			want: Info{Container: "mp4", Codec: "avc1", Width: 1920, Height: 1080, Duration: 12.5},
+		},
+		{
+			name: "mp4 version 1 mvhd",
+			data: cat(ftyp(), box("moov", mvhdV1(90000, 180000), videoTrak)),
+			want: Info{Container: "mp4", Codec: "avc1", Width: 1920, Height: 1080, Duration: 2},
+		},
+		{
+			name: "mp4 audio only",
+			data: cat(ftyp(), box("moov", mvhd(1000, 3000), audioTrak)),
+			want: Info{Container: "mp4", Duration: 3},
+		},
+		{
+			name: "mp4 cover art",
+			data: cat(ftyp(), box("moov", mvhd(1000, 1000), videoTrak,
+				box("udta", box("meta", append(make([]byte, 4), box("ilst", box("covr", box("data", append(make([]byte, 8), cover.Bytes()...))))...))))),
+			want:       Info{Container: "mp4", Codec: "avc1", Width: 1920, Height: 1080, Duration: 1},
+			wantPoster: image.Rect(0, 0, 3, 2),
+		},
+		{
+			name: "mp4 without moov",
+			data: cat(ftyp(), box("mdat", make([]byte, 10))),
+			fail: true,
+		},
+		{
+			name: "mp4 box smaller than its header",
+			data: cat(ftyp(), []byte{0, 0, 0, 4, 'f', 'r', 'e', 'e'}),
+			fail: true,
+		},
+		{
+			name: "webm with unknown-size segment",
+			data: cat(
+				ebml(idEBML, ebml(idDocType, []byte("webm"))),
+				unknownSizeElement(idSegment),
+				ebml(idInfo, ebml(idTimecodeScale, []byte{0x0F, 0x42, 0x40}), ebml(idDuration, float64Bytes(2500))),
+				mkvVideo,
+			),
+			want: Info{Container: "webm", Codec: "V_VP9", Width: 640, Height: 360, Duration: 2.5},
+		},
+		{
+			name: "matroska with custom timecode scale",
+			data: cat(
+				ebml(idEBML, ebml(idDocType, []byte("matroska"))),
+				ebml(idSegment,
+					ebml(idInfo, ebml(idTimecodeScale, []byte{0x3B, 0x9A, 0xCA, 0x00}), ebml(idDuration, float64Bytes(4))),
+					mkvVideo,
+				),
+			),
+			want: Info{Container: "matroska", Codec: "V_VP9", Width: 640, Height: 360, Duration: 4},
+		},
+		{
+			name: "matroska without video or duration",
+			data: cat(ebml(idEBML, ebml(idDocType, []byte("webm"))), ebml(idSegment, mkvAudio)),
+			fail: true,
+		},
+		{
+			name:    "unknown container",
+			data:    []byte("RIFF\x00\x00\x00\x00AVI LIST"),
+			wantErr: ErrUnsupported,
+		},
+		{
+			name:    "too short",
+			data:    []byte("ftyp"),
+			wantErr: ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Probe(bytes.NewReader(tt.data))
+			if tt.fail || tt.wantErr != nil {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("Probe() = %+v, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Probe() error: %v", err)
+			}
+			poster := got.Poster
+			got.Poster = nil
+			if *got != tt.want {
+				t.Errorf("Probe() = %+v, want %+v", *got, tt.want)
+			}
+			switch {
+			case tt.wantPoster.Empty() && poster != nil:
+				t.Errorf("Probe() returned an unexpected poster")
+			case !tt.wantPoster.Empty() && (poster == nil || poster.Bounds() != tt.wantPoster):
+				t.Errorf("Probe() poster = %v, want bounds %v", poster, tt.wantPoster)
+			}
+		})
+	}
+}
+
+func cat(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+func box(typ string, children ...[]byte) []byte {
+	payload := cat(children...)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(b, typ...), payload...)
+}
+
+// largeBox 生成一个使用 64 位 largesize 的 box。
+func largeBox(typ string, payload []byte) []byte {
+	b := append(binary.BigEndian.AppendUint32(nil, 1), typ...)
+	b = binary.BigEndian.AppendUint64(b, uint64(16+len(payload)))
+	return append(b, payload...)
+}
+
+func ftyp() []byte { return box("ftyp", []byte("isom\x00\x00\x02\x00isomavc1")) }
+
+func mvhd(timescale, duration uint32) []byte {
+	p := make([]byte, 100)
+	binary.BigEndian.PutUint32(p[12:16], timescale)
+	binary.BigEndian.PutUint32(p[16:20], duration)
+	return box("mvhd", p)
+}
+
+func mvhdV1(timescale uint32, duration uint64) []byte {
+	p := make([]byte, 112)
+	p[0] = 1
+	binary.BigEndian.PutUint32(p[20:24], timescale)
+	binary.BigEndian.PutUint64(p[24:32], duration)
+	return box("mvhd", p)
+}
+
+func tkhd(width, height uint32) []byte {
+	p := make([]byte, 84)
+	binary.BigEndian.PutUint32(p[76:80], width<<16)
+	binary.BigEndian.PutUint32(p[80:84], height<<16)
+	return box("tkhd", p)
+}
+
+func hdlr(handler string) []byte {
+	return box("hdlr", append(make([]byte, 8), handler+"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...))
+}
+
+func stsd(format string) []byte {
+	return box("stsd", append(make([]byte, 12), format+"\x00\x00\x00\x00\x00\x00\x00\x01"...))
+}
+
+// ebml 生成一个 EBML 元素，大小使用 8 字节的变长整数。
+func ebml(id int64, children ...[]byte) []byte {
+	body := cat(children...)
+	el := ebmlID(id)
+	el = append(el, 0x01)
+	el = append(el, binary.BigEndian.AppendUint64(nil, uint64(len(body)))[1:]...)
+	return append(el, body...)
+}
+
+// unknownSizeElement 生成一个大小未知的元素头，其子元素紧随其后。
+func unknownSizeElement(id int64) []byte {
+	return append(ebmlID(id), 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+}
+
+// ebmlID 按元素 ID 自身的长度标记写出它的字节。
+func ebmlID(id int64) []byte {
+	b := binary.BigEndian.AppendUint64(nil, uint64(id))
+	return bytes.TrimLeft(b, "\x00")
+}
+
+func float64Bytes(v float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+}
@@ -2,14 +2,30 @@
 import axios from 'axios';
 import type { SeriesListResponse } from '../types/entities';
 import type { Image } from '../types/entities';
+import type { Library } from '../types/entities';
 import type { AppConfig } from '../types/config';
 
 // 创建一个axios实例，统一配置后端API的基础URL
 const apiClient = axios.create({
     baseURL: 'http://localhost:8080/api/v1', // 请确保这与您Go后端的地址和端口一致
     timeout: 10000,
+    withCredentials: true, // 携带会话 Cookie (pics_session)
 });
 
+/**
+ * 登录并建立会话，后端会通过 Set-Cookie 下发会话 Cookie
+ */
+export const login = async (username: string, password: string): Promise<void> => {
+    await apiClient.post('/auth/login', { username, password });
+};
+
+/**
+ * 注销当前会话
+ */
+export const logout = async (): Promise<void> => {
+    await apiClient.post('/auth/logout');
+};
+
 /**
  * 获取系列列表（支持分页）
  * @param page - 请求的页码
@@ -96,6 +112,12 @@ export const searchByImage = async (file: File): Promise<SeriesListResponse> =>
     }
 };
 
+// 获取所有库
+export const getLibraries = async (): Promise<Library[]> => {
+    const response = await apiClient.get('/libraries');
+    return response.data;
+};
+
 // 获取当前配置
 export const getConfig = async (): Promise<AppConfig> => {
     const response = await apiClient.get('/config');
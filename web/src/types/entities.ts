@@ -11,9 +11,21 @@ export interface Timestamps {
 export interface Series extends Timestamps {
     ID: string;           // 对应 a`primitive.ObjectID`
     Name: string;
+    SortName?: string;         // 罗马字化的排序名，列表按它排序
+    SortNameOverride?: string; // 手动设置的排序名
     Path: string;
     ImageCount: number;
     Thumbnail: string;    // 这将用于显示缩略图，可能是Base64或一个URL
+    Source?: SourceInfo;  // 下载器元数据中的来源信息
+}
+
+// 对应后端的 SourceInfo struct (来自 gallery-dl 等下载器的 .json 元数据)
+export interface SourceInfo {
+    URL?: string;
+    Title?: string;
+    Author?: string;
+    Tags?: string[];
+    PostedAt?: string;
 }
 
 // 对应后端的 Image struct
@@ -25,6 +37,26 @@ export interface Image extends Timestamps {
     FileName: string;
     FilePath: string;
     Thumbnail: string;    // 图片自身的缩略图
+    Width: number;
+    Height: number;
+    Format?: string;
+    FileSize: number;
+    ColorModel?: string;
+    FrameCount?: number;
+    MediaType?: 'image' | 'animation' | 'video';
+    Duration?: number;
+    Codec?: string;
+    Source?: SourceInfo;
+    EXIF?: ImageEXIF;
+}
+
+// 对应后端的 ImageEXIF struct，仅在图片包含 EXIF 时存在
+export interface ImageEXIF {
+    CameraMake?: string;
+    CameraModel?: string;
+    Software?: string;
+    DateTaken?: string;
+    Orientation?: number;
 }
 
 // --- API响应的包装结构 (这部分保持不变) ---
@@ -38,4 +70,14 @@ export interface Pagination {
 export interface SeriesListResponse {
     data: Series[];
     pagination: Pagination;
-}
\ No newline at end of file
+}
+// 一个独立管理的库，default 为 true 的库是不带库名的旧 API 路由所操作的库
+export interface Library {
+    name: string;
+    default: boolean;
+    database: string;
+    collectionPrefix?: string;
+    scanPath: string;
+    finalLibraryPath: string;
+    libraryLayout: string;
+}